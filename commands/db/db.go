@@ -0,0 +1,69 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"github.com/mitchellh/cli"
+	"github.com/rande/goapp"
+
+	"github.com/rande/gonode/commands/server"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/core/config"
+
+	"fmt"
+)
+
+// run the function with the schema migrator of each tenant, the plugins are
+// configured so their migrations are available
+func runMigrator(ui cli.Ui, configFile string, f func(migrator *core.SchemaMigrator) error) int {
+	conf := config.NewServerConfig()
+
+	config.LoadConfigurationFromFile(configFile, conf)
+
+	if conf.Databases["master"] == nil || conf.Databases["master"].Type == "memory" {
+		ui.Error("The schema migrations require a PostgreSQL master database")
+
+		return 1
+	}
+
+	l := goapp.NewLifecycle()
+
+	server.ConfigureServer(l, conf)
+	server.ConfigurePlugins(l, conf)
+
+	l.Run(func(app *goapp.App, state *goapp.GoroutineState) error {
+		defer func() {
+			state.Out <- goapp.Control_Stop
+		}()
+
+		for _, migrator := range app.Get("gonode.schema.migrators").([]*core.SchemaMigrator) {
+			ui.Output(fmt.Sprintf("Prefix: %s", migrator.Prefix))
+
+			if err := f(migrator); err != nil {
+				ui.Error(err.Error())
+
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return l.Go(goapp.NewApp())
+}
+
+// report the migrations applied or reverted by a command
+func reportMigrations(ui cli.Ui, action string, migrations []*core.SchemaMigration) {
+	if len(migrations) == 0 {
+		ui.Info(fmt.Sprintf("No migration %s", action))
+
+		return
+	}
+
+	for _, migration := range migrations {
+		ui.Info(fmt.Sprintf("%s %d: %s", action, migration.Version, migration.Name))
+	}
+}
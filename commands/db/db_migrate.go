@@ -0,0 +1,53 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"flag"
+	"github.com/mitchellh/cli"
+
+	"github.com/rande/gonode/core"
+)
+
+type DbMigrateCommand struct {
+	Ui         cli.Ui
+	ConfigFile string
+}
+
+func (c *DbMigrateCommand) Help() string {
+	return `Apply the pending schema migrations of the core and of the plugins
+
+Usage: gonode db:migrate
+
+Options:
+  -config=server.toml.dist  the configuration file
+`
+}
+
+func (c *DbMigrateCommand) Run(args []string) int {
+
+	cmdFlags := flag.NewFlagSet("db:migrate", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	cmdFlags.StringVar(&c.ConfigFile, "config", "server.toml.dist", "")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	return runMigrator(c.Ui, c.ConfigFile, func(migrator *core.SchemaMigrator) error {
+		migrations, err := migrator.Migrate(context.Background())
+
+		reportMigrations(c.Ui, "applied", migrations)
+
+		return err
+	})
+}
+
+func (c *DbMigrateCommand) Synopsis() string {
+	return "apply the pending schema migrations"
+}
@@ -0,0 +1,62 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"flag"
+	"github.com/mitchellh/cli"
+
+	"github.com/rande/gonode/core"
+)
+
+type DbRollbackCommand struct {
+	Ui         cli.Ui
+	ConfigFile string
+	Steps      int
+}
+
+func (c *DbRollbackCommand) Help() string {
+	return `Revert the last applied schema migrations
+
+Usage: gonode db:rollback [-steps=1]
+
+Options:
+  -config=server.toml.dist  the configuration file
+  -steps=1                  the number of migrations to revert
+`
+}
+
+func (c *DbRollbackCommand) Run(args []string) int {
+
+	cmdFlags := flag.NewFlagSet("db:rollback", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	cmdFlags.StringVar(&c.ConfigFile, "config", "server.toml.dist", "")
+	cmdFlags.IntVar(&c.Steps, "steps", 1, "")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if c.Steps < 1 {
+		c.Ui.Error("Invalid -steps value, the value must be greater than 0")
+
+		return 1
+	}
+
+	return runMigrator(c.Ui, c.ConfigFile, func(migrator *core.SchemaMigrator) error {
+		migrations, err := migrator.Rollback(context.Background(), c.Steps)
+
+		reportMigrations(c.Ui, "reverted", migrations)
+
+		return err
+	})
+}
+
+func (c *DbRollbackCommand) Synopsis() string {
+	return "revert the last applied schema migrations"
+}
@@ -0,0 +1,67 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package db
+
+import (
+	"context"
+	"flag"
+	"github.com/mitchellh/cli"
+
+	"github.com/rande/gonode/core"
+
+	"fmt"
+)
+
+type DbStatusCommand struct {
+	Ui         cli.Ui
+	ConfigFile string
+}
+
+func (c *DbStatusCommand) Help() string {
+	return `List the schema migrations with their state
+
+Usage: gonode db:status
+
+Options:
+  -config=server.toml.dist  the configuration file
+`
+}
+
+func (c *DbStatusCommand) Run(args []string) int {
+
+	cmdFlags := flag.NewFlagSet("db:status", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	cmdFlags.StringVar(&c.ConfigFile, "config", "server.toml.dist", "")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	return runMigrator(c.Ui, c.ConfigFile, func(migrator *core.SchemaMigrator) error {
+		status, err := migrator.Status(context.Background())
+
+		if err != nil {
+			return err
+		}
+
+		for _, s := range status {
+			state := "pending"
+
+			if s.AppliedAt != nil {
+				state = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			c.Ui.Output(fmt.Sprintf("%d  %-32s %s", s.Migration.Version, s.Migration.Name, state))
+		}
+
+		return nil
+	})
+}
+
+func (c *DbStatusCommand) Synopsis() string {
+	return "list the schema migrations with their state"
+}
@@ -7,7 +7,9 @@ package main
 
 import (
 	"github.com/mitchellh/cli"
+	"github.com/rande/gonode/commands/db"
 	"github.com/rande/gonode/commands/dev"
+	"github.com/rande/gonode/commands/node"
 	"github.com/rande/gonode/commands/server"
 	"log"
 	"os"
@@ -36,6 +38,31 @@ func main() {
 				Ui: ui,
 			}, nil
 		},
+		"node:purge": func() (cli.Command, error) {
+			return &node.NodePurgeCommand{
+				Ui: ui,
+			}, nil
+		},
+		"migrate:nodes": func() (cli.Command, error) {
+			return &node.NodeMigrateCommand{
+				Ui: ui,
+			}, nil
+		},
+		"db:migrate": func() (cli.Command, error) {
+			return &db.DbMigrateCommand{
+				Ui: ui,
+			}, nil
+		},
+		"db:status": func() (cli.Command, error) {
+			return &db.DbStatusCommand{
+				Ui: ui,
+			}, nil
+		},
+		"db:rollback": func() (cli.Command, error) {
+			return &db.DbRollbackCommand{
+				Ui: ui,
+			}, nil
+		},
 	}
 
 	exitStatus, err := c.Run()
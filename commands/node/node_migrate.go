@@ -0,0 +1,88 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"flag"
+	sq "github.com/lann/squirrel"
+	"github.com/mitchellh/cli"
+	"github.com/rande/goapp"
+
+	"github.com/rande/gonode/commands/server"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/core/config"
+
+	"fmt"
+)
+
+type NodeMigrateCommand struct {
+	Ui         cli.Ui
+	ConfigFile string
+	Type       string
+}
+
+func (c *NodeMigrateCommand) Help() string {
+	return `Rewrite the nodes stored with an older data and meta version than the one of their handler
+
+Usage: gonode migrate:nodes [-type=blog.post]
+
+Options:
+  -config=server.toml.dist  the configuration file
+  -type=blog.post           only migrate the nodes of this type
+`
+}
+
+func (c *NodeMigrateCommand) Run(args []string) int {
+
+	cmdFlags := flag.NewFlagSet("migrate:nodes", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	cmdFlags.StringVar(&c.ConfigFile, "config", "server.toml.dist", "")
+	cmdFlags.StringVar(&c.Type, "type", "", "")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	conf := config.NewServerConfig()
+
+	config.LoadConfigurationFromFile(c.ConfigFile, conf)
+
+	l := goapp.NewLifecycle()
+
+	server.ConfigureServer(l, conf)
+
+	l.Run(func(app *goapp.App, state *goapp.GoroutineState) error {
+		defer func() {
+			state.Out <- goapp.Control_Stop
+		}()
+
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		query := manager.SelectBuilder(core.NewSelectOptions())
+
+		if c.Type != "" {
+			query = query.Where(sq.Eq{"type": c.Type})
+		}
+
+		migrated, err := manager.MigrateNodesContext(context.Background(), query)
+
+		c.Ui.Info(fmt.Sprintf("Migrated %d nodes", migrated))
+
+		if err != nil {
+			c.Ui.Error(err.Error())
+		}
+
+		return err
+	})
+
+	return l.Go(goapp.NewApp())
+}
+
+func (c *NodeMigrateCommand) Synopsis() string {
+	return "migrate the stored nodes to the current version of their handler"
+}
@@ -0,0 +1,102 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"flag"
+	"github.com/mitchellh/cli"
+	"github.com/rande/goapp"
+
+	"github.com/rande/gonode/commands/server"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/core/config"
+	"github.com/rande/gonode/plugins/api"
+
+	"fmt"
+)
+
+type NodePurgeCommand struct {
+	Ui           cli.Ui
+	ConfigFile   string
+	Before       string
+	KeepAudit    bool
+	KeepBinaries bool
+}
+
+func (c *NodePurgeCommand) Help() string {
+	return `Delete the nodes soft deleted before a date from the datastore
+
+Usage: gonode node:purge -before=2016-01-31 [-keep-audit] [-keep-binaries]
+
+Options:
+  -config=server.toml.dist  the configuration file
+  -before=date              the date (2016-01-31 or 2016-01-31T10:00:00Z), the nodes
+                            deleted after this date are kept
+  -keep-audit               keep the node's revisions stored in the audit table
+  -keep-binaries            keep the binaries stored in the vault
+`
+}
+
+func (c *NodePurgeCommand) Run(args []string) int {
+
+	cmdFlags := flag.NewFlagSet("node:purge", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.Ui.Output(c.Help()) }
+
+	cmdFlags.StringVar(&c.ConfigFile, "config", "server.toml.dist", "")
+	cmdFlags.StringVar(&c.Before, "before", "", "")
+	cmdFlags.BoolVar(&c.KeepAudit, "keep-audit", false, "")
+	cmdFlags.BoolVar(&c.KeepBinaries, "keep-binaries", false, "")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	before, err := api.ParseDate(c.Before)
+
+	if err != nil {
+		c.Ui.Error("Invalid -before value: " + err.Error())
+
+		return 1
+	}
+
+	conf := config.NewServerConfig()
+
+	config.LoadConfigurationFromFile(c.ConfigFile, conf)
+
+	l := goapp.NewLifecycle()
+
+	server.ConfigureServer(l, conf)
+
+	l.Run(func(app *goapp.App, state *goapp.GoroutineState) error {
+		defer func() {
+			state.Out <- goapp.Control_Stop
+		}()
+
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		query := manager.SelectBuilder(core.NewSelectOptions()).Where("updated_at < ?", before)
+
+		purged, err := manager.PurgeContext(context.Background(), query, &core.PurgeOptions{
+			KeepAudit:    c.KeepAudit,
+			KeepBinaries: c.KeepBinaries,
+		})
+
+		c.Ui.Info(fmt.Sprintf("Purged %d nodes deleted before %s", purged, before.Format("2006-01-02 15:04:05")))
+
+		if err != nil {
+			c.Ui.Error(err.Error())
+		}
+
+		return err
+	})
+
+	return l.Go(goapp.NewApp())
+}
+
+func (c *NodePurgeCommand) Synopsis() string {
+	return "delete the soft deleted nodes from the datastore"
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/rande/gonode/plugins/search"
 	"github.com/rande/gonode/plugins/security"
 	"github.com/rande/gonode/plugins/setup"
+	"github.com/rande/gonode/plugins/tenant"
 	"github.com/zenazn/goji/bind"
 	"github.com/zenazn/goji/graceful"
 	"github.com/zenazn/goji/web"
@@ -57,13 +58,7 @@ func (c *ServerCommand) Run(args []string) int {
 	l := goapp.NewLifecycle()
 
 	ConfigureServer(l, conf)
-
-	// add plugins
-	setup.ConfigureServer(l, conf)
-	security.ConfigureServer(l, conf)
-	search.ConfigureServer(l, conf)
-	api.ConfigureServer(l, conf)
-	guard.ConfigureServer(l, conf)
+	ConfigurePlugins(l, conf)
 
 	l.Run(func(app *goapp.App, state *goapp.GoroutineState) error {
 		mux := app.Get("goji.mux").(*web.Mux)
@@ -98,6 +93,16 @@ func (c *ServerCommand) Run(args []string) int {
 	return l.Go(goapp.NewApp())
 }
 
+// Configure the plugins, the plugins might add their schema migrations
+func ConfigurePlugins(l *goapp.Lifecycle, conf *config.ServerConfig) {
+	setup.ConfigureServer(l, conf)
+	security.ConfigureServer(l, conf)
+	search.ConfigureServer(l, conf)
+	api.ConfigureServer(l, conf)
+	tenant.ConfigureServer(l, conf)
+	guard.ConfigureServer(l, conf)
+}
+
 func (c *ServerCommand) Synopsis() string {
 	return "server local command"
 }
@@ -6,10 +6,12 @@
 package server
 
 import (
+	"context"
 	"github.com/rande/goapp"
 
 	"github.com/rande/gonode/core"
 	"github.com/rande/gonode/core/config"
+	"github.com/rande/gonode/helper"
 	"github.com/rande/gonode/plugins/api"
 	"github.com/rande/gonode/plugins/blog"
 	"github.com/rande/gonode/plugins/debug"
@@ -23,6 +25,8 @@ import (
 	pq "github.com/lib/pq"
 
 	"log"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/hypebeast/gojistatic"
@@ -31,6 +35,84 @@ import (
 	"os"
 )
 
+func getVault(root string) *vault.Vault {
+	return &vault.Vault{
+		BaseKey: []byte(""),
+		Algo:    "no_op",
+		Driver: &vault.DriverFs{
+			Root: root,
+		},
+	}
+}
+
+func getHandlerCollection(v *vault.Vault) core.HandlerCollection {
+	return core.HandlerCollection{
+		"default": &debug.DefaultHandler{},
+		"media.image": &media.ImageHandler{
+			Vault: v,
+		},
+		"media.youtube": &media.YoutubeHandler{},
+		"blog.post":     &blog.PostHandler{},
+		"core.user":     &user.UserHandler{},
+	}
+}
+
+// return the manager of the nodes stored with the prefix, the managers share the
+// master connection. The cached manager is invalidated by the manager events.
+func getNodeManager(app *goapp.App, conf *config.ServerConfig, prefix string, handlers core.Handlers) core.NodeManager {
+	manager := newNodeManager(app, conf, prefix, handlers)
+
+	if !conf.Cache.Enabled {
+		return manager
+	}
+
+	cached := core.NewCachedNodeManager(manager, handlers, conf.Cache.Size, time.Duration(conf.Cache.TTL)*time.Second)
+
+	app.Get("gonode.postgres.subscriber").(*core.Subscriber).ListenMessage(prefix+"_manager_action", cached.HandleNotification)
+
+	return cached
+}
+
+func newNodeManager(app *goapp.App, conf *config.ServerConfig, prefix string, handlers core.Handlers) core.NodeManager {
+	if conf.Databases["master"].Type == "memory" {
+		return &core.InMemoryNodeManager{
+			Logger:     app.Get("logger").(*log.Logger),
+			Subscriber: app.Get("gonode.postgres.subscriber").(*core.Subscriber),
+			ReadOnly:   false,
+			Handlers:   handlers,
+			Prefix:     prefix,
+			Integrity:  app.Get("gonode.integrity").(core.IntegrityRules),
+		}
+	}
+
+	return &core.PgNodeManager{
+		Logger:    app.Get("logger").(*log.Logger),
+		Db:        app.Get("gonode.postgres.connection").(*sql.DB),
+		Replicas:  app.Get("gonode.postgres.replicas").([]*sql.DB),
+		ReadOnly:  false,
+		Handlers:  handlers,
+		Prefix:    prefix,
+		Integrity: app.Get("gonode.integrity").(core.IntegrityRules),
+	}
+}
+
+func openConnection(dsn string) *sql.DB {
+	db, err := sql.Open("postgres", dsn)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.SetMaxIdleConns(8)
+	db.SetMaxOpenConns(64)
+
+	if err = db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
 func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 	l.Config(func(app *goapp.App) error {
@@ -51,8 +133,9 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 			mux := web.New()
 
 			mux.Use(middleware.RequestID)
+			mux.Use(helper.RequestIdMiddleware)
 			mux.Use(middleware.Logger)
-			mux.Use(middleware.Recoverer)
+			mux.Use(helper.Recoverer)
 			mux.Use(middleware.AutomaticOptions)
 			mux.Use(gojistatic.Static("dist", gojistatic.StaticOptions{SkipLogging: true, Prefix: "dist"}))
 
@@ -64,13 +147,7 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 	l.Register(func(app *goapp.App) error {
 		app.Set("gonode.vault.fs", func(app *goapp.App) interface{} {
-			return &vault.Vault{
-				BaseKey: []byte(""),
-				Algo:    "no_op",
-				Driver: &vault.DriverFs{
-					Root: conf.Filesystem.Path,
-				},
-			}
+			return getVault(conf.Filesystem.Path)
 		})
 
 		app.Set("gonode.http_client", func(app *goapp.App) interface{} {
@@ -78,49 +155,139 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 		})
 
 		app.Set("gonode.handler_collection", func(app *goapp.App) interface{} {
-			return core.HandlerCollection{
-				"default": &debug.DefaultHandler{},
-				"media.image": &media.ImageHandler{
-					Vault: app.Get("gonode.vault.fs").(*vault.Vault),
-				},
-				"media.youtube": &media.YoutubeHandler{},
-				"blog.post":     &blog.PostHandler{},
-				"core.user":     &user.UserHandler{},
+			return getHandlerCollection(app.Get("gonode.vault.fs").(*vault.Vault))
+		})
+
+		app.Set("gonode.integrity", func(app *goapp.App) interface{} {
+			rules := core.IntegrityRules{}
+
+			for field, rule := range conf.Integrity {
+				rules[field] = &core.IntegrityRule{
+					OnSave:   rule.OnSave,
+					OnDelete: rule.OnDelete,
+				}
 			}
+
+			if err := rules.Check(); err != nil {
+				log.Fatal(err)
+			}
+
+			return rules
 		})
 
 		app.Set("gonode.manager", func(app *goapp.App) interface{} {
-			return &core.PgNodeManager{
-				Logger:   app.Get("logger").(*log.Logger),
-				Db:       app.Get("gonode.postgres.connection").(*sql.DB),
-				ReadOnly: false,
-				Handlers: app.Get("gonode.handler_collection").(core.Handlers),
+			return getNodeManager(app, conf, conf.Databases["master"].Prefix, app.Get("gonode.handler_collection").(core.Handlers))
+		})
+
+		// the default tenant uses the master services, the other tenants share the
+		// master connection with their own prefix, vault and guard key
+		app.Set("gonode.tenants", func(app *goapp.App) interface{} {
+			tenants := core.NewTenants(&core.Tenant{
+				Name:     core.DefaultTenant,
 				Prefix:   conf.Databases["master"].Prefix,
+				Manager:  app.Get("gonode.manager").(core.NodeManager),
+				Handlers: app.Get("gonode.handler_collection").(core.Handlers),
+				GuardKey: []byte(conf.Guard.Key),
+			})
+
+			for name, c := range conf.Tenancy.Tenants {
+				if c.GuardKey == "" {
+					log.Fatalf("The tenant %s requires a guard_key", name)
+				}
+
+				prefix, filesystem := c.Prefix, c.Filesystem
+
+				if prefix == "" {
+					prefix = name
+				}
+
+				if filesystem == "" {
+					filesystem = filepath.Join(conf.Filesystem.Path, name)
+				}
+
+				handlers := getHandlerCollection(getVault(filesystem))
+
+				tenants.Add(&core.Tenant{
+					Name:     name,
+					Prefix:   prefix,
+					Manager:  getNodeManager(app, conf, prefix, handlers),
+					Handlers: handlers,
+					GuardKey: []byte(c.GuardKey),
+				})
+			}
+
+			return tenants
+		})
+
+		// the plugins add their migrations to the collection
+		app.Set("gonode.schema.migrations", func(app *goapp.App) interface{} {
+			migrations := &core.SchemaMigrations{}
+			migrations.Add(core.GetNodeSchemaMigrations()...)
+
+			return migrations
+		})
+
+		app.Set("gonode.schema.migrator", func(app *goapp.App) interface{} {
+			return &core.SchemaMigrator{
+				Db:         app.Get("gonode.postgres.connection").(*sql.DB),
+				Prefix:     conf.Databases["master"].Prefix,
+				Logger:     app.Get("logger").(*log.Logger),
+				Migrations: app.Get("gonode.schema.migrations").(*core.SchemaMigrations),
+			}
+		})
+
+		// one migrator per tenant, the default tenant first
+		app.Set("gonode.schema.migrators", func(app *goapp.App) interface{} {
+			migrators := make([]*core.SchemaMigrator, 0)
+
+			for _, tenant := range app.Get("gonode.tenants").(*core.Tenants).All() {
+				if tenant.Name == core.DefaultTenant {
+					migrators = append(migrators, app.Get("gonode.schema.migrator").(*core.SchemaMigrator))
+
+					continue
+				}
+
+				migrators = append(migrators, &core.SchemaMigrator{
+					Db:         app.Get("gonode.postgres.connection").(*sql.DB),
+					Prefix:     tenant.Prefix,
+					Logger:     app.Get("logger").(*log.Logger),
+					Migrations: app.Get("gonode.schema.migrations").(*core.SchemaMigrations),
+				})
 			}
+
+			return migrators
 		})
 
 		app.Set("gonode.postgres.connection", func(app *goapp.App) interface{} {
 			sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-			db, err := sql.Open("postgres", conf.Databases["master"].DSN)
 
-			db.SetMaxIdleConns(8)
-			db.SetMaxOpenConns(64)
+			return openConnection(conf.Databases["master"].DSN)
+		})
 
-			if err != nil {
-				log.Fatal(err)
+		// the enabled databases with the replica type, ordered by name
+		app.Set("gonode.postgres.replicas", func(app *goapp.App) interface{} {
+			names := make([]string, 0)
+
+			for name, database := range conf.Databases {
+				if database.Type == "replica" && database.Enabled {
+					names = append(names, name)
+				}
 			}
 
-			err = db.Ping()
-			if err != nil {
-				log.Fatal(err)
+			sort.Strings(names)
+
+			replicas := make([]*sql.DB, 0, len(names))
+
+			for _, name := range names {
+				replicas = append(replicas, openConnection(conf.Databases[name].DSN))
 			}
 
-			return db
+			return replicas
 		})
 
 		app.Set("gonode.api", func(app *goapp.App) interface{} {
 			return &api.Api{
-				Manager:    app.Get("gonode.manager").(*core.PgNodeManager),
+				Manager:    app.Get("gonode.manager").(core.NodeManager),
 				Version:    "1.0.0",
 				Serializer: app.Get("gonode.node.serializer").(*core.Serializer),
 				Logger:     app.Get("logger").(*log.Logger),
@@ -135,8 +302,15 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 		})
 
 		app.Set("gonode.postgres.subscriber", func(app *goapp.App) interface{} {
+			dsn := conf.Databases["master"].DSN
+
+			if conf.Databases["master"].Type == "memory" {
+				// notifications are dispatched in process by the manager
+				dsn = ""
+			}
+
 			return core.NewSubscriber(
-				conf.Databases["master"].DSN,
+				dsn,
 				app.Get("logger").(*log.Logger),
 			)
 		})
@@ -163,26 +337,28 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 		// need to find a way to trigger the handler registration
 		sub := app.Get("gonode.postgres.subscriber").(*core.Subscriber)
 
-		sub.ListenMessage("media_youtube_update", func(app *goapp.App) core.SubscriberHander {
-			manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		sub.ListenMessageContext("media_youtube_update", func(app *goapp.App) core.SubscriberHanderContext {
+			manager := app.Get("gonode.manager").(core.NodeManager)
 			listener := app.Get("gonode.listener.youtube").(*media.YoutubeListener)
 
-			return func(notification *pq.Notification) (int, error) {
-				return listener.Handle(notification, manager)
+			return func(ctx context.Context, notification *pq.Notification) (int, error) {
+				// the node has just been saved, the replicas might lag behind
+				return listener.HandleContext(core.WithReadYourWrites(ctx), notification, manager)
 			}
 		}(app))
 
-		sub.ListenMessage("media_file_download", func(app *goapp.App) core.SubscriberHander {
-			manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		sub.ListenMessageContext("media_file_download", func(app *goapp.App) core.SubscriberHanderContext {
+			manager := app.Get("gonode.manager").(core.NodeManager)
 			listener := app.Get("gonode.listener.file_downloader").(*media.ImageDownloadListener)
 
-			return func(notification *pq.Notification) (int, error) {
-				return listener.Handle(notification, manager)
+			return func(ctx context.Context, notification *pq.Notification) (int, error) {
+				// the node has just been saved, the replicas might lag behind
+				return listener.HandleContext(core.WithReadYourWrites(ctx), notification, manager)
 			}
 		}(app))
 
-		sub.ListenMessage("core_sleep", func(app *goapp.App) core.SubscriberHander {
-			return func(notification *pq.Notification) (int, error) {
+		sub.ListenMessageContext("core_sleep", func(app *goapp.App) core.SubscriberHanderContext {
+			return func(ctx context.Context, notification *pq.Notification) (int, error) {
 
 				logger := app.Get("logger").(*log.Logger)
 
@@ -190,7 +366,10 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 				logger.Printf("[core_sleep] sleep ...")
 
-				time.Sleep(duration)
+				select {
+				case <-time.After(duration):
+				case <-ctx.Done():
+				}
 
 				logger.Printf("[core_sleep] wake up ...")
 
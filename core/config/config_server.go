@@ -52,24 +52,71 @@ type ServerHandler struct {
 	Enabled bool   `toml:"enabled"`
 }
 
+// ServerIntegrity is the referential integrity rule of a reference field, see
+// the core.Integrity* constants for the values
+type ServerIntegrity struct {
+	OnSave   string `toml:"on_save"`
+	OnDelete string `toml:"on_delete"`
+}
+
+// ServerCache configures the cache of the nodes loaded by Find and FindOneBy,
+// the ttl is in seconds
+type ServerCache struct {
+	Enabled bool  `toml:"enabled"`
+	Size    int   `toml:"size"`
+	TTL     int64 `toml:"ttl"`
+}
+
+// ServerTenant is a tenant sharing the server with the default tenant, which
+// uses the master database prefix, the filesystem path and the guard key.
+type ServerTenant struct {
+	Hosts      []string `toml:"hosts"`
+	Path       string   `toml:"path"`
+	Prefix     string   `toml:"prefix"`
+	Filesystem string   `toml:"filesystem"`
+	GuardKey   string   `toml:"guard_key"`
+}
+
+// ServerTenancy configures how the tenant of a request is resolved, the resolvers
+// (host, path or claim) are tried in order.
+type ServerTenancy struct {
+	Resolvers []string                 `toml:"resolvers"`
+	Claim     string                   `toml:"claim"`
+	Tenants   map[string]*ServerTenant `toml:"tenants"`
+}
+
 type ServerConfig struct {
-	Name       string                     `toml:"name"`
-	Databases  map[string]*ServerDatabase `toml:"databases"`
-	Filesystem ServerFilesystem           `toml:"filesystem"`
-	Test       bool                       `toml:"test"`
-	Bind       string                     `toml:"bind"`
-	Guard      *ServerGuard               `toml:"guard"`
-	Security   *ServerSecurity            `toml:"security"`
-	Search     *ServerSearch              `toml:"search"`
+	Name       string                      `toml:"name"`
+	Databases  map[string]*ServerDatabase  `toml:"databases"`
+	Filesystem ServerFilesystem            `toml:"filesystem"`
+	Test       bool                        `toml:"test"`
+	Bind       string                      `toml:"bind"`
+	Guard      *ServerGuard                `toml:"guard"`
+	Security   *ServerSecurity             `toml:"security"`
+	Search     *ServerSearch               `toml:"search"`
+	Integrity  map[string]*ServerIntegrity `toml:"integrity"`
+	Tenancy    *ServerTenancy              `toml:"tenancy"`
+	Cache      *ServerCache                `toml:"cache"`
 }
 
 func NewServerConfig() *ServerConfig {
 	return &ServerConfig{
 		Databases: make(map[string]*ServerDatabase),
+		Integrity: make(map[string]*ServerIntegrity),
 		Bind:      ":2408",
 		Test:      false,
 		Search: &ServerSearch{
 			MaxResult: 128,
 		},
+		Tenancy: &ServerTenancy{
+			Resolvers: []string{"host"},
+			Claim:     "tenant",
+			Tenants:   make(map[string]*ServerTenant),
+		},
+		Cache: &ServerCache{
+			Enabled: false,
+			Size:    1024,
+			TTL:     60,
+		},
 	}
 }
@@ -59,6 +59,26 @@ key = "ZeSecretKey0oo"
 [search]
     max_result = 256
 
+[integrity]
+    [integrity.parent_uuid]
+    on_save   = "reject"
+    on_delete = "cascade"
+
+[tenancy]
+resolvers = ["claim", "path"]
+
+    [tenancy.tenants.acme]
+    hosts      = ["acme.example.org"]
+    path       = "/acme"
+    prefix     = "acme"
+    filesystem = "/tmp/gnode/acme"
+    guard_key  = "AcmeSecretKey"
+
+[cache]
+enabled = true
+size    = 512
+ttl     = 30
+
 `, config)
 
 	// test general configuration
@@ -82,6 +102,23 @@ key = "ZeSecretKey0oo"
 	// test search
 	assert.Equal(t, uint64(256), config.Search.MaxResult)
 
+	// test integrity
+	assert.Equal(t, "reject", config.Integrity["parent_uuid"].OnSave)
+	assert.Equal(t, "cascade", config.Integrity["parent_uuid"].OnDelete)
+
+	// test tenancy
+	assert.Equal(t, []string{"claim", "path"}, config.Tenancy.Resolvers)
+	assert.Equal(t, []string{"acme.example.org"}, config.Tenancy.Tenants["acme"].Hosts)
+	assert.Equal(t, "/acme", config.Tenancy.Tenants["acme"].Path)
+	assert.Equal(t, "acme", config.Tenancy.Tenants["acme"].Prefix)
+	assert.Equal(t, "/tmp/gnode/acme", config.Tenancy.Tenants["acme"].Filesystem)
+	assert.Equal(t, "AcmeSecretKey", config.Tenancy.Tenants["acme"].GuardKey)
+
+	// test cache
+	assert.True(t, config.Cache.Enabled)
+	assert.Equal(t, 512, config.Cache.Size)
+	assert.Equal(t, int64(30), config.Cache.TTL)
+
 	// debug
 	config.Guard.Jwt.Login.Path = `^\/nodes\/(.*)$`
 
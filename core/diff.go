@@ -0,0 +1,188 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of a RFC 6902 JSON Patch document
+type PatchOperation struct {
+	Op    string
+	Path  string
+	From  string // used by the move and copy operations
+	Value interface{}
+}
+
+func (o *PatchOperation) MarshalJSON() ([]byte, error) {
+	switch o.Op {
+	case "remove":
+		return json.Marshal(&struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	case "move", "copy":
+		return json.Marshal(&struct {
+			Op   string `json:"op"`
+			From string `json:"from"`
+			Path string `json:"path"`
+		}{o.Op, o.From, o.Path})
+	}
+
+	// the value is required by the other operations, even if the value is null
+	return json.Marshal(&struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// Patch is a RFC 6902 JSON Patch document
+type Patch []*PatchOperation
+
+// Compare the nodes field by field, the Data and Meta values are compared
+// recursively. Applying the returned patch to the json representation of the
+// from node gives the json representation of the to node.
+func DiffNodes(from, to *Node) (Patch, error) {
+	a, err := toJsonValue(from)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := toJsonValue(to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffJson(a, b), nil
+}
+
+// Compare two values decoded from json (ie, map[string]interface{}, []interface{},
+// string, json.Number or float64, bool and nil).
+func DiffJson(from, to interface{}) Patch {
+	patch := Patch{}
+	patch.diff("", from, to)
+
+	return patch
+}
+
+func (p *Patch) diff(path string, from, to interface{}) {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		if b, ok := to.(map[string]interface{}); ok {
+			p.diffObject(path, a, b)
+
+			return
+		}
+	case []interface{}:
+		if b, ok := to.([]interface{}); ok {
+			p.diffArray(path, a, b)
+
+			return
+		}
+	default:
+		if isSameJsonValue(from, to) {
+			return
+		}
+	}
+
+	*p = append(*p, &PatchOperation{Op: "replace", Path: path, Value: to})
+}
+
+func (p *Patch) diffObject(path string, from, to map[string]interface{}) {
+	keys := make([]string, 0, len(from)+len(to))
+
+	for key := range from {
+		keys = append(keys, key)
+	}
+
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		a, inFrom := from[key]
+		b, inTo := to[key]
+		sub := path + "/" + escapePointer(key)
+
+		switch {
+		case !inTo:
+			*p = append(*p, &PatchOperation{Op: "remove", Path: sub})
+		case !inFrom:
+			*p = append(*p, &PatchOperation{Op: "add", Path: sub, Value: b})
+		default:
+			p.diff(sub, a, b)
+		}
+	}
+}
+
+// the common elements are compared by position, then the extra elements are
+// added at the end of the array or removed from the end of the array.
+func (p *Patch) diffArray(path string, from, to []interface{}) {
+	i := 0
+
+	for ; i < len(from) && i < len(to); i++ {
+		p.diff(path+"/"+strconv.Itoa(i), from[i], to[i])
+	}
+
+	for j := i; j < len(to); j++ {
+		*p = append(*p, &PatchOperation{Op: "add", Path: path + "/" + strconv.Itoa(j), Value: to[j]})
+	}
+
+	for j := len(from) - 1; j >= i; j-- {
+		*p = append(*p, &PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(j)})
+	}
+}
+
+func isSameJsonValue(a, b interface{}) bool {
+	switch v := a.(type) {
+	case nil:
+		return b == nil
+	case json.Number:
+		if w, ok := b.(json.Number); ok {
+			return v == w
+		}
+
+		return false
+	}
+
+	return a == b
+}
+
+// encode a key as a JSON Pointer token, see RFC 6901
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+// the numbers are decoded as json.Number to keep the original representation
+func toJsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
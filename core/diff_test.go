@@ -0,0 +1,93 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_DiffJson(t *testing.T) {
+	from, _ := toJsonValue(map[string]interface{}{
+		"title": "Hello",
+		"tags":  []string{"a", "b", "c"},
+		"a/b":   1,
+		"author": map[string]interface{}{
+			"name": "Thomas",
+			"age":  30,
+		},
+		"removed": true,
+	})
+
+	to, _ := toJsonValue(map[string]interface{}{
+		"title": "Hello world",
+		"tags":  []string{"a", "d"},
+		"a/b":   1,
+		"author": map[string]interface{}{
+			"name": "Thomas",
+			"age":  31,
+			"city": nil,
+		},
+	})
+
+	patch := DiffJson(from, to)
+
+	assert.Equal(t, 6, len(patch))
+	assert.Equal(t, &PatchOperation{Op: "replace", Path: "/author/age", Value: json.Number("31")}, patch[0])
+	assert.Equal(t, &PatchOperation{Op: "add", Path: "/author/city", Value: nil}, patch[1])
+	assert.Equal(t, &PatchOperation{Op: "remove", Path: "/removed"}, patch[2])
+	assert.Equal(t, &PatchOperation{Op: "replace", Path: "/tags/1", Value: "d"}, patch[3])
+	assert.Equal(t, &PatchOperation{Op: "remove", Path: "/tags/2"}, patch[4])
+	assert.Equal(t, &PatchOperation{Op: "replace", Path: "/title", Value: "Hello world"}, patch[5])
+
+	assert.Equal(t, 0, len(DiffJson(from, from)))
+}
+
+func Test_DiffJson_Type(t *testing.T) {
+	patch := DiffJson(map[string]interface{}{"a": []interface{}{}}, map[string]interface{}{"a": "string"})
+
+	assert.Equal(t, Patch{&PatchOperation{Op: "replace", Path: "/a", Value: "string"}}, patch)
+
+	patch = DiffJson([]interface{}{"a"}, []interface{}{"a", "b", "c"})
+
+	assert.Equal(t, Patch{
+		&PatchOperation{Op: "add", Path: "/1", Value: "b"},
+		&PatchOperation{Op: "add", Path: "/2", Value: "c"},
+	}, patch)
+}
+
+func Test_DiffNodes(t *testing.T) {
+	from := NewNode()
+	from.Name = "User A"
+	from.Data = &User{Username: "user-a"}
+	from.Meta = &UserMeta{}
+
+	to := *from
+	to.Name = "User B"
+	to.Revision = 2
+	to.Data = &User{Username: "user-b"}
+
+	patch, err := DiffNodes(from, &to)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(patch))
+	assert.Equal(t, "/data/username", patch[0].Path)
+	assert.Equal(t, "/name", patch[1].Path)
+	assert.Equal(t, "/revision", patch[2].Path)
+
+	b := bytes.NewBuffer([]byte{})
+	Serialize(b, patch)
+
+	assert.Equal(t, `[{"op":"replace","path":"/data/username","value":"user-b"},{"op":"replace","path":"/name","value":"User B"},{"op":"replace","path":"/revision","value":2}]`+"\n", b.String())
+
+	b.Reset()
+	Serialize(b, Patch{&PatchOperation{Op: "remove", Path: "/a~1b~0c"}})
+
+	assert.Equal(t, `[{"op":"remove","path":"/a~1b~0c"}]`+"\n", b.String())
+	assert.Equal(t, "/a~1b~0c", "/"+escapePointer("a/b~c"))
+}
@@ -5,6 +5,10 @@
 
 package core
 
+import (
+	"fmt"
+)
+
 var (
 	ValidationError             = &validationError{"Unable to validate date"}
 	RevisionError               = &revisionError{"Wrong revision while saving"}
@@ -12,6 +16,8 @@ var (
 	InvalidReferenceFormatError = &invalidReferenceFormatError{"Unable to parse the reference"}
 	AlreadyDeletedError         = &alreadyDeletedError{"Unable to find the node"}
 	NoStreamHandler             = &noStreamHandlerError{"No stream handler defined"}
+	InvalidFieldError           = &invalidFieldError{"Unable to use the field"}
+	EmptyDataError              = &emptyDataError{"No data read from the request"}
 )
 
 type validationError struct {
@@ -62,10 +68,72 @@ func (e *invalidReferenceFormatError) Error() string {
 	return e.message
 }
 
+type invalidFieldError struct {
+	message string
+}
+
+func (e *invalidFieldError) Error() string {
+	return e.message
+}
+
+type emptyDataError struct {
+	message string
+}
+
+func (e *emptyDataError) Error() string {
+	return e.message
+}
+
 func NewRevisionError(message string) error {
 	return &revisionError{message}
 }
 
+// return true if the error is a revision error, ie the saved node is newer
+func IsRevisionError(err error) bool {
+	_, ok := err.(*revisionError)
+
+	return ok
+}
+
+// ConnectionError is returned when the datastore cannot be reached
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("Unable to reach the datastore: %s", e.Err)
+}
+
+// DecodeError is returned when the stored data or meta of a node cannot be decoded
+type DecodeError struct {
+	Uuid Reference
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Unable to decode the node %s: %s", e.Uuid.CleanString(), e.Err)
+}
+
+// ValidationErrors is returned when a node is not valid, the errors are the
+// validation errors by field
+type ValidationErrors struct {
+	Errors Errors
+}
+
+func (e *ValidationErrors) Error() string {
+	return "The node is not valid"
+}
+
+// ConstraintError is returned when a datastore's constraint is violated
+type ConstraintError struct {
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("Constraint %s violated: %s", e.Constraint, e.Err)
+}
+
 // use for model validation
 func NewErrors() Errors {
 	return Errors{}
@@ -6,6 +6,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 )
@@ -25,6 +26,7 @@ type HandlerCollection map[string]Handler
 func (c HandlerCollection) NewNode(t string) *Node {
 	node := NewNode()
 	node.Type = t
+	node.Version = GetHandlerVersion(c.Get(node))
 	node.Data, node.Meta = c.Get(node).GetStruct()
 
 	return node
@@ -73,6 +75,90 @@ type Handler interface {
 	StoreStream(node *Node, r io.Reader) (int64, error)
 }
 
+// HandlerContext is implemented by the handlers requiring the context of the
+// current operation, ie to forward it to the NodeManager. The methods are used
+// by the managers instead of the ones without context.
+type HandlerContext interface {
+	Handler
+
+	PreUpdateContext(ctx context.Context, node *Node, m NodeManager) error
+	PostUpdateContext(ctx context.Context, node *Node, m NodeManager) error
+	PreInsertContext(ctx context.Context, node *Node, m NodeManager) error
+	PostInsertContext(ctx context.Context, node *Node, m NodeManager) error
+	ValidateContext(ctx context.Context, node *Node, m NodeManager, e Errors)
+}
+
+// HandlerPurge is implemented by the handlers storing data outside the datastore,
+// ie binaries in a vault. The method is called once the node is purged and must
+// remove the data of all the node's revisions.
+type HandlerPurge interface {
+	Handler
+
+	Purge(node *Node) error
+}
+
+// HandlerCopy is implemented by the handlers storing data outside the datastore,
+// ie binaries in a vault. The method is called once the copy is saved and decides
+// which data of the source node must be duplicated for the copy.
+type HandlerCopy interface {
+	Handler
+
+	Copy(source *Node, node *Node) error
+}
+
+// HandlerMigration is implemented by the handlers whose data or meta structure
+// changed. GetVersion returns the current version and GetMigrations returns the
+// functions converting the raw data and meta of a version to the next one,
+// indexed by the version they migrate from.
+type HandlerMigration interface {
+	Handler
+
+	GetVersion() int
+	GetMigrations() map[int]NodeMigration
+}
+
+func handlerPreUpdate(ctx context.Context, h Handler, node *Node, m NodeManager) error {
+	if hc, ok := h.(HandlerContext); ok {
+		return hc.PreUpdateContext(ctx, node, m)
+	}
+
+	return h.PreUpdate(node, m)
+}
+
+func handlerPostUpdate(ctx context.Context, h Handler, node *Node, m NodeManager) error {
+	if hc, ok := h.(HandlerContext); ok {
+		return hc.PostUpdateContext(ctx, node, m)
+	}
+
+	return h.PostUpdate(node, m)
+}
+
+func handlerPreInsert(ctx context.Context, h Handler, node *Node, m NodeManager) error {
+	if hc, ok := h.(HandlerContext); ok {
+		return hc.PreInsertContext(ctx, node, m)
+	}
+
+	return h.PreInsert(node, m)
+}
+
+func handlerPostInsert(ctx context.Context, h Handler, node *Node, m NodeManager) error {
+	if hc, ok := h.(HandlerContext); ok {
+		return hc.PostInsertContext(ctx, node, m)
+	}
+
+	return h.PostInsert(node, m)
+}
+
+func handlerValidate(ctx context.Context, h Handler, node *Node, m NodeManager, e Errors) {
+	if hc, ok := h.(HandlerContext); ok {
+		hc.ValidateContext(ctx, node, m, e)
+
+		return
+	}
+
+	h.Validate(node, m, e)
+}
+
 func GetDownloadData() *DownloadData {
 	return &DownloadData{
 		ContentType:  "application/octet-stream",
@@ -89,13 +175,11 @@ func HandlerLoad(handler Handler, data []byte, meta []byte, node *Node) error {
 
 	node.Data, node.Meta = handler.GetStruct()
 
-	err = json.Unmarshal(data, node.Data)
-	PanicOnError(err)
-
-	err = json.Unmarshal(meta, node.Meta)
-	PanicOnError(err)
+	if err = json.Unmarshal(data, node.Data); err != nil {
+		return err
+	}
 
-	return nil
+	return json.Unmarshal(meta, node.Meta)
 }
 
 func DefaultHandlerStoreStream(node *Node, r io.Reader) (int64, error) {
@@ -0,0 +1,177 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/lann/squirrel"
+)
+
+const (
+	// nothing is checked (default)
+	IntegrityIgnore = "ignore"
+	// save: the referenced node must exist and must not be deleted
+	IntegrityReject = "reject"
+	// delete: the referencing nodes are removed too
+	IntegrityCascade = "cascade"
+	// delete: the reference of the referencing nodes is set to the empty reference
+	IntegritySetEmpty = "set_empty"
+	// delete: the node cannot be removed while other nodes reference it
+	IntegrityRestrict = "restrict"
+)
+
+var integrityFields = map[string]func(node *Node) *Reference{
+	"parent_uuid": func(node *Node) *Reference { return &node.ParentUuid },
+	"source":      func(node *Node) *Reference { return &node.Source },
+	"set_uuid":    func(node *Node) *Reference { return &node.SetUuid },
+}
+
+// IntegrityRule defines how a reference field is checked when a node is saved
+// and what happens to the referencing nodes when the referenced node is removed.
+type IntegrityRule struct {
+	OnSave   string
+	OnDelete string
+}
+
+// IntegrityRules are indexed by reference field: parent_uuid, source or set_uuid
+type IntegrityRules map[string]*IntegrityRule
+
+// Check the fields and the values of the rules
+func (rules IntegrityRules) Check() error {
+	for field, rule := range rules {
+		if _, ok := integrityFields[field]; !ok {
+			return fmt.Errorf("Invalid integrity field: %s", field)
+		}
+
+		switch rule.OnSave {
+		case "", IntegrityIgnore, IntegrityReject:
+		default:
+			return fmt.Errorf("Invalid integrity on_save rule for %s: %s", field, rule.OnSave)
+		}
+
+		switch rule.OnDelete {
+		case "", IntegrityIgnore, IntegrityCascade, IntegritySetEmpty, IntegrityRestrict:
+		default:
+			return fmt.Errorf("Invalid integrity on_delete rule for %s: %s", field, rule.OnDelete)
+		}
+	}
+
+	return nil
+}
+
+// IntegrityError is returned by RemoveOne if the node is referenced by nodes with
+// a restrict rule, the errors are indexed by field.
+type IntegrityError struct {
+	Errors Errors
+}
+
+func (e *IntegrityError) Error() string {
+	return "The node is referenced by other nodes"
+}
+
+// Add an error for each reference field with a reject rule pointing to a node
+// which does not exist or is deleted.
+func validateReferences(ctx context.Context, m NodeManager, rules IntegrityRules, node *Node, errors Errors) {
+	for field, rule := range rules {
+		reference := *integrityFields[field](node)
+
+		if rule.OnSave != IntegrityReject || reference.UUID == nil || isEmptyReference(reference) {
+			continue
+		}
+
+		referenced, err := m.FindContext(ctx, reference)
+
+		if err != nil {
+			errors.AddError(field, "Unable to check the referenced node")
+		} else if referenced == nil || referenced.Deleted {
+			errors.AddError(field, "The referenced node does not exist")
+		}
+	}
+}
+
+// return the query of the non deleted nodes referencing the node with the field
+func referencingQuery(m NodeManager, field string, node *Node) sq.SelectBuilder {
+	return m.SelectBuilder(NewSelectOptions()).
+		Where(sq.Eq{field: node.Uuid.CleanString()}).
+		Where("deleted = ?", false)
+}
+
+// Return an IntegrityError if the node is referenced by a field with a restrict
+// rule, it must be called before the node is removed.
+func restrictReferences(ctx context.Context, m NodeManager, rules IntegrityRules, node *Node) error {
+	errors := NewErrors()
+
+	for field, rule := range rules {
+		if rule.OnDelete != IntegrityRestrict {
+			continue
+		}
+
+		count, err := m.CountContext(ctx, referencingQuery(m, field, node))
+
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			errors.AddError(field, fmt.Sprintf("The node is referenced by %d node(s)", count))
+		}
+	}
+
+	if errors.HasErrors() {
+		return &IntegrityError{Errors: errors}
+	}
+
+	return nil
+}
+
+// Apply the cascade and set_empty rules once the node is saved as deleted. The
+// referencing nodes are removed with RemoveOne, so the rules are applied on them
+// too. As the parents are only altered by Move, the children of the removed node
+// are detached by the detach function provided by the manager: they become root
+// nodes without a new revision.
+func removeReferences(ctx context.Context, m NodeManager, rules IntegrityRules, node *Node, detach func(tx NodeManager, node *Node) error) error {
+	for field, rule := range rules {
+		if rule.OnDelete != IntegrityCascade && rule.OnDelete != IntegritySetEmpty {
+			continue
+		}
+
+		nodes, err := iterateToList(ctx, m, referencingQuery(m, field, node))
+
+		if err != nil {
+			return err
+		}
+
+		for e := nodes.Front(); e != nil; e = e.Next() {
+			referencing := e.Value.(*Node)
+
+			if rule.OnDelete == IntegrityCascade {
+				if _, err := m.RemoveOneContext(ctx, referencing); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if field == "parent_uuid" {
+				if err := detach(m, referencing); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			*integrityFields[field](referencing) = GetEmptyReference()
+			referencing.UpdatedAt = node.UpdatedAt
+
+			if _, err := m.SaveContext(ctx, referencing, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_IntegrityRules_Check(t *testing.T) {
+	assert.Nil(t, IntegrityRules{"parent_uuid": {OnSave: IntegrityReject, OnDelete: IntegrityCascade}}.Check())
+	assert.Nil(t, IntegrityRules{"source": {OnDelete: IntegritySetEmpty}}.Check())
+
+	assert.NotNil(t, IntegrityRules{"name": {OnSave: IntegrityReject}}.Check())
+	assert.NotNil(t, IntegrityRules{"source": {OnSave: IntegrityCascade}}.Check())
+	assert.NotNil(t, IntegrityRules{"source": {OnDelete: IntegrityReject}}.Check())
+}
+
+func Test_Integrity_Validate_Reject(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	node := m.NewNode("core.user")
+	node.Name = "c"
+	node.Source = GetRootReference()
+
+	// no rule, nothing is checked
+	_, errors := m.Validate(node)
+	assert.False(t, errors.HasError("source"))
+
+	m.Integrity = IntegrityRules{"source": {OnSave: IntegrityReject}}
+
+	ok, errors := m.Validate(node)
+	assert.False(t, ok)
+	assert.True(t, errors.HasError("source"))
+
+	node.Source = nodes["b"].Uuid
+
+	_, errors = m.Validate(node)
+	assert.False(t, errors.HasError("source"))
+
+	m.RemoveOne(m.Find(nodes["b"].Uuid))
+
+	ok, errors = m.Validate(node)
+	assert.False(t, ok)
+	assert.True(t, errors.HasError("source"))
+}
+
+func Test_Integrity_Remove_Restrict(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	m.Integrity = IntegrityRules{"parent_uuid": {OnDelete: IntegrityRestrict}}
+
+	_, err := m.RemoveOne(m.Find(nodes["a"].Uuid))
+
+	assert.IsType(t, &IntegrityError{}, err)
+	assert.True(t, err.(*IntegrityError).Errors.HasError("parent_uuid"))
+	assert.False(t, m.Find(nodes["a"].Uuid).Deleted)
+
+	// a leaf can be removed
+	_, err = m.RemoveOne(m.Find(nodes["a11"].Uuid))
+
+	assert.Nil(t, err)
+}
+
+func Test_Integrity_Remove_Cascade(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	m.Integrity = IntegrityRules{"parent_uuid": {OnDelete: IntegrityCascade}}
+
+	_, err := m.RemoveOne(m.Find(nodes["a"].Uuid))
+
+	assert.Nil(t, err)
+	assert.True(t, m.Find(nodes["a1"].Uuid).Deleted)
+	assert.True(t, m.Find(nodes["a11"].Uuid).Deleted)
+	assert.False(t, m.Find(nodes["b"].Uuid).Deleted)
+}
+
+func Test_Integrity_Remove_SetEmpty(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	m.Integrity = IntegrityRules{"parent_uuid": {OnDelete: IntegritySetEmpty}}
+
+	_, err := m.RemoveOne(m.Find(nodes["a"].Uuid))
+
+	assert.Nil(t, err)
+
+	a1 := m.Find(nodes["a1"].Uuid)
+
+	assert.False(t, a1.Deleted)
+	assert.True(t, isEmptyReference(a1.ParentUuid))
+	assert.Equal(t, 0, len(a1.Parents))
+
+	descendants, err := m.FindDescendants(a1.Uuid, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a11"}, getTreeNames(descendants))
+	assert.Equal(t, []Reference{a1.Uuid}, descendants.Front().Value.(*Node).Parents)
+}
@@ -7,9 +7,12 @@ package core
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
 	sq "github.com/lann/squirrel"
 	"github.com/twinj/uuid"
+	"strconv"
+	"time"
 )
 
 var (
@@ -33,11 +36,73 @@ func GetRootReference() Reference {
 	return rootUuid
 }
 
+// NodeIterator walks over the nodes matching a query without loading all of them
+// in memory, the iterator must be closed once done.
+//
+//	it, err := manager.Iterate(query)
+//	defer it.Close()
+//
+//	for it.Next() {
+//	    node := it.Node()
+//	}
+//
+//	err = it.Err()
+type NodeIterator interface {
+	// Move to the next node, false is returned once there is no more nodes or
+	// if an error occurs
+	Next() bool
+	Node() *Node
+	Err() error
+	Close() error
+}
+
+// NodeManagerContext is the context aware version of the NodeManager, the context
+// is used to cancel the pending queries and is sent to the handler's hooks.
+// The datastore errors are returned as ConnectionError, DecodeError or ConstraintError.
+// FindOneByContext and FindContext return a nil node and a nil error if there is no match.
+type NodeManagerContext interface {
+	FindByContext(ctx context.Context, query sq.SelectBuilder, offset uint64, limit uint64) (*list.List, error)
+	FindOneByContext(ctx context.Context, query sq.SelectBuilder) (*Node, error)
+	FindContext(ctx context.Context, uuid Reference) (*Node, error)
+	IterateContext(ctx context.Context, query sq.SelectBuilder) (NodeIterator, error)
+	FindChildrenContext(ctx context.Context, uuid Reference) (*list.List, error)
+	FindDescendantsContext(ctx context.Context, uuid Reference, depth int) (*list.List, error)
+	FindAncestorsContext(ctx context.Context, uuid Reference) (*list.List, error)
+	CountContext(ctx context.Context, query sq.SelectBuilder) (uint64, error)
+	AggregateContext(ctx context.Context, query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error)
+	RemoveContext(ctx context.Context, query sq.SelectBuilder) error
+	RemoveOneContext(ctx context.Context, node *Node) (*Node, error)
+	SaveContext(ctx context.Context, node *Node, revision bool) (*Node, error)
+	NotifyContext(ctx context.Context, channel string, payload string) error
+	ValidateContext(ctx context.Context, node *Node) (bool, Errors)
+	MoveContext(ctx context.Context, uuid, parent Reference) (int64, error)
+	CopyContext(ctx context.Context, uuid, parent Reference, deep bool) (*Node, error)
+	ReorderContext(ctx context.Context, parent Reference, uuids []Reference) (*list.List, error)
+	LinkContext(ctx context.Context, relation *Relation) (*Relation, error)
+	UnlinkContext(ctx context.Context, from, to Reference, kind string) (int64, error)
+	FindRelationsContext(ctx context.Context, from, to Reference, kind string) ([]*Relation, error)
+	RestoreContext(ctx context.Context, uuid Reference, revision int) (*Node, error)
+	UndeleteContext(ctx context.Context, node *Node) (*Node, error)
+	PurgeContext(ctx context.Context, query sq.SelectBuilder, options *PurgeOptions) (int64, error)
+	MigrateNodesContext(ctx context.Context, query sq.SelectBuilder) (int64, error)
+	TransactionContext(ctx context.Context, f func(tx NodeManager) error) error
+}
+
+// The methods without context are adapters running with context.Background(),
+// they panic if an error occurs while reading from the datastore.
 type NodeManager interface {
+	NodeManagerContext
+
 	SelectBuilder(option *SelectOptions) sq.SelectBuilder
 	FindBy(query sq.SelectBuilder, offset uint64, limit uint64) *list.List
 	FindOneBy(query sq.SelectBuilder) *Node
 	Find(uuid Reference) *Node
+	Iterate(query sq.SelectBuilder) (NodeIterator, error)
+	FindChildren(uuid Reference) (*list.List, error)
+	FindDescendants(uuid Reference, depth int) (*list.List, error)
+	FindAncestors(uuid Reference) (*list.List, error)
+	Count(query sq.SelectBuilder) (uint64, error)
+	Aggregate(query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error)
 	Remove(query sq.SelectBuilder) error
 	RemoveOne(node *Node) (*Node, error)
 	Save(node *Node, revision bool) (*Node, error)
@@ -45,4 +110,164 @@ type NodeManager interface {
 	NewNode(t string) *Node
 	Validate(node *Node) (bool, Errors)
 	Move(uuid, parent Reference) (int64, error)
+	Copy(uuid, parent Reference, deep bool) (*Node, error)
+	Reorder(parent Reference, uuids []Reference) (*list.List, error)
+	Link(relation *Relation) (*Relation, error)
+	Unlink(from, to Reference, kind string) (int64, error)
+	FindRelations(from, to Reference, kind string) ([]*Relation, error)
+	Restore(uuid Reference, revision int) (*Node, error)
+	Undelete(node *Node) (*Node, error)
+	Purge(query sq.SelectBuilder, options *PurgeOptions) (int64, error)
+	MigrateNodes(query sq.SelectBuilder) (int64, error)
+	Transaction(f func(tx NodeManager) error) error
+}
+
+// PurgeOptions configures the physical deletion of the soft deleted nodes
+type PurgeOptions struct {
+	// keep the node's revisions stored in the audit table
+	KeepAudit bool
+	// keep the data stored outside the datastore by the handlers, ie the vault's binaries
+	KeepBinaries bool
+}
+
+// validate the common node's fields and the references with a reject rule, then
+// delegate the validation to the related handler
+func validateNode(ctx context.Context, node *Node, m NodeManager, handlers Handlers, rules IntegrityRules) (bool, Errors) {
+	errors := NewErrors()
+
+	if node.Name == "" {
+		errors.AddError("name", "Username cannot be empty")
+	}
+
+	if node.Slug == "" {
+		errors.AddError("slug", "Name cannot be empty")
+	}
+
+	if node.Type == "" {
+		errors.AddError("type", "Type cannot be empty")
+	}
+
+	if node.Status < 0 || node.Status > 3 {
+		errors.AddError("status", "Invalid status")
+	}
+
+	validateReferences(ctx, m, rules, node, errors)
+
+	handlerValidate(ctx, handlers.Get(node), node, m, errors)
+
+	return !errors.HasErrors(), errors
+}
+
+// Copy the content of an old revision (data, meta, name, slug, status and weight)
+// to the current node and save it as a new revision. NotFoundError is returned if
+// the node or the revision does not exist and ValidationErrors if the restored node
+// is not valid.
+func restoreNode(ctx context.Context, m NodeManager, prefix string, uuid Reference, revision int) (*Node, error) {
+	var node *Node
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		current, err := tx.FindContext(ctx, uuid)
+
+		if err != nil {
+			return err
+		}
+
+		if current == nil {
+			return NotFoundError
+		}
+
+		if current.Deleted {
+			return AlreadyDeletedError
+		}
+
+		options := NewSelectOptions()
+		options.TableSuffix = "nodes_audit"
+
+		saved, err := tx.FindOneByContext(ctx, tx.SelectBuilder(options).Where(sq.Eq{"uuid": uuid.String()}).Where(sq.Eq{"revision": revision}))
+
+		if err != nil {
+			return err
+		}
+
+		if saved == nil {
+			return NotFoundError
+		}
+
+		node = current
+		node.Data = saved.Data
+		node.Meta = saved.Meta
+		node.Name = saved.Name
+		node.Slug = saved.Slug
+		node.Status = saved.Status
+		node.Weight = saved.Weight
+
+		if ok, errors := tx.ValidateContext(ctx, node); !ok {
+			return &ValidationErrors{Errors: errors}
+		}
+
+		if _, err := tx.SaveContext(ctx, node, true); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Type:        node.Type,
+			Action:      "Restore",
+			Subject:     node.Uuid.CleanString(),
+			Revision:    node.Revision,
+			Date:        node.UpdatedAt,
+			Name:        node.Name,
+			Extra:       strconv.Itoa(revision),
+			NewRevision: true,
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	return node, err
+}
+
+// Save the soft deleted node as a new revision with the deleted flag unset
+func undeleteNode(ctx context.Context, m NodeManager, prefix string, node *Node) (*Node, error) {
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		node.Deleted = false
+		node.UpdatedAt = time.Now()
+
+		if _, err := tx.SaveContext(ctx, node, true); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Type:        node.Type,
+			Action:      "Undelete",
+			Subject:     node.Uuid.CleanString(),
+			Revision:    node.Revision,
+			Date:        node.UpdatedAt,
+			Name:        node.Name,
+			NewRevision: true,
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	return node, err
+}
+
+// Remove the data stored by the handlers for the purged nodes, the first error is
+// returned once all the nodes are processed.
+func purgeHandlers(handlers Handlers, nodes []*Node) error {
+	var first error
+
+	for _, node := range nodes {
+		h, ok := handlers.Get(node).(HandlerPurge)
+
+		if !ok {
+			continue
+		}
+
+		if err := h.Purge(node); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
 }
@@ -0,0 +1,52 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// the columns the nodes can be grouped by
+	aggregateColumns = map[string]bool{
+		"type": true, "name": true, "slug": true, "status": true, "enabled": true, "deleted": true,
+		"revision": true, "version": true, "weight": true, "created_by": true, "updated_by": true,
+		"parent_uuid": true, "set_uuid": true, "source": true,
+	}
+
+	rexAggregatePath = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+)
+
+// AggregateValue is the number of nodes sharing the same value, the value is
+// the text representation of the column or of the json path.
+type AggregateValue struct {
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// Parse an aggregate field, the field is a column (ie, type, status) or a path
+// inside the data or the meta (ie, data.author.name). The path's elements are
+// only made of letters, digits and underscores so they can be used in a query.
+func parseAggregateField(field string) (string, []string, error) {
+	if aggregateColumns[field] {
+		return field, nil, nil
+	}
+
+	parts := strings.Split(field, ".")
+
+	if len(parts) < 2 || (parts[0] != "data" && parts[0] != "meta") {
+		return "", nil, InvalidFieldError
+	}
+
+	for _, p := range parts[1:] {
+		if !rexAggregatePath.MatchString(p) {
+			return "", nil, InvalidFieldError
+		}
+	}
+
+	return parts[0], parts[1:], nil
+}
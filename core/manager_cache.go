@@ -0,0 +1,407 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	pq "github.com/lib/pq"
+	"sync"
+	"time"
+)
+
+// CacheStats are the statistics of a CachedNodeManager since its creation
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+type cacheItem struct {
+	key       string
+	uuid      string
+	row       *memoryRow // nil if no node matches
+	expiresAt time.Time
+}
+
+// CachedNodeManager is a NodeManager decorator keeping the nodes loaded by Find
+// and FindOneBy in a LRU cache, the entries expire after the TTL. The cache is
+// invalidated by the events of the <prefix>_manager_action channel, so the
+// instances sharing a database stay coherent, and by the calls altering the nodes
+// on the decorator. The read-your-writes contexts bypass the cache.
+type CachedNodeManager struct {
+	NodeManager
+
+	Handlers Handlers
+	Size     int
+	TTL      time.Duration
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	stats CacheStats
+	// incremented by the invalidations, a node loaded before an invalidation is not cached
+	generation uint64
+}
+
+func NewCachedNodeManager(manager NodeManager, handlers Handlers, size int, ttl time.Duration) *CachedNodeManager {
+	return &CachedNodeManager{
+		NodeManager: manager,
+		Handlers:    handlers,
+		Size:        size,
+		TTL:         ttl,
+		items:       make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// Return the statistics of the cache
+func (m *CachedNodeManager) Stats() CacheStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats := m.stats
+	stats.Size = m.lru.Len()
+
+	return stats
+}
+
+// Remove all the entries
+func (m *CachedNodeManager) Clear() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.items = make(map[string]*list.Element)
+	m.lru.Init()
+	m.generation++
+}
+
+// Handle the events of the <prefix>_manager_action channel: the entry of the
+// subject is removed and the entries of the queries are cleared as the event
+// might alter their result. The events altering other nodes than the subject
+// (ie, the descendants of a moved or removed node) clear the cache.
+func (m *CachedNodeManager) HandleNotification(notification *pq.Notification) (int, error) {
+	event := CreateModelEvent(notification)
+
+	switch event.Action {
+	case "Link", "Unlink":
+		// the relations are not cached
+	case "Create", "Update", "Restore", "Undelete", "Copy", "Purge":
+		m.invalidate(event.Subject)
+	default:
+		m.Clear()
+	}
+
+	return PubSubListenContinue, nil
+}
+
+// remove the entry of the node and the entries of the queries
+func (m *CachedNodeManager) invalidate(uuid string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.generation++
+
+	for e := m.lru.Front(); e != nil; {
+		next := e.Next()
+
+		if item := e.Value.(*cacheItem); item.uuid == "" || item.uuid == uuid {
+			m.lru.Remove(e)
+			delete(m.items, item.key)
+		}
+
+		e = next
+	}
+}
+
+// return the cached node, the second value is false if the key is not cached,
+// the current generation is returned on a miss
+func (m *CachedNodeManager) get(key string) (*Node, bool, uint64, error) {
+	m.lock.Lock()
+
+	e, ok := m.items[key]
+
+	if ok && time.Now().After(e.Value.(*cacheItem).expiresAt) {
+		m.lru.Remove(e)
+		delete(m.items, key)
+
+		ok = false
+	}
+
+	if !ok {
+		m.stats.Misses++
+		generation := m.generation
+		m.lock.Unlock()
+
+		return nil, false, generation, nil
+	}
+
+	m.stats.Hits++
+	m.lru.MoveToFront(e)
+
+	row := e.Value.(*cacheItem).row
+
+	m.lock.Unlock()
+
+	if row == nil {
+		return nil, true, 0, nil
+	}
+
+	// the cached node is never returned, so the callers can alter the node
+	node := &Node{}
+	*node = *row.node
+
+	node.Parents = make([]Reference, len(row.node.Parents))
+	copy(node.Parents, row.node.Parents)
+
+	if err := loadNode(m.Handlers.Get(node), row.data, row.meta, node); err != nil {
+		return nil, false, 0, &DecodeError{Uuid: node.Uuid, Err: err}
+	}
+
+	return node, true, 0, nil
+}
+
+func (m *CachedNodeManager) set(key string, uuid string, node *Node, generation uint64) {
+	item := &cacheItem{
+		key:       key,
+		uuid:      uuid,
+		expiresAt: time.Now().Add(m.TTL),
+	}
+
+	if node != nil {
+		item.row = &memoryRow{
+			node: &Node{},
+			data: InterfaceToJsonMessage(node.Type, node.Data),
+			meta: InterfaceToJsonMessage(node.Type, node.Meta),
+		}
+
+		*item.row.node = *node
+		item.row.node.Data = nil
+		item.row.node.Meta = nil
+		item.row.node.Parents = make([]Reference, len(node.Parents))
+		copy(item.row.node.Parents, node.Parents)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if generation != m.generation {
+		return
+	}
+
+	if e, ok := m.items[key]; ok {
+		m.lru.Remove(e)
+	}
+
+	m.items[key] = m.lru.PushFront(item)
+
+	for m.Size > 0 && m.lru.Len() > m.Size {
+		e := m.lru.Back()
+
+		m.lru.Remove(e)
+		delete(m.items, e.Value.(*cacheItem).key)
+
+		m.stats.Evictions++
+	}
+}
+
+// return the node from the cache or load it with the function
+func (m *CachedNodeManager) load(ctx context.Context, key string, uuid string, f func() (*Node, error)) (*Node, error) {
+	if ReadYourWrites(ctx) {
+		return f()
+	}
+
+	node, ok, generation, err := m.get(key)
+
+	if ok || err != nil {
+		return node, err
+	}
+
+	if node, err = f(); err != nil {
+		return nil, err
+	}
+
+	m.set(key, uuid, node, generation)
+
+	return node, nil
+}
+
+func (m *CachedNodeManager) Find(uuid Reference) *Node {
+	node, err := m.FindContext(context.Background(), uuid)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *CachedNodeManager) FindContext(ctx context.Context, uuid Reference) (*Node, error) {
+	return m.load(ctx, "uuid:"+uuid.CleanString(), uuid.CleanString(), func() (*Node, error) {
+		return m.NodeManager.FindContext(ctx, uuid)
+	})
+}
+
+func (m *CachedNodeManager) FindOneBy(query sq.SelectBuilder) *Node {
+	node, err := m.FindOneByContext(context.Background(), query)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *CachedNodeManager) FindOneByContext(ctx context.Context, query sq.SelectBuilder) (*Node, error) {
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, _ := json.Marshal(args)
+
+	return m.load(ctx, fmt.Sprintf("query:%s:%s", rawSql, key), "", func() (*Node, error) {
+		return m.NodeManager.FindOneByContext(ctx, query)
+	})
+}
+
+func (m *CachedNodeManager) Save(node *Node, revision bool) (*Node, error) {
+	return m.SaveContext(context.Background(), node, revision)
+}
+
+func (m *CachedNodeManager) SaveContext(ctx context.Context, node *Node, revision bool) (*Node, error) {
+	node, err := m.NodeManager.SaveContext(ctx, node, revision)
+
+	m.invalidate(node.Uuid.CleanString())
+
+	return node, err
+}
+
+func (m *CachedNodeManager) RemoveOne(node *Node) (*Node, error) {
+	return m.RemoveOneContext(context.Background(), node)
+}
+
+func (m *CachedNodeManager) RemoveOneContext(ctx context.Context, node *Node) (*Node, error) {
+	node, err := m.NodeManager.RemoveOneContext(ctx, node)
+
+	// the referencing nodes might be removed or detached too
+	m.Clear()
+
+	return node, err
+}
+
+func (m *CachedNodeManager) Remove(query sq.SelectBuilder) error {
+	return m.RemoveContext(context.Background(), query)
+}
+
+func (m *CachedNodeManager) RemoveContext(ctx context.Context, query sq.SelectBuilder) error {
+	err := m.NodeManager.RemoveContext(ctx, query)
+
+	m.Clear()
+
+	return err
+}
+
+func (m *CachedNodeManager) Move(uuid, parent Reference) (int64, error) {
+	return m.MoveContext(context.Background(), uuid, parent)
+}
+
+// the parents of the descendants are altered too
+func (m *CachedNodeManager) MoveContext(ctx context.Context, uuid, parent Reference) (int64, error) {
+	affected, err := m.NodeManager.MoveContext(ctx, uuid, parent)
+
+	m.Clear()
+
+	return affected, err
+}
+
+func (m *CachedNodeManager) Copy(uuid, parent Reference, deep bool) (*Node, error) {
+	return m.CopyContext(context.Background(), uuid, parent, deep)
+}
+
+func (m *CachedNodeManager) CopyContext(ctx context.Context, uuid, parent Reference, deep bool) (*Node, error) {
+	node, err := m.NodeManager.CopyContext(ctx, uuid, parent, deep)
+
+	m.Clear()
+
+	return node, err
+}
+
+func (m *CachedNodeManager) Reorder(parent Reference, uuids []Reference) (*list.List, error) {
+	return m.ReorderContext(context.Background(), parent, uuids)
+}
+
+func (m *CachedNodeManager) ReorderContext(ctx context.Context, parent Reference, uuids []Reference) (*list.List, error) {
+	nodes, err := m.NodeManager.ReorderContext(ctx, parent, uuids)
+
+	m.Clear()
+
+	return nodes, err
+}
+
+func (m *CachedNodeManager) Restore(uuid Reference, revision int) (*Node, error) {
+	return m.RestoreContext(context.Background(), uuid, revision)
+}
+
+func (m *CachedNodeManager) RestoreContext(ctx context.Context, uuid Reference, revision int) (*Node, error) {
+	node, err := m.NodeManager.RestoreContext(ctx, uuid, revision)
+
+	m.invalidate(uuid.CleanString())
+
+	return node, err
+}
+
+func (m *CachedNodeManager) Undelete(node *Node) (*Node, error) {
+	return m.UndeleteContext(context.Background(), node)
+}
+
+func (m *CachedNodeManager) UndeleteContext(ctx context.Context, node *Node) (*Node, error) {
+	uuid := node.Uuid.CleanString()
+
+	node, err := m.NodeManager.UndeleteContext(ctx, node)
+
+	m.invalidate(uuid)
+
+	return node, err
+}
+
+func (m *CachedNodeManager) Purge(query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	return m.PurgeContext(context.Background(), query, options)
+}
+
+func (m *CachedNodeManager) PurgeContext(ctx context.Context, query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	purged, err := m.NodeManager.PurgeContext(ctx, query, options)
+
+	m.Clear()
+
+	return purged, err
+}
+
+func (m *CachedNodeManager) MigrateNodes(query sq.SelectBuilder) (int64, error) {
+	return m.MigrateNodesContext(context.Background(), query)
+}
+
+func (m *CachedNodeManager) MigrateNodesContext(ctx context.Context, query sq.SelectBuilder) (int64, error) {
+	migrated, err := m.NodeManager.MigrateNodesContext(ctx, query)
+
+	m.Clear()
+
+	return migrated, err
+}
+
+func (m *CachedNodeManager) Transaction(f func(tx NodeManager) error) error {
+	return m.TransactionContext(context.Background(), f)
+}
+
+// the transaction's manager is not cached, so the cache is cleared once done
+func (m *CachedNodeManager) TransactionContext(ctx context.Context, f func(tx NodeManager) error) error {
+	err := m.NodeManager.TransactionContext(ctx, f)
+
+	m.Clear()
+
+	return err
+}
@@ -0,0 +1,211 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	pq "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getCachedManager(size int, ttl time.Duration) (*CachedNodeManager, *InMemoryNodeManager) {
+	m := getMemoryManager()
+
+	return NewCachedNodeManager(m, m.Handlers, size, ttl), m
+}
+
+func saveUser(m NodeManager, name string) *Node {
+	node := m.NewNode("core.user")
+	node.Name = name
+	node.Data.(*User).Username = name
+
+	m.Save(node, false)
+
+	return node
+}
+
+func getNotification(action string, subject Reference) *pq.Notification {
+	data, _ := json.Marshal(&ModelEvent{Action: action, Subject: subject.CleanString()})
+
+	return &pq.Notification{Channel: "test_manager_action", Extra: string(data)}
+}
+
+func Test_CachedNodeManager_Find(t *testing.T) {
+	c, m := getCachedManager(10, time.Minute)
+	node := saveUser(m, "user-a")
+
+	found := c.Find(node.Uuid)
+
+	assert.Equal(t, "user-a", found.Name)
+	assert.Equal(t, CacheStats{Misses: 1, Size: 1}, c.Stats())
+
+	// the returned node is a copy
+	found.Name = "altered"
+	found.Data.(*User).Username = "altered"
+
+	found = c.Find(node.Uuid)
+
+	assert.Equal(t, "user-a", found.Name)
+	assert.Equal(t, "user-a", found.Data.(*User).Username)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, c.Stats())
+
+	// the missing nodes are cached too
+	assert.Nil(t, c.Find(GetRootReference()))
+	assert.Nil(t, c.Find(GetRootReference()))
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Size: 2}, c.Stats())
+
+	// the read-your-writes contexts bypass the cache
+	found, err := c.FindContext(WithReadYourWrites(context.Background()), node.Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user-a", found.Name)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Size: 2}, c.Stats())
+}
+
+func Test_CachedNodeManager_Invalidation(t *testing.T) {
+	c, m := getCachedManager(10, time.Minute)
+	a := saveUser(m, "user-a")
+	b := saveUser(m, "user-b")
+
+	c.Find(a.Uuid)
+	c.Find(b.Uuid)
+	c.FindOneBy(c.SelectBuilder(NewSelectOptions()).Where("name = ?", "user-b"))
+
+	assert.Equal(t, 3, c.Stats().Size)
+
+	// another instance alters the node
+	a = m.Find(a.Uuid)
+	a.Name = "user-a2"
+	m.Save(a, true)
+
+	assert.Equal(t, "user-a", c.Find(a.Uuid).Name)
+
+	// the subject and the queries are removed
+	c.HandleNotification(getNotification("Update", a.Uuid))
+
+	assert.Equal(t, 1, c.Stats().Size)
+	assert.Equal(t, "user-a2", c.Find(a.Uuid).Name)
+
+	// the relations are not cached
+	c.HandleNotification(getNotification("Link", a.Uuid))
+
+	assert.Equal(t, 2, c.Stats().Size)
+
+	// a move alters the descendants
+	c.HandleNotification(getNotification("Move", b.Uuid))
+
+	assert.Equal(t, 0, c.Stats().Size)
+
+	// the saves of the decorator invalidate the node
+	b = c.Find(b.Uuid)
+	b.Name = "user-b2"
+
+	_, err := c.Save(b, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user-b2", c.Find(b.Uuid).Name)
+}
+
+func Test_CachedNodeManager_Write_Invalidation(t *testing.T) {
+	c, m := getCachedManager(10, time.Minute)
+	b := saveUser(m, "user-b")
+
+	// the restored revision must be valid
+	a := m.NewNode("core.user")
+	a.Name = "user-a"
+	a.Data.(*User).Username = "user-a"
+	a.Data.(*User).Name = "User A"
+	a.Data.(*User).Password = "secret"
+	m.Save(a, false)
+
+	// no notification is received, the decorator invalidates the nodes
+	_, err := c.RemoveOne(c.Find(a.Uuid))
+
+	assert.Nil(t, err)
+	assert.True(t, c.Find(a.Uuid).Deleted)
+
+	_, err = c.Undelete(c.Find(a.Uuid))
+
+	assert.Nil(t, err)
+	assert.False(t, c.Find(a.Uuid).Deleted)
+
+	_, err = c.Move(b.Uuid, a.Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, a.Uuid, c.Find(b.Uuid).ParentUuid)
+
+	_, err = c.Restore(a.Uuid, 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, c.Find(a.Uuid).Revision)
+
+	c.RemoveOne(c.Find(b.Uuid))
+
+	purged, err := c.Purge(c.SelectBuilder(NewSelectOptions()), nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Nil(t, c.Find(b.Uuid))
+
+	err = c.Transaction(func(tx NodeManager) error {
+		node := tx.Find(a.Uuid)
+		node.Name = "user-a2"
+
+		_, err := tx.Save(node, true)
+
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user-a2", c.Find(a.Uuid).Name)
+}
+
+func Test_CachedNodeManager_FindOneBy(t *testing.T) {
+	c, m := getCachedManager(10, time.Minute)
+	saveUser(m, "user-a")
+	saveUser(m, "user-b")
+
+	query := c.SelectBuilder(NewSelectOptions()).Where("name = ?", "user-b")
+
+	assert.Equal(t, "user-b", c.FindOneBy(query).Name)
+	assert.Equal(t, "user-b", c.FindOneBy(query).Name)
+	assert.Equal(t, "user-a", c.FindOneBy(c.SelectBuilder(NewSelectOptions()).Where("name = ?", "user-a")).Name)
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 2}, c.Stats())
+}
+
+func Test_CachedNodeManager_Eviction(t *testing.T) {
+	c, m := getCachedManager(2, time.Minute)
+	a := saveUser(m, "user-a")
+	b := saveUser(m, "user-b")
+	d := saveUser(m, "user-d")
+
+	c.Find(a.Uuid)
+	c.Find(b.Uuid)
+	c.Find(a.Uuid) // b is the least recently used
+	c.Find(d.Uuid)
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}, c.Stats())
+
+	c.Find(a.Uuid)
+	c.Find(b.Uuid)
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}, c.Stats())
+}
+
+func Test_CachedNodeManager_TTL(t *testing.T) {
+	c, m := getCachedManager(10, time.Nanosecond)
+	a := saveUser(m, "user-a")
+
+	c.Find(a.Uuid)
+	time.Sleep(time.Millisecond)
+	c.Find(a.Uuid)
+
+	assert.Equal(t, CacheStats{Misses: 2, Size: 1}, c.Stats())
+}
@@ -0,0 +1,1365 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	"github.com/twinj/uuid"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A stored row, the Data and Meta values are kept as raw json so each
+// read hydrates a fresh node like the PgNodeManager does.
+type memoryRow struct {
+	node *Node
+	data json.RawMessage
+	meta json.RawMessage
+}
+
+// The tables shared by a manager and its transactions
+type memoryStore struct {
+	lock      sync.RWMutex
+	tables    map[string][]*memoryRow
+	sequences map[string]int
+	relations []*Relation
+}
+
+// The changes done inside a transaction, the undo functions are played in
+// reverse order on rollback and the notifications are sent on commit.
+type memoryJournal struct {
+	undo          []func()
+	notifications [][2]string
+}
+
+// InMemoryNodeManager is a NodeManager storing the nodes and the related audit
+// trail in memory. It can be used for testing purpose or for embedded usage, the
+// notifications are dispatched to the Subscriber (if any) without PostgreSQL.
+//
+// Please note, the changes done inside a transaction are visible to the other
+// readers before the commit.
+type InMemoryNodeManager struct {
+	Logger     *log.Logger
+	Handlers   Handlers
+	Subscriber *Subscriber
+	ReadOnly   bool
+	Prefix     string
+	// the referential integrity rules of the reference fields
+	Integrity IntegrityRules
+
+	initLock sync.Mutex
+	store    *memoryStore
+	journal  *memoryJournal
+}
+
+func (m *InMemoryNodeManager) SelectBuilder(options *SelectOptions) sq.SelectBuilder {
+	return sq.
+		Select(options.SelectClause).
+		From(m.Prefix + "_" + options.TableSuffix).
+		PlaceholderFormat(sq.Dollar)
+}
+
+func (m *InMemoryNodeManager) Notify(channel string, payload string) {
+	PanicOnError(m.NotifyContext(context.Background(), channel, payload))
+}
+
+// The notifications are not sent if the context is done
+func (m *InMemoryNodeManager) NotifyContext(ctx context.Context, channel string, payload string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if m.journal != nil {
+		m.journal.notifications = append(m.journal.notifications, [2]string{channel, payload})
+
+		return nil
+	}
+
+	if m.Subscriber != nil {
+		m.Subscriber.Publish(channel, payload)
+	}
+
+	return nil
+}
+
+func (m *InMemoryNodeManager) NewNode(t string) *Node {
+	return m.Handlers.NewNode(t)
+}
+
+// Remove all stored rows, the sequences are restarted
+func (m *InMemoryNodeManager) Reset() {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.tables = map[string][]*memoryRow{
+		m.Prefix + "_nodes":       make([]*memoryRow, 0),
+		m.Prefix + "_nodes_audit": make([]*memoryRow, 0),
+	}
+
+	store.sequences = make(map[string]int)
+	store.relations = make([]*Relation, 0)
+}
+
+func (m *InMemoryNodeManager) init() *memoryStore {
+	m.initLock.Lock()
+	defer m.initLock.Unlock()
+
+	if m.store == nil {
+		m.store = &memoryStore{
+			tables: map[string][]*memoryRow{
+				m.Prefix + "_nodes":       make([]*memoryRow, 0),
+				m.Prefix + "_nodes_audit": make([]*memoryRow, 0),
+			},
+			sequences: make(map[string]int),
+			relations: make([]*Relation, 0),
+		}
+	}
+
+	return m.store
+}
+
+// Append a row to the table, the caller must hold the store lock
+func (m *InMemoryNodeManager) appendRow(store *memoryStore, table string, row *memoryRow) {
+	store.tables[table] = append(store.tables[table], row)
+
+	if m.journal != nil {
+		m.journal.undo = append(m.journal.undo, func() {
+			m.swapRow(store, table, row, nil)
+		})
+	}
+}
+
+// Replace the row at the given position, the caller must hold the store lock
+func (m *InMemoryNodeManager) replaceRow(store *memoryStore, table string, pos int, row *memoryRow) {
+	previous := store.tables[table][pos]
+	store.tables[table][pos] = row
+
+	if m.journal != nil {
+		m.journal.undo = append(m.journal.undo, func() {
+			m.swapRow(store, table, row, previous)
+		})
+	}
+}
+
+// Swap the row with a new one, or delete it if the new one is nil. The position
+// is not used as the rows might have been altered since the change.
+func (m *InMemoryNodeManager) swapRow(store *memoryStore, table string, row *memoryRow, with *memoryRow) {
+	rows := store.tables[table]
+
+	for pos, r := range rows {
+		if r != row {
+			continue
+		}
+
+		if with == nil {
+			store.tables[table] = append(rows[:pos:pos], rows[pos+1:]...)
+		} else {
+			rows[pos] = with
+		}
+
+		return
+	}
+}
+
+// Run the function inside a transaction, the changes are reverted if the function
+// returns an error or panics. A transaction started from a transaction joins the
+// current one.
+func (m *InMemoryNodeManager) Transaction(f func(tx NodeManager) error) error {
+	return m.TransactionContext(context.Background(), f)
+}
+
+// The transaction is rolled back if the context is done before the commit
+func (m *InMemoryNodeManager) TransactionContext(ctx context.Context, f func(tx NodeManager) error) (err error) {
+	if m.journal != nil {
+		return f(m)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &InMemoryNodeManager{
+		Logger:     m.Logger,
+		Handlers:   m.Handlers,
+		Subscriber: m.Subscriber,
+		ReadOnly:   m.ReadOnly,
+		Prefix:     m.Prefix,
+		Integrity:  m.Integrity,
+		store:      m.init(),
+		journal:    &memoryJournal{},
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollback()
+
+			panic(r)
+		}
+	}()
+
+	if err = f(tx); err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		tx.rollback()
+
+		return err
+	}
+
+	for _, n := range tx.journal.notifications {
+		m.Notify(n[0], n[1])
+	}
+
+	return nil
+}
+
+func (m *InMemoryNodeManager) rollback() {
+	m.store.lock.Lock()
+	defer m.store.lock.Unlock()
+
+	for i := len(m.journal.undo) - 1; i >= 0; i-- {
+		m.journal.undo[i]()
+	}
+
+	m.journal.undo = nil
+	m.journal.notifications = nil
+}
+
+func (m *InMemoryNodeManager) FindBy(query sq.SelectBuilder, offset uint64, limit uint64) *list.List {
+	list, err := m.FindByContext(context.Background(), query, offset, limit)
+
+	PanicOnError(err)
+
+	return list
+}
+
+func (m *InMemoryNodeManager) FindByContext(ctx context.Context, query sq.SelectBuilder, offset uint64, limit uint64) (*list.List, error) {
+	query = query.Limit(limit).Offset(offset)
+
+	rows, err := m.selectRows(query)
+
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if m.Logger != nil {
+			rawSql, _, _ := query.ToSql()
+
+			m.Logger.Printf("[MemoryNode] Error while runing the request: `%s`, %s ", rawSql, err)
+		}
+
+		return nil, err
+	}
+
+	list := list.New()
+
+	for _, row := range rows {
+		node, err := m.hydrate(row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list.PushBack(node)
+	}
+
+	return list, nil
+}
+
+func (m *InMemoryNodeManager) FindOneBy(query sq.SelectBuilder) *Node {
+	node, err := m.FindOneByContext(context.Background(), query)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *InMemoryNodeManager) FindOneByContext(ctx context.Context, query sq.SelectBuilder) (*Node, error) {
+	list, err := m.FindByContext(ctx, query, 0, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if list.Len() == 1 {
+		return list.Front().Value.(*Node), nil
+	}
+
+	return nil, nil
+}
+
+func (m *InMemoryNodeManager) Find(uuid Reference) *Node {
+	node, err := m.FindContext(context.Background(), uuid)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *InMemoryNodeManager) FindContext(ctx context.Context, uuid Reference) (*Node, error) {
+	return m.FindOneByContext(ctx, m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": uuid.String()}))
+}
+
+func (m *InMemoryNodeManager) Iterate(query sq.SelectBuilder) (NodeIterator, error) {
+	return m.IterateContext(context.Background(), query)
+}
+
+// The matching rows are selected once, the nodes are hydrated while iterating
+func (m *InMemoryNodeManager) IterateContext(ctx context.Context, query sq.SelectBuilder) (NodeIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.selectRows(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &memoryNodeIterator{ctx: ctx, manager: m, rows: rows}, nil
+}
+
+type memoryNodeIterator struct {
+	ctx     context.Context
+	manager *InMemoryNodeManager
+	rows    []*memoryRow
+	node    *Node
+	err     error
+}
+
+func (it *memoryNodeIterator) Next() bool {
+	if it.err != nil || len(it.rows) == 0 {
+		return false
+	}
+
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+
+	it.node, it.err = it.manager.hydrate(it.rows[0])
+	it.rows = it.rows[1:]
+
+	return it.err == nil
+}
+
+func (it *memoryNodeIterator) Node() *Node {
+	return it.node
+}
+
+func (it *memoryNodeIterator) Err() error {
+	return it.err
+}
+
+func (it *memoryNodeIterator) Close() error {
+	it.rows = nil
+
+	return nil
+}
+
+func (m *InMemoryNodeManager) Count(query sq.SelectBuilder) (uint64, error) {
+	return m.CountContext(context.Background(), query)
+}
+
+func (m *InMemoryNodeManager) CountContext(ctx context.Context, query sq.SelectBuilder) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	rows, err := m.selectRows(query)
+
+	return uint64(len(rows)), err
+}
+
+func (m *InMemoryNodeManager) Aggregate(query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	return m.AggregateContext(context.Background(), query, field, limit)
+}
+
+func (m *InMemoryNodeManager) AggregateContext(ctx context.Context, query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	column, path, err := parseAggregateField(field)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.selectRows(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]*AggregateValue)
+	values := make([]*AggregateValue, 0)
+
+	for _, row := range rows {
+		value, ok, err := row.aggregateValue(column, path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if _, found := counts[value]; !found {
+			counts[value] = &AggregateValue{Value: value}
+			values = append(values, counts[value])
+		}
+
+		counts[value].Count++
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+
+		return values[i].Value < values[j].Value
+	})
+
+	if limit > 0 && uint64(len(values)) > limit {
+		values = values[:limit]
+	}
+
+	return values, nil
+}
+
+func (m *InMemoryNodeManager) selectRows(query sq.SelectBuilder) ([]*memoryRow, error) {
+	q, err := parseMemoryQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store := m.init()
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	rows, ok := store.tables[q.table]
+
+	if !ok {
+		return nil, fmt.Errorf("relation \"%s\" does not exist", q.table)
+	}
+
+	return q.filter(rows)
+}
+
+func (m *InMemoryNodeManager) hydrate(row *memoryRow) (*Node, error) {
+	node := &Node{}
+	*node = *row.node
+
+	node.Parents = make([]Reference, len(row.node.Parents))
+	copy(node.Parents, row.node.Parents)
+
+	if err := loadNode(m.Handlers.Get(node), row.data, row.meta, node); err != nil {
+		return nil, &DecodeError{Uuid: node.Uuid, Err: err}
+	}
+
+	return node, nil
+}
+
+func (m *InMemoryNodeManager) Remove(query sq.SelectBuilder) error {
+	return m.RemoveContext(context.Background(), query)
+}
+
+func (m *InMemoryNodeManager) RemoveContext(ctx context.Context, query sq.SelectBuilder) error {
+	query = query.Where("deleted != ?", true)
+
+	now := time.Now()
+
+	it, err := m.IterateContext(ctx, query)
+
+	if err != nil {
+		return err
+	}
+
+	defer it.Close()
+
+	for it.Next() {
+		node := it.Node()
+		node.Deleted = true
+		node.UpdatedAt = now
+
+		if _, err := m.SaveContext(ctx, node, false); err != nil {
+			return err
+		}
+
+		err := m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+			Type:     node.Type,
+			Name:     node.Name,
+			Action:   "SoftDelete",
+			Subject:  node.Uuid.CleanString(),
+			Revision: node.Revision,
+			Date:     node.UpdatedAt,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if m.Logger != nil {
+			m.Logger.Printf("[MemoryNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+		}
+	}
+
+	return it.Err()
+}
+
+func (m *InMemoryNodeManager) RemoveOne(node *Node) (*Node, error) {
+	return m.RemoveOneContext(context.Background(), node)
+}
+
+// The delete rules of the node's relations and the integrity rules are applied
+// in the same transaction
+func (m *InMemoryNodeManager) RemoveOneContext(ctx context.Context, node *Node) (*Node, error) {
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		if err := restrictReferences(ctx, tx, m.Integrity, node); err != nil {
+			return err
+		}
+
+		node.UpdatedAt = time.Now()
+		node.Deleted = true
+
+		if m.Logger != nil {
+			m.Logger.Printf("[MemoryNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+		}
+
+		err := tx.(*InMemoryNodeManager).sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+			Type:     node.Type,
+			Action:   "SoftDelete",
+			Subject:  node.Uuid.CleanString(),
+			Revision: node.Revision,
+			Date:     node.UpdatedAt,
+			Name:     node.Name,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.SaveContext(ctx, node, true); err != nil {
+			return err
+		}
+
+		if err := removeRelations(ctx, tx, node); err != nil {
+			return err
+		}
+
+		return removeReferences(ctx, tx, m.Integrity, node, func(tx NodeManager, node *Node) error {
+			return tx.(*InMemoryNodeManager).detach(node)
+		})
+	})
+
+	return node, err
+}
+
+func (m *InMemoryNodeManager) newRow(node *Node) *memoryRow {
+	row := &memoryRow{
+		node: &Node{},
+		data: InterfaceToJsonMessage(node.Type, node.Data),
+		meta: InterfaceToJsonMessage(node.Type, node.Meta),
+	}
+
+	*row.node = *node
+	row.node.Data = nil
+	row.node.Meta = nil
+	row.node.Parents = make([]Reference, len(node.Parents))
+	copy(row.node.Parents, node.Parents)
+
+	return row
+}
+
+// check the unique constraints defined on the nodes table
+func (m *InMemoryNodeManager) checkConstraints(store *memoryStore, table string, row *memoryRow) error {
+	if table != m.Prefix+"_nodes" {
+		return nil
+	}
+
+	for _, r := range store.tables[table] {
+		if r.node.Id == row.node.Id {
+			continue
+		}
+
+		if r.node.Revision != row.node.Revision {
+			continue
+		}
+
+		if r.node.Uuid.CleanString() == row.node.Uuid.CleanString() {
+			return m.constraintError(m.Prefix + "_uuid")
+		}
+
+		if r.node.ParentUuid.CleanString() == row.node.ParentUuid.CleanString() && r.node.Slug == row.node.Slug {
+			return m.constraintError(m.Prefix + "_slug")
+		}
+	}
+
+	return nil
+}
+
+func (m *InMemoryNodeManager) constraintError(name string) error {
+	return &ConstraintError{
+		Constraint: name,
+		Err:        fmt.Errorf("duplicate key value violates unique constraint \"%s\"", name),
+	}
+}
+
+func (m *InMemoryNodeManager) insertNode(node *Node, table string) (*Node, error) {
+	if node.Uuid == GetEmptyReference() {
+		node.Uuid = GetReference(uuid.NewV4())
+	}
+
+	if node.Slug == "" {
+		node.Slug = node.Uuid.String()
+	}
+
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	row := m.newRow(node)
+	row.node.Id = store.sequences[table] + 1
+
+	if err := m.checkConstraints(store, table, row); err != nil {
+		return node, err
+	}
+
+	// like a sequence, the value is not reverted on rollback
+	store.sequences[table]++
+
+	m.appendRow(store, table, row)
+
+	node.Id = row.node.Id
+
+	return node, nil
+}
+
+func (m *InMemoryNodeManager) updateNode(node *Node, table string) (*Node, error) {
+
+	PanicIf(node.Id == 0, "Cannot update node without id")
+
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for pos, saved := range store.tables[table] {
+		if saved.node.Id != node.Id {
+			continue
+		}
+
+		row := m.newRow(node)
+
+		// the parents are only altered by the Move function
+		row.node.ParentUuid = saved.node.ParentUuid
+		row.node.Parents = saved.node.Parents
+
+		if err := m.checkConstraints(store, table, row); err != nil {
+			return node, err
+		}
+
+		m.replaceRow(store, table, pos, row)
+
+		return node, nil
+	}
+
+	return node, errors.New("Zero affected rows for current node")
+}
+
+func (m *InMemoryNodeManager) Move(uuid, parentUuid Reference) (int64, error) {
+	return m.MoveContext(context.Background(), uuid, parentUuid)
+}
+
+func (m *InMemoryNodeManager) MoveContext(ctx context.Context, uuid, parentUuid Reference) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if !m.move(uuid, parentUuid) {
+		return 0, nil
+	}
+
+	return 1, m.sendNotification(ctx, m.Prefix+"_manager_action", newMoveEvent(uuid, parentUuid))
+}
+
+// move the node and update the parents of the subtree, false is returned if the
+// node cannot be moved
+func (m *InMemoryNodeManager) move(uuid, parentUuid Reference) bool {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	table := m.Prefix + "_nodes"
+
+	var node, parent *memoryRow
+
+	for _, row := range store.tables[table] {
+		if row.node.Uuid.CleanString() == uuid.CleanString() {
+			node = row
+		}
+
+		if row.node.Uuid.CleanString() == parentUuid.CleanString() {
+			parent = row
+		}
+	}
+
+	if node == nil || parent == nil {
+		return false
+	}
+
+	// a node cannot be moved into one of its children
+	for _, p := range parent.node.Parents {
+		if p.CleanString() == uuid.CleanString() {
+			return false
+		}
+	}
+
+	// the rows are never altered in place, so a rollback can restore them
+	for pos, row := range store.tables[table] {
+		if row == node {
+			moved := m.copyRow(row)
+			moved.node.ParentUuid = parentUuid
+
+			m.replaceRow(store, table, pos, moved)
+		}
+	}
+
+	// recompute the parents of the subtree starting from the new parent
+	m.updateParents(store, table, parent)
+
+	return true
+}
+
+// recompute the parents of the descendants of the row, the store must be locked
+func (m *InMemoryNodeManager) updateParents(store *memoryStore, table string, row *memoryRow) {
+	for pos, child := range store.tables[table] {
+		if child == row || child.node.ParentUuid.CleanString() != row.node.Uuid.CleanString() {
+			continue
+		}
+
+		updated := m.copyRow(child)
+		updated.node.Parents = append(append(make([]Reference, 0, len(row.node.Parents)+1), row.node.Parents...), row.node.Uuid)
+
+		m.replaceRow(store, table, pos, updated)
+
+		m.updateParents(store, table, updated)
+	}
+}
+
+// detach the node from its parent, the node becomes a root node and the parents
+// of its descendants are recomputed. The audit table is not altered.
+func (m *InMemoryNodeManager) detach(node *Node) error {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	table := m.Prefix + "_nodes"
+
+	for pos, row := range store.tables[table] {
+		if row.node.Id != node.Id {
+			continue
+		}
+
+		detached := m.copyRow(row)
+		detached.node.ParentUuid = GetEmptyReference()
+		detached.node.Parents = []Reference{}
+
+		m.replaceRow(store, table, pos, detached)
+		m.updateParents(store, table, detached)
+
+		return nil
+	}
+
+	return errors.New("Zero affected rows for current node")
+}
+
+func (m *InMemoryNodeManager) copyRow(row *memoryRow) *memoryRow {
+	c := &memoryRow{
+		node: &Node{},
+		data: row.data,
+		meta: row.meta,
+	}
+
+	*c.node = *row.node
+	c.node.Parents = make([]Reference, len(row.node.Parents))
+	copy(c.node.Parents, row.node.Parents)
+
+	return c
+}
+
+func (m *InMemoryNodeManager) Save(node *Node, revision bool) (*Node, error) {
+	return m.SaveContext(context.Background(), node, revision)
+}
+
+func (m *InMemoryNodeManager) SaveContext(ctx context.Context, node *Node, revision bool) (*Node, error) {
+	if m.journal == nil {
+		// the audit row, the node and the nodes saved by the handlers are stored together
+		err := m.TransactionContext(ctx, func(tx NodeManager) error {
+			_, err := tx.SaveContext(ctx, node, revision)
+
+			return err
+		})
+
+		return node, err
+	}
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Saving uuid: %s, id: %d, type: %s, revision: %d", node.Uuid, node.Id, node.Type, node.Revision)
+	}
+
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	var err error
+
+	handler := m.Handlers.Get(node)
+
+	if node.Id == 0 {
+		if err = handlerPreInsert(ctx, handler, node, m); err != nil {
+			return node, err
+		}
+
+		if node, err = m.insertNode(node, m.Prefix+"_nodes_audit"); err != nil {
+			return node, err
+		}
+
+		node.Id = 0
+
+		if node, err = m.insertNode(node, m.Prefix+"_nodes"); err != nil {
+			return node, err
+		}
+
+		if m.Logger != nil {
+			m.Logger.Printf("[MemoryNode] Creating node uuid: %s, id: %d, type: %s, revision: %d", node.Uuid, node.Id, node.Type, node.Revision)
+		}
+
+		if err = handlerPostInsert(ctx, handler, node, m); err != nil {
+			return node, err
+		}
+
+		err = m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+			Type:        node.Type,
+			Action:      "Create",
+			Subject:     node.Uuid.CleanString(),
+			Date:        node.CreatedAt,
+			Name:        node.Name,
+			Revision:    node.Revision,
+			NewRevision: revision,
+		})
+
+		return node, err
+	}
+
+	if err = handlerPreUpdate(ctx, handler, node, m); err != nil {
+		return node, err
+	}
+
+	// 1. check if the one in the datastore is older
+	saved, err := m.FindOneByContext(ctx, m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": node.Uuid.String()}))
+
+	if err != nil {
+		return node, err
+	}
+
+	// the node has been purged or the id is not valid
+	if saved == nil {
+		return node, NotFoundError
+	}
+
+	if node.Revision != saved.Revision {
+		if m.Logger != nil {
+			m.Logger.Printf("[MemoryNode] Invalid revision for node: %s, saved rev: %d, current rev: %d", node.Uuid, saved.Revision, node.Revision)
+		}
+
+		return node, NewRevisionError(fmt.Sprintf("Invalid revision for node: %s, saved rev: %d, current rev: %d", node.Uuid, saved.Revision, node.Revision))
+	}
+
+	if revision {
+		// 2. Update the revision number
+		node.Revision++
+		node.CreatedAt = saved.CreatedAt
+		node.UpdatedAt = time.Now()
+	}
+
+	if node, err = m.updateNode(node, m.Prefix+"_nodes"); err != nil {
+		return node, err
+	}
+
+	if err = handlerPostUpdate(ctx, handler, node, m); err != nil {
+		return node, err
+	}
+
+	if revision {
+		id := node.Id
+		_, err = m.insertNode(node, m.Prefix+"_nodes_audit")
+
+		node.Id = id
+
+		if err != nil {
+			return node, err
+		}
+	}
+
+	err = m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+		Type:        node.Type,
+		Action:      "Update",
+		Subject:     node.Uuid.CleanString(),
+		Revision:    node.Revision,
+		Date:        node.UpdatedAt,
+		Name:        node.Name,
+		NewRevision: revision,
+	})
+
+	return node, err
+}
+
+func (m *InMemoryNodeManager) sendNotification(ctx context.Context, channel string, element interface{}) error {
+	data, _ := json.Marshal(element)
+
+	return m.NotifyContext(ctx, channel, string(data[:]))
+}
+
+func (m *InMemoryNodeManager) Restore(uuid Reference, revision int) (*Node, error) {
+	return m.RestoreContext(context.Background(), uuid, revision)
+}
+
+func (m *InMemoryNodeManager) RestoreContext(ctx context.Context, uuid Reference, revision int) (*Node, error) {
+	return restoreNode(ctx, m, m.Prefix, uuid, revision)
+}
+
+func (m *InMemoryNodeManager) Validate(node *Node) (bool, Errors) {
+	return m.ValidateContext(context.Background(), node)
+}
+
+func (m *InMemoryNodeManager) ValidateContext(ctx context.Context, node *Node) (bool, Errors) {
+	return validateNode(ctx, node, m, m.Handlers, m.Integrity)
+}
+
+func (m *InMemoryNodeManager) Undelete(node *Node) (*Node, error) {
+	return m.UndeleteContext(context.Background(), node)
+}
+
+func (m *InMemoryNodeManager) UndeleteContext(ctx context.Context, node *Node) (*Node, error) {
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Undelete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+	}
+
+	return undeleteNode(ctx, m, m.Prefix, node)
+}
+
+func (m *InMemoryNodeManager) MigrateNodes(query sq.SelectBuilder) (int64, error) {
+	return m.MigrateNodesContext(context.Background(), query)
+}
+
+func (m *InMemoryNodeManager) MigrateNodesContext(ctx context.Context, query sq.SelectBuilder) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	migrated, err := migrateNodes(ctx, m, m.Handlers, query, func(tx NodeManager, node *Node) error {
+		return tx.(*InMemoryNodeManager).updateVersion(node)
+	})
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] MigrateNodes: %d nodes migrated", migrated)
+	}
+
+	return migrated, err
+}
+
+// store the data, the meta and the version of the current node only, the audit
+// table is not altered
+func (m *InMemoryNodeManager) updateVersion(node *Node) error {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	table := m.Prefix + "_nodes"
+
+	for pos, row := range store.tables[table] {
+		if row.node.Id != node.Id {
+			continue
+		}
+
+		updated := m.copyRow(row)
+		updated.node.Version = node.Version
+		updated.data = InterfaceToJsonMessage(node.Type, node.Data)
+		updated.meta = InterfaceToJsonMessage(node.Type, node.Meta)
+
+		m.replaceRow(store, table, pos, updated)
+
+		return nil
+	}
+
+	return errors.New("Zero affected rows for current node")
+}
+
+func (m *InMemoryNodeManager) Purge(query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	return m.PurgeContext(context.Background(), query, options)
+}
+
+// Delete the soft deleted nodes matching the query, the handler's data are
+// removed once the transaction is committed.
+func (m *InMemoryNodeManager) PurgeContext(ctx context.Context, query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if options == nil {
+		options = &PurgeOptions{}
+	}
+
+	query = query.Where("deleted = ?", true)
+
+	purged := make([]*Node, 0)
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		mtx := tx.(*InMemoryNodeManager)
+
+		it, err := mtx.IterateContext(ctx, query)
+
+		if err != nil {
+			return err
+		}
+
+		defer it.Close()
+
+		for it.Next() {
+			node := it.Node()
+
+			if err := mtx.purge(ctx, node, options); err != nil {
+				return err
+			}
+
+			purged = append(purged, node)
+		}
+
+		return it.Err()
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if options.KeepBinaries {
+		return int64(len(purged)), nil
+	}
+
+	return int64(len(purged)), purgeHandlers(m.Handlers, purged)
+}
+
+func (m *InMemoryNodeManager) purge(ctx context.Context, node *Node, options *PurgeOptions) error {
+	store := m.init()
+
+	store.lock.Lock()
+
+	m.deleteRows(store, m.Prefix+"_nodes", func(row *memoryRow) bool {
+		return row.node.Id == node.Id
+	})
+
+	if !options.KeepAudit {
+		m.deleteRows(store, m.Prefix+"_nodes_audit", func(row *memoryRow) bool {
+			return row.node.Uuid.CleanString() == node.Uuid.CleanString()
+		})
+	}
+
+	m.deleteRelations(store, node.Uuid, GetEmptyReference(), "")
+	m.deleteRelations(store, GetEmptyReference(), node.Uuid, "")
+
+	store.lock.Unlock()
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Purge: Uuid:%+v - type: %s", node.Uuid, node.Type)
+	}
+
+	return m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+		Type:     node.Type,
+		Action:   "Purge",
+		Subject:  node.Uuid.CleanString(),
+		Revision: node.Revision,
+		Date:     time.Now(),
+		Name:     node.Name,
+	})
+}
+
+// Delete the matching rows from the table, the rows are appended back to the
+// table on rollback. The caller must hold the store lock.
+func (m *InMemoryNodeManager) deleteRows(store *memoryStore, table string, match func(row *memoryRow) bool) {
+	rows := make([]*memoryRow, 0, len(store.tables[table]))
+
+	for _, row := range store.tables[table] {
+		if !match(row) {
+			rows = append(rows, row)
+
+			continue
+		}
+
+		if m.journal != nil {
+			deleted := row
+
+			m.journal.undo = append(m.journal.undo, func() {
+				store.tables[table] = append(store.tables[table], deleted)
+			})
+		}
+	}
+
+	store.tables[table] = rows
+}
+
+func (m *InMemoryNodeManager) FindChildren(uuid Reference) (*list.List, error) {
+	return m.FindChildrenContext(context.Background(), uuid)
+}
+
+func (m *InMemoryNodeManager) FindChildrenContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return findChildren(ctx, m, uuid)
+}
+
+func (m *InMemoryNodeManager) FindDescendants(uuid Reference, depth int) (*list.List, error) {
+	return m.FindDescendantsContext(context.Background(), uuid, depth)
+}
+
+func (m *InMemoryNodeManager) FindDescendantsContext(ctx context.Context, uuid Reference, depth int) (*list.List, error) {
+	return findDescendants(ctx, m, uuid, depth)
+}
+
+func (m *InMemoryNodeManager) FindAncestors(uuid Reference) (*list.List, error) {
+	return m.FindAncestorsContext(context.Background(), uuid)
+}
+
+func (m *InMemoryNodeManager) FindAncestorsContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return findAncestors(ctx, m, uuid)
+}
+
+func (m *InMemoryNodeManager) Copy(uuid, parent Reference, deep bool) (*Node, error) {
+	return m.CopyContext(context.Background(), uuid, parent, deep)
+}
+
+func (m *InMemoryNodeManager) CopyContext(ctx context.Context, uuid, parent Reference, deep bool) (*Node, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Copy: Uuid:%+v - parent: %+v - deep: %t", uuid, parent, deep)
+	}
+
+	return copyNode(ctx, m, m.Handlers, m.Prefix, uuid, parent, deep)
+}
+
+func (m *InMemoryNodeManager) Reorder(parent Reference, uuids []Reference) (*list.List, error) {
+	return m.ReorderContext(context.Background(), parent, uuids)
+}
+
+func (m *InMemoryNodeManager) ReorderContext(ctx context.Context, parent Reference, uuids []Reference) (*list.List, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Reorder: parent: %+v - children: %d", parent, len(uuids))
+	}
+
+	return reorderChildren(ctx, m, m.Prefix, parent, uuids, func(tx NodeManager, node *Node) error {
+		return tx.(*InMemoryNodeManager).updateWeight(node)
+	})
+}
+
+// update the weight of the current node only, the audit table is not altered
+func (m *InMemoryNodeManager) updateWeight(node *Node) error {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	table := m.Prefix + "_nodes"
+
+	for pos, row := range store.tables[table] {
+		if row.node.Id != node.Id {
+			continue
+		}
+
+		updated := m.copyRow(row)
+		updated.node.Weight = node.Weight
+
+		m.replaceRow(store, table, pos, updated)
+
+		return nil
+	}
+
+	return errors.New("Zero affected rows for current node")
+}
+
+func (m *InMemoryNodeManager) Link(relation *Relation) (*Relation, error) {
+	return m.LinkContext(context.Background(), relation)
+}
+
+func (m *InMemoryNodeManager) LinkContext(ctx context.Context, relation *Relation) (*Relation, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Link: from: %+v - to: %+v - kind: %s", relation.From, relation.To, relation.Kind)
+	}
+
+	return linkNodes(ctx, m, m.Prefix, relation, func(tx NodeManager, relation *Relation) error {
+		tx.(*InMemoryNodeManager).storeRelation(relation)
+
+		return nil
+	})
+}
+
+// update the relation if it exists, append it otherwise
+func (m *InMemoryNodeManager) storeRelation(relation *Relation) {
+	store := m.init()
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	stored := &Relation{}
+	*stored = *relation
+
+	for pos, r := range store.relations {
+		if !matchRelation(r, relation.From, relation.To, relation.Kind) {
+			continue
+		}
+
+		stored.CreatedAt = r.CreatedAt
+		store.relations[pos] = stored
+
+		if m.journal != nil {
+			m.journal.undo = append(m.journal.undo, func() {
+				m.swapRelation(store, stored, r)
+			})
+		}
+
+		return
+	}
+
+	store.relations = append(store.relations, stored)
+
+	if m.journal != nil {
+		m.journal.undo = append(m.journal.undo, func() {
+			m.swapRelation(store, stored, nil)
+		})
+	}
+}
+
+// Swap the relation with a new one, or delete it if the new one is nil. The
+// caller must hold the store lock.
+func (m *InMemoryNodeManager) swapRelation(store *memoryStore, relation *Relation, with *Relation) {
+	for pos, r := range store.relations {
+		if r != relation {
+			continue
+		}
+
+		if with == nil {
+			store.relations = append(store.relations[:pos:pos], store.relations[pos+1:]...)
+		} else {
+			store.relations[pos] = with
+		}
+
+		return
+	}
+}
+
+func (m *InMemoryNodeManager) Unlink(from, to Reference, kind string) (int64, error) {
+	return m.UnlinkContext(context.Background(), from, to, kind)
+}
+
+func (m *InMemoryNodeManager) UnlinkContext(ctx context.Context, from, to Reference, kind string) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[MemoryNode] Unlink: from: %+v - to: %+v - kind: %s", from, to, kind)
+	}
+
+	return unlinkNodes(ctx, m, m.Prefix, from, to, kind, func(tx NodeManager) (int64, error) {
+		mtx := tx.(*InMemoryNodeManager)
+		store := mtx.init()
+
+		store.lock.Lock()
+		defer store.lock.Unlock()
+
+		return mtx.deleteRelations(store, from, to, kind), nil
+	})
+}
+
+// Delete the matching relations, the relations are appended back on rollback.
+// The caller must hold the store lock.
+func (m *InMemoryNodeManager) deleteRelations(store *memoryStore, from, to Reference, kind string) int64 {
+	relations := make([]*Relation, 0, len(store.relations))
+
+	var deleted int64
+
+	for _, relation := range store.relations {
+		if !matchRelation(relation, from, to, kind) {
+			relations = append(relations, relation)
+
+			continue
+		}
+
+		deleted++
+
+		if m.journal != nil {
+			r := relation
+
+			m.journal.undo = append(m.journal.undo, func() {
+				store.relations = append(store.relations, r)
+			})
+		}
+	}
+
+	store.relations = relations
+
+	return deleted
+}
+
+func (m *InMemoryNodeManager) FindRelations(from, to Reference, kind string) ([]*Relation, error) {
+	return m.FindRelationsContext(context.Background(), from, to, kind)
+}
+
+// Return the relations matching the references and the kind (an empty reference
+// or an empty kind matches all the values), ordered by kind and weight.
+func (m *InMemoryNodeManager) FindRelationsContext(ctx context.Context, from, to Reference, kind string) ([]*Relation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store := m.init()
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	relations := make([]*Relation, 0)
+
+	for _, relation := range store.relations {
+		if matchRelation(relation, from, to, kind) {
+			r := &Relation{}
+			*r = *relation
+
+			relations = append(relations, r)
+		}
+	}
+
+	sort.SliceStable(relations, func(i, j int) bool {
+		if relations[i].Kind != relations[j].Kind {
+			return relations[i].Kind < relations[j].Kind
+		}
+
+		return relations[i].Weight < relations[j].Weight
+	})
+
+	return relations, nil
+}
@@ -0,0 +1,1766 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+// The InMemoryNodeManager does not have any SQL engine, so the queries built with
+// the sq.SelectBuilder (ie, by the SearchPGSQL.BuildQuery function) are rendered
+// with ToSql() and evaluated against the stored nodes. Only the subset of the
+// PostgreSQL syntax used by gonode is supported:
+//   - SELECT ... FROM table [WHERE expr] [ORDER BY expr [ASC|DESC], ...] [LIMIT n] [OFFSET n]
+//   - AND, OR, NOT and parenthesis
+//   - =, !=, <>, <, <=, >, >=, [NOT] IN (...), IS [NOT] NULL, [NOT] LIKE, [NOT] ILIKE
+//   - x op ANY(array), x op ALL(array)
+//   - jsonb operators: ->, ->>, ?, ?|, ?&, @>
+//   - array operators: @>, <@ and the cardinality(array) function
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	"github.com/twinj/uuid"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenKeyword
+	tokenString
+	tokenNumber
+	tokenPlaceholder
+	tokenOperator
+	tokenPunct
+)
+
+var memoryKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "IS": true, "NULL": true, "ORDER": true, "BY": true, "ASC": true, "DESC": true,
+	"LIMIT": true, "OFFSET": true, "TRUE": true, "FALSE": true, "ARRAY": true, "LIKE": true,
+	"ILIKE": true, "ANY": true, "ALL": true, "NULLS": true, "FIRST": true, "LAST": true,
+}
+
+type memoryToken struct {
+	kind  int
+	value string
+	arg   int
+}
+
+// uuid columns are stored in their clean format, the type is used to normalize
+// the values provided by the query before the comparison
+type memoryUuid string
+
+// raw jsonb value (decoded with encoding/json)
+type memoryJson struct {
+	value interface{}
+}
+
+type memoryOrder struct {
+	expr memoryExpr
+	desc bool
+}
+
+type memoryQuery struct {
+	table   string
+	where   memoryExpr
+	orderBy []*memoryOrder
+	limit   int64
+	offset  int64
+}
+
+type memoryExpr func(row *memoryRow) (interface{}, error)
+
+func parseMemoryQuery(query sq.SelectBuilder) (*memoryQuery, error) {
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := tokenizeMemoryQuery(rawSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &memoryParser{tokens: tokens, args: args}
+
+	return p.parseSelect()
+}
+
+func tokenizeMemoryQuery(rawSql string) ([]*memoryToken, error) {
+	tokens := make([]*memoryToken, 0)
+
+	// with a dollar placeholder format, the ?? escape sequence is already converted to ?
+	dollar := strings.Contains(rawSql, "$1")
+	position := 0
+
+	for i := 0; i < len(rawSql); {
+		c := rawSql[i]
+
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+
+		case c == '\'':
+			value := ""
+			i++
+			for {
+				if i >= len(rawSql) {
+					return nil, errors.New("unterminated string literal")
+				}
+
+				if rawSql[i] == '\'' {
+					if i+1 < len(rawSql) && rawSql[i+1] == '\'' {
+						value += "'"
+						i += 2
+						continue
+					}
+
+					i++
+					break
+				}
+
+				value += string(rawSql[i])
+				i++
+			}
+
+			tokens = append(tokens, &memoryToken{kind: tokenString, value: value})
+
+		case c == '"':
+			end := strings.IndexByte(rawSql[i+1:], '"')
+			if end == -1 {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+
+			tokens = append(tokens, &memoryToken{kind: tokenIdent, value: rawSql[i+1 : i+1+end]})
+			i += end + 2
+
+		case c == '$' && i+1 < len(rawSql) && rawSql[i+1] >= '0' && rawSql[i+1] <= '9':
+			j := i + 1
+			for j < len(rawSql) && rawSql[j] >= '0' && rawSql[j] <= '9' {
+				j++
+			}
+
+			n, _ := strconv.Atoi(rawSql[i+1 : j])
+			tokens = append(tokens, &memoryToken{kind: tokenPlaceholder, arg: n - 1})
+			i = j
+
+		case c == '?':
+			if strings.HasPrefix(rawSql[i:], "??|") || strings.HasPrefix(rawSql[i:], "??&") {
+				tokens = append(tokens, &memoryToken{kind: tokenOperator, value: "?" + string(rawSql[i+2])})
+				i += 3
+			} else if strings.HasPrefix(rawSql[i:], "??") {
+				tokens = append(tokens, &memoryToken{kind: tokenOperator, value: "?"})
+				i += 2
+			} else if strings.HasPrefix(rawSql[i:], "?|") || strings.HasPrefix(rawSql[i:], "?&") {
+				tokens = append(tokens, &memoryToken{kind: tokenOperator, value: rawSql[i : i+2]})
+				i += 2
+			} else if dollar {
+				tokens = append(tokens, &memoryToken{kind: tokenOperator, value: "?"})
+				i++
+			} else {
+				tokens = append(tokens, &memoryToken{kind: tokenPlaceholder, arg: position})
+				position++
+				i++
+			}
+
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(rawSql) && (rawSql[j] >= '0' && rawSql[j] <= '9' || rawSql[j] == '.') {
+				j++
+			}
+
+			tokens = append(tokens, &memoryToken{kind: tokenNumber, value: rawSql[i:j]})
+			i = j
+
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(rawSql) && (rawSql[j] == '_' || rawSql[j] == '.' || unicode.IsLetter(rune(rawSql[j])) || unicode.IsDigit(rune(rawSql[j]))) {
+				j++
+			}
+
+			word := rawSql[i:j]
+			if memoryKeywords[strings.ToUpper(word)] {
+				tokens = append(tokens, &memoryToken{kind: tokenKeyword, value: strings.ToUpper(word)})
+			} else {
+				tokens = append(tokens, &memoryToken{kind: tokenIdent, value: word})
+			}
+
+			i = j
+
+		case c == '(' || c == ')' || c == ',' || c == '[' || c == ']' || c == '*' || c == ';':
+			tokens = append(tokens, &memoryToken{kind: tokenPunct, value: string(c)})
+			i++
+
+		default:
+			matched := false
+			for _, op := range []string{"->>", "->", "::", "!=", "<>", "<=", ">=", "@>", "<@", "=", "<", ">"} {
+				if strings.HasPrefix(rawSql[i:], op) {
+					tokens = append(tokens, &memoryToken{kind: tokenOperator, value: op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+
+	return append(tokens, &memoryToken{kind: tokenEOF}), nil
+}
+
+type memoryParser struct {
+	tokens []*memoryToken
+	pos    int
+	args   []interface{}
+}
+
+func (p *memoryParser) peek() *memoryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *memoryParser) next() *memoryToken {
+	t := p.tokens[p.pos]
+
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *memoryParser) is(kind int, value string) bool {
+	t := p.peek()
+
+	return t.kind == kind && t.value == value
+}
+
+func (p *memoryParser) accept(kind int, value string) bool {
+	if p.is(kind, value) {
+		p.next()
+
+		return true
+	}
+
+	return false
+}
+
+func (p *memoryParser) expect(kind int, value string) error {
+	if !p.accept(kind, value) {
+		return fmt.Errorf("expected %q near token %q", value, p.peek().value)
+	}
+
+	return nil
+}
+
+func (p *memoryParser) parseSelect() (*memoryQuery, error) {
+	query := &memoryQuery{limit: -1}
+
+	if err := p.expect(tokenKeyword, "SELECT"); err != nil {
+		return nil, err
+	}
+
+	// the selected columns are not relevant, the complete node is always returned
+	for depth := 0; !(depth == 0 && p.is(tokenKeyword, "FROM")); {
+		t := p.next()
+
+		switch {
+		case t.kind == tokenEOF:
+			return nil, errors.New("missing FROM clause")
+		case t.kind == tokenPunct && t.value == "(":
+			depth++
+		case t.kind == tokenPunct && t.value == ")":
+			depth--
+		}
+	}
+
+	p.next()
+
+	if t := p.next(); t.kind != tokenIdent {
+		return nil, errors.New("invalid table name")
+	} else {
+		query.table = t.value
+	}
+
+	var err error
+
+	if p.accept(tokenKeyword, "WHERE") {
+		if query.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept(tokenKeyword, "ORDER") {
+		if err = p.expect(tokenKeyword, "BY"); err != nil {
+			return nil, err
+		}
+
+		for {
+			order := &memoryOrder{}
+
+			if order.expr, err = p.parseOperand(); err != nil {
+				return nil, err
+			}
+
+			if p.accept(tokenKeyword, "DESC") {
+				order.desc = true
+			} else {
+				p.accept(tokenKeyword, "ASC")
+			}
+
+			query.orderBy = append(query.orderBy, order)
+
+			if !p.accept(tokenPunct, ",") {
+				break
+			}
+		}
+	}
+
+	if p.accept(tokenKeyword, "LIMIT") {
+		if query.limit, err = p.parseInteger(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept(tokenKeyword, "OFFSET") {
+		if query.offset, err = p.parseInteger(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.accept(tokenPunct, ";")
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unsupported syntax near token %q", t.value)
+	}
+
+	return query, nil
+}
+
+func (p *memoryParser) parseInteger() (int64, error) {
+	t := p.next()
+
+	var v interface{}
+	switch t.kind {
+	case tokenNumber:
+		v = t.value
+	case tokenPlaceholder:
+		v = p.arg(t.arg)
+	default:
+		return 0, fmt.Errorf("expected an integer near token %q", t.value)
+	}
+
+	return strconv.ParseInt(fmt.Sprintf("%v", v), 10, 64)
+}
+
+func (p *memoryParser) arg(pos int) interface{} {
+	if pos < 0 || pos >= len(p.args) {
+		return nil
+	}
+
+	return normalizeMemoryValue(p.args[pos])
+}
+
+func (p *memoryParser) parseOr() (memoryExpr, error) {
+	left, err := p.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokenKeyword, "OR") {
+		right, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = func(l, r memoryExpr) memoryExpr {
+			return func(row *memoryRow) (interface{}, error) {
+				a, err := l(row)
+				if err != nil {
+					return nil, err
+				}
+
+				if a == true {
+					return true, nil
+				}
+
+				b, err := r(row)
+				if err != nil {
+					return nil, err
+				}
+
+				if b == true {
+					return true, nil
+				}
+
+				if a == nil || b == nil {
+					return nil, nil
+				}
+
+				return false, nil
+			}
+		}(left, right)
+	}
+
+	return left, nil
+}
+
+func (p *memoryParser) parseAnd() (memoryExpr, error) {
+	left, err := p.parseNot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept(tokenKeyword, "AND") {
+		right, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = func(l, r memoryExpr) memoryExpr {
+			return func(row *memoryRow) (interface{}, error) {
+				a, err := l(row)
+				if err != nil {
+					return nil, err
+				}
+
+				if a == false {
+					return false, nil
+				}
+
+				b, err := r(row)
+				if err != nil {
+					return nil, err
+				}
+
+				if b == false {
+					return false, nil
+				}
+
+				if a == nil || b == nil {
+					return nil, nil
+				}
+
+				return true, nil
+			}
+		}(left, right)
+	}
+
+	return left, nil
+}
+
+func (p *memoryParser) parseNot() (memoryExpr, error) {
+	if p.accept(tokenKeyword, "NOT") {
+		expr, err := p.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return negateMemoryExpr(expr), nil
+	}
+
+	return p.parseComparison()
+}
+
+func negateMemoryExpr(expr memoryExpr) memoryExpr {
+	return func(row *memoryRow) (interface{}, error) {
+		v, err := expr(row)
+
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("argument of NOT must be type boolean, not %T", v)
+		}
+
+		return !b, nil
+	}
+}
+
+func (p *memoryParser) parseComparison() (memoryExpr, error) {
+	left, err := p.parseOperand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+
+	if t.kind == tokenKeyword && t.value == "IS" {
+		p.next()
+		not := p.accept(tokenKeyword, "NOT")
+
+		if err := p.expect(tokenKeyword, "NULL"); err != nil {
+			return nil, err
+		}
+
+		return func(row *memoryRow) (interface{}, error) {
+			v, err := left(row)
+			if err != nil {
+				return nil, err
+			}
+
+			return (v == nil) != not, nil
+		}, nil
+	}
+
+	not := false
+	if t.kind == tokenKeyword && t.value == "NOT" {
+		p.next()
+		not = true
+		t = p.peek()
+	}
+
+	if t.kind == tokenKeyword && t.value == "IN" {
+		p.next()
+
+		list, err := p.parseList("(", ")")
+		if err != nil {
+			return nil, err
+		}
+
+		expr := func(row *memoryRow) (interface{}, error) {
+			v, err := left(row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+
+			values, err := evalMemoryExprs(list, row)
+			if err != nil {
+				return nil, err
+			}
+
+			return memoryAny("=", v, values)
+		}
+
+		if not {
+			return negateMemoryExpr(expr), nil
+		}
+
+		return expr, nil
+	}
+
+	if t.kind == tokenKeyword && (t.value == "LIKE" || t.value == "ILIKE") {
+		p.next()
+
+		pattern, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		insensitive := t.value == "ILIKE"
+
+		expr := func(row *memoryRow) (interface{}, error) {
+			v, err := left(row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+
+			pv, err := pattern(row)
+			if err != nil || pv == nil {
+				return nil, err
+			}
+
+			return memoryLike(memoryText(v), memoryText(pv), insensitive), nil
+		}
+
+		if not {
+			return negateMemoryExpr(expr), nil
+		}
+
+		return expr, nil
+	}
+
+	if not {
+		return nil, fmt.Errorf("unsupported syntax near token %q", t.value)
+	}
+
+	if t.kind != tokenOperator || t.value == "->" || t.value == "->>" || t.value == "::" {
+		return left, nil
+	}
+
+	op := p.next().value
+
+	// x = ANY(array) or x <> ALL(array)
+	if p.is(tokenKeyword, "ANY") || p.is(tokenKeyword, "ALL") {
+		all := p.next().value == "ALL"
+
+		if err := p.expect(tokenPunct, "("); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokenPunct, ")"); err != nil {
+			return nil, err
+		}
+
+		return func(row *memoryRow) (interface{}, error) {
+			v, err := left(row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+
+			a, err := right(row)
+			if err != nil || a == nil {
+				return nil, err
+			}
+
+			values, ok := a.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("op ANY/ALL requires array on right side, got %T", a)
+			}
+
+			if all {
+				return memoryAll(op, v, values)
+			}
+
+			return memoryAny(op, v, values)
+		}, nil
+	}
+
+	right, err := p.parseOperand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row *memoryRow) (interface{}, error) {
+		a, err := left(row)
+		if err != nil || a == nil {
+			return nil, err
+		}
+
+		b, err := right(row)
+		if err != nil || b == nil {
+			return nil, err
+		}
+
+		return memoryOperator(op, a, b)
+	}, nil
+}
+
+func (p *memoryParser) parseList(open, close string) ([]memoryExpr, error) {
+	if err := p.expect(tokenPunct, open); err != nil {
+		return nil, err
+	}
+
+	list := make([]memoryExpr, 0)
+
+	if p.accept(tokenPunct, close) {
+		return list, nil
+	}
+
+	for {
+		expr, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, expr)
+
+		if p.accept(tokenPunct, close) {
+			return list, nil
+		}
+
+		if err := p.expect(tokenPunct, ","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *memoryParser) parseOperand() (memoryExpr, error) {
+	expr, err := p.parsePrimary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.is(tokenOperator, "->") || p.is(tokenOperator, "->>"):
+			text := p.next().value == "->>"
+
+			key, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+
+			expr = func(source, key memoryExpr, text bool) memoryExpr {
+				return func(row *memoryRow) (interface{}, error) {
+					v, err := source(row)
+					if err != nil || v == nil {
+						return nil, err
+					}
+
+					k, err := key(row)
+					if err != nil || k == nil {
+						return nil, err
+					}
+
+					return memoryJsonGet(v, k, text)
+				}
+			}(expr, key, text)
+
+		case p.is(tokenOperator, "::"):
+			// casts are ignored, the comparison functions are lenient enough
+			p.next()
+			if t := p.next(); t.kind != tokenIdent && t.kind != tokenKeyword {
+				return nil, fmt.Errorf("invalid cast near token %q", t.value)
+			}
+
+			p.accept(tokenPunct, "[")
+			p.accept(tokenPunct, "]")
+
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *memoryParser) parsePrimary() (memoryExpr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenPlaceholder:
+		v := p.arg(t.arg)
+
+		return func(row *memoryRow) (interface{}, error) {
+			return v, nil
+		}, nil
+
+	case tokenString:
+		v := t.value
+
+		return func(row *memoryRow) (interface{}, error) {
+			return v, nil
+		}, nil
+
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(row *memoryRow) (interface{}, error) {
+			return v, nil
+		}, nil
+
+	case tokenIdent:
+		if p.is(tokenPunct, "(") {
+			return p.parseFunction(t.value)
+		}
+
+		column := t.value
+		if pos := strings.LastIndex(column, "."); pos != -1 { // table alias
+			column = column[pos+1:]
+		}
+
+		if _, ok := memoryColumns[column]; !ok {
+			return nil, fmt.Errorf("column %q does not exist", t.value)
+		}
+
+		return func(row *memoryRow) (interface{}, error) {
+			return row.column(column)
+		}, nil
+
+	case tokenKeyword:
+		switch t.value {
+		case "NULL":
+			return func(row *memoryRow) (interface{}, error) {
+				return nil, nil
+			}, nil
+
+		case "TRUE", "FALSE":
+			v := t.value == "TRUE"
+
+			return func(row *memoryRow) (interface{}, error) {
+				return v, nil
+			}, nil
+
+		case "ARRAY":
+			list, err := p.parseList("[", "]")
+			if err != nil {
+				return nil, err
+			}
+
+			return func(row *memoryRow) (interface{}, error) {
+				return evalMemoryExprs(list, row)
+			}, nil
+		}
+
+	case tokenPunct:
+		if t.value == "(" {
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(tokenPunct, ")"); err != nil {
+				return nil, err
+			}
+
+			return expr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported syntax near token %q", t.value)
+}
+
+// only the functions used by gonode are supported
+func (p *memoryParser) parseFunction(name string) (memoryExpr, error) {
+	args, err := p.parseList("(", ")")
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(name) {
+	case "cardinality":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("function cardinality expects 1 argument, got %d", len(args))
+		}
+
+		return func(row *memoryRow) (interface{}, error) {
+			v, err := args[0](row)
+			if err != nil || v == nil {
+				return nil, err
+			}
+
+			values, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("function cardinality requires an array, got %T", v)
+			}
+
+			return float64(len(values)), nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("function %s does not exist", name)
+}
+
+func evalMemoryExprs(list []memoryExpr, row *memoryRow) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(list))
+
+	for _, expr := range list {
+		v, err := expr(row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if a, ok := v.([]interface{}); ok { // expanded slice from a placeholder
+			values = append(values, a...)
+		} else {
+			values = append(values, v)
+		}
+	}
+
+	return values, nil
+}
+
+// convert the arguments provided to the query builder into the values used by the evaluator
+func normalizeMemoryValue(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			v = value
+		}
+	}
+
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case Reference:
+		return memoryUuid(t.CleanString())
+	case *Reference:
+		return memoryUuid(t.CleanString())
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case []byte:
+		return string(t)
+	case []string:
+		values := make([]interface{}, len(t))
+		for i := range t {
+			values[i] = t[i]
+		}
+
+		return values
+	case []int:
+		values := make([]interface{}, len(t))
+		for i := range t {
+			values[i] = float64(t[i])
+		}
+
+		return values
+	case []interface{}:
+		values := make([]interface{}, len(t))
+		for i := range t {
+			values[i] = normalizeMemoryValue(t[i])
+		}
+
+		return values
+	}
+
+	return v
+}
+
+var memoryColumns = map[string]bool{
+	"id": true, "uuid": true, "type": true, "name": true, "revision": true, "version": true,
+	"created_at": true, "updated_at": true, "set_uuid": true, "parent_uuid": true, "parents": true,
+	"slug": true, "created_by": true, "updated_by": true, "data": true, "meta": true,
+	"deleted": true, "enabled": true, "current": true, "source": true, "status": true, "weight": true,
+}
+
+func (r *memoryRow) column(name string) (interface{}, error) {
+	node := r.node
+
+	switch name {
+	case "id":
+		return float64(node.Id), nil
+	case "uuid":
+		return memoryUuid(node.Uuid.CleanString()), nil
+	case "type":
+		return node.Type, nil
+	case "name":
+		return node.Name, nil
+	case "revision":
+		return float64(node.Revision), nil
+	case "version":
+		return float64(node.Version), nil
+	case "created_at":
+		return node.CreatedAt, nil
+	case "updated_at":
+		return node.UpdatedAt, nil
+	case "set_uuid":
+		return memoryUuid(node.SetUuid.CleanString()), nil
+	case "parent_uuid":
+		return memoryUuid(node.ParentUuid.CleanString()), nil
+	case "parents":
+		parents := make([]interface{}, 0, len(node.Parents))
+		for _, p := range node.Parents {
+			parents = append(parents, memoryUuid(p.CleanString()))
+		}
+
+		return parents, nil
+	case "slug":
+		return node.Slug, nil
+	case "created_by":
+		return memoryUuid(node.CreatedBy.CleanString()), nil
+	case "updated_by":
+		return memoryUuid(node.UpdatedBy.CleanString()), nil
+	case "data":
+		return r.decode(r.data)
+	case "meta":
+		return r.decode(r.meta)
+	case "deleted":
+		return node.Deleted, nil
+	case "enabled":
+		return node.Enabled, nil
+	case "current":
+		return false, nil // the column exists in the schema but it is never set
+	case "source":
+		return memoryUuid(node.Source.CleanString()), nil
+	case "status":
+		return float64(node.Status), nil
+	case "weight":
+		return float64(node.Weight), nil
+	}
+
+	return nil, fmt.Errorf("column %q does not exist", name)
+}
+
+// Return the text value used to group the row, false is returned for a null value
+func (r *memoryRow) aggregateValue(column string, path []string) (string, bool, error) {
+	v, err := r.column(column)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	for i, p := range path {
+		var key interface{} = p
+		if n, err := strconv.Atoi(p); err == nil {
+			key = float64(n)
+		}
+
+		if v, err = memoryJsonGet(v, key, i == len(path)-1); err != nil {
+			return "", false, err
+		}
+
+		if v == nil {
+			return "", false, nil
+		}
+	}
+
+	return memoryText(v), true, nil
+}
+
+func (r *memoryRow) decode(raw []byte) (interface{}, error) {
+	var v interface{}
+
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+
+	return &memoryJson{v}, nil
+}
+
+func memoryJsonGet(source, key interface{}, text bool) (interface{}, error) {
+	var value interface{}
+
+	switch s := source.(type) {
+	case *memoryJson:
+		value = s.value
+	case string: // text value casted as jsonb
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("operator does not exist: %T -> %T", source, key)
+	}
+
+	var found interface{}
+	var ok bool
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		found, ok = v[memoryText(key)]
+	case []interface{}:
+		if f, isNumber := key.(float64); isNumber {
+			i := int(f)
+			if i < 0 {
+				i += len(v)
+			}
+
+			if i >= 0 && i < len(v) {
+				found, ok = v[i], true
+			}
+		}
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	if !text {
+		return &memoryJson{found}, nil
+	}
+
+	switch f := found.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return f, nil
+	default:
+		b, err := json.Marshal(f)
+
+		return string(b), err
+	}
+}
+
+func memoryText(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case memoryUuid:
+		return string(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		if t {
+			return "true"
+		}
+
+		return "false"
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case *memoryJson:
+		b, _ := json.Marshal(t.value)
+
+		return string(b)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func memoryLike(value, pattern string, insensitive bool) bool {
+	expr := ""
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			expr += ".*"
+		case '_':
+			expr += "."
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	if insensitive {
+		expr = "(?i)" + expr
+	}
+
+	matched, _ := regexp.MatchString("^"+expr+"$", value)
+
+	return matched
+}
+
+func memoryAny(op string, v interface{}, values []interface{}) (interface{}, error) {
+	hasNull := false
+
+	for _, value := range values {
+		if value == nil {
+			hasNull = true
+			continue
+		}
+
+		r, err := memoryOperator(op, v, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if r == true {
+			return true, nil
+		}
+	}
+
+	if hasNull {
+		return nil, nil
+	}
+
+	return false, nil
+}
+
+func memoryAll(op string, v interface{}, values []interface{}) (interface{}, error) {
+	hasNull := false
+
+	for _, value := range values {
+		if value == nil {
+			hasNull = true
+			continue
+		}
+
+		r, err := memoryOperator(op, v, value)
+		if err != nil {
+			return nil, err
+		}
+
+		if r == false {
+			return false, nil
+		}
+	}
+
+	if hasNull {
+		return nil, nil
+	}
+
+	return true, nil
+}
+
+func memoryOperator(op string, a, b interface{}) (interface{}, error) {
+	switch op {
+	case "?", "?|", "?&":
+		return memoryJsonExists(op, a, b)
+	case "@>":
+		if values, ok := a.([]interface{}); ok {
+			return memoryArrayContains(values, b)
+		}
+
+		return memoryJsonContains(a, b)
+	case "<@":
+		if values, ok := b.([]interface{}); ok {
+			return memoryArrayContains(values, a)
+		}
+
+		return memoryJsonContains(b, a)
+	}
+
+	c, err := compareMemoryValues(a, b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func toMemoryJson(v interface{}) (*memoryJson, error) {
+	switch t := v.(type) {
+	case *memoryJson:
+		return t, nil
+	case string:
+		var value interface{}
+		if err := json.Unmarshal([]byte(t), &value); err != nil {
+			return nil, err
+		}
+
+		return &memoryJson{value}, nil
+	}
+
+	return nil, fmt.Errorf("cannot cast %T to jsonb", v)
+}
+
+func memoryJsonExists(op string, a, b interface{}) (interface{}, error) {
+	j, err := toMemoryJson(a)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0)
+	if list, ok := b.([]interface{}); ok {
+		for _, k := range list {
+			keys = append(keys, memoryText(k))
+		}
+	} else {
+		keys = append(keys, memoryText(b))
+	}
+
+	exists := func(key string) bool {
+		switch v := j.value.(type) {
+		case map[string]interface{}:
+			_, ok := v[key]
+
+			return ok
+		case []interface{}:
+			for _, e := range v {
+				if s, ok := e.(string); ok && s == key {
+					return true
+				}
+			}
+		case string:
+			return v == key
+		}
+
+		return false
+	}
+
+	for _, key := range keys {
+		found := exists(key)
+
+		if op == "?&" && !found {
+			return false, nil
+		}
+
+		if op != "?&" && found {
+			return true, nil
+		}
+	}
+
+	return op == "?&", nil
+}
+
+// check if all the elements of b are in the array a
+func memoryArrayContains(a []interface{}, b interface{}) (interface{}, error) {
+	values, ok := b.([]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("op @> requires arrays on both sides, got %T", b)
+	}
+
+	for _, v := range values {
+		found := false
+
+		for _, e := range a {
+			if e == nil || v == nil {
+				continue
+			}
+
+			c, err := compareMemoryValues(e, v)
+			if err != nil {
+				return nil, err
+			}
+
+			if c == 0 {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func memoryJsonContains(a, b interface{}) (interface{}, error) {
+	left, err := toMemoryJson(a)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := toMemoryJson(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonContains(left.value, right.value), nil
+}
+
+func jsonContains(a, b interface{}) bool {
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		av, ok := a.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, v := range bv {
+			if _, ok := av[k]; !ok || !jsonContains(av[k], v) {
+				return false
+			}
+		}
+
+		return true
+
+	case []interface{}:
+		av, ok := a.([]interface{})
+		if !ok {
+			return false
+		}
+
+		for _, v := range bv {
+			found := false
+			for _, e := range av {
+				if jsonContains(e, v) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return false
+			}
+		}
+
+		return true
+
+	default:
+		if av, ok := a.([]interface{}); ok { // a scalar is contained in an array
+			for _, e := range av {
+				if compareJsonValues(e, b) == 0 {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		return compareJsonValues(a, b) == 0
+	}
+}
+
+// jsonb ordering: Object > Array > Boolean > Number > String > Null
+func jsonRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case string:
+		return 1
+	case float64:
+		return 2
+	case bool:
+		return 3
+	case []interface{}:
+		return 4
+	}
+
+	return 5
+}
+
+func compareJsonValues(a, b interface{}) int {
+	ra, rb := jsonRank(a), jsonRank(b)
+
+	if ra != rb {
+		return compareInts(ra, rb)
+	}
+
+	switch av := a.(type) {
+	case string:
+		return compareStrings(av, b.(string))
+	case float64:
+		return compareFloats(av, b.(float64))
+	case bool:
+		return compareBools(av, b.(bool))
+	case []interface{}:
+		bv := b.([]interface{})
+		if len(av) != len(bv) {
+			return compareInts(len(av), len(bv))
+		}
+
+		for i := range av {
+			if c := compareJsonValues(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		if len(av) != len(bv) {
+			return compareInts(len(av), len(bv))
+		}
+
+		keys := make([]string, 0, len(av))
+		for k := range av {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if _, ok := bv[k]; !ok {
+				return 1
+			}
+
+			if c := compareJsonValues(av[k], bv[k]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func compareStrings(a, b string) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	}
+
+	if a > b {
+		return 1
+	}
+
+	return 0
+}
+
+func compareBools(a, b bool) int {
+	if a == b {
+		return 0
+	}
+
+	if !a {
+		return -1
+	}
+
+	return 1
+}
+
+func parseMemoryBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "t", "true", "y", "yes", "on", "1":
+		return true, nil
+	case "f", "false", "n", "no", "off", "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid input syntax for type boolean: %q", s)
+}
+
+func parseMemoryTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid input syntax for type timestamp: %q", s)
+}
+
+func compareMemoryValues(a, b interface{}) (int, error) {
+	switch av := a.(type) {
+	case memoryUuid:
+		bv, err := memoryUuidFrom(b)
+		if err != nil {
+			return 0, err
+		}
+
+		return compareStrings(string(av), string(bv)), nil
+
+	case float64:
+		switch bv := b.(type) {
+		case float64:
+			return compareFloats(av, bv), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(bv), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid input syntax for type numeric: %q", bv)
+			}
+
+			return compareFloats(av, f), nil
+		case bool:
+			if bv {
+				return compareFloats(av, 1), nil
+			}
+
+			return compareFloats(av, 0), nil
+		}
+
+	case bool:
+		switch bv := b.(type) {
+		case bool:
+			return compareBools(av, bv), nil
+		case string:
+			v, err := parseMemoryBool(bv)
+			if err != nil {
+				return 0, err
+			}
+
+			return compareBools(av, v), nil
+		}
+
+	case time.Time:
+		switch bv := b.(type) {
+		case time.Time:
+			if av.Before(bv) {
+				return -1, nil
+			}
+
+			if av.After(bv) {
+				return 1, nil
+			}
+
+			return 0, nil
+		case string:
+			t, err := parseMemoryTime(bv)
+			if err != nil {
+				return 0, err
+			}
+
+			return compareMemoryValues(av, t)
+		}
+
+	case *memoryJson:
+		bv, err := toMemoryJson(b)
+		if err != nil {
+			return 0, err
+		}
+
+		return compareJsonValues(av.value, bv.value), nil
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			c, err := compareMemoryValues(av[i], bv[i])
+			if err != nil || c != 0 {
+				return c, err
+			}
+		}
+
+		return compareInts(len(av), len(bv)), nil
+
+	case string:
+		switch b.(type) {
+		case string:
+			return compareStrings(av, b.(string)), nil
+		default:
+			// make sure the typed value drives the conversion
+			c, err := compareMemoryValues(b, a)
+
+			return -c, err
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func memoryUuidFrom(v interface{}) (memoryUuid, error) {
+	switch t := v.(type) {
+	case memoryUuid:
+		return t, nil
+	case string:
+		u, err := uuid.Parse(t)
+		if err != nil {
+			return "", fmt.Errorf("invalid input syntax for uuid: %q", t)
+		}
+
+		return memoryUuid(uuid.Formatter(u, uuid.CleanHyphen)), nil
+	}
+
+	return "", fmt.Errorf("cannot compare uuid with %T", v)
+}
+
+// run the query against the provided rows, the rows are not altered
+func (q *memoryQuery) filter(rows []*memoryRow) ([]*memoryRow, error) {
+	results := make([]*memoryRow, 0)
+
+	for _, row := range rows {
+		if q.where != nil {
+			v, err := q.where(row)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if v != true {
+				continue
+			}
+		}
+
+		results = append(results, row)
+	}
+
+	if len(q.orderBy) > 0 {
+		sorter := &memoryRowSorter{rows: results, orderBy: q.orderBy}
+
+		sort.Stable(sorter)
+
+		if sorter.err != nil {
+			return nil, sorter.err
+		}
+	}
+
+	offset := int(math.Min(float64(q.offset), float64(len(results))))
+	results = results[offset:]
+
+	if q.limit >= 0 && int(q.limit) < len(results) {
+		results = results[:q.limit]
+	}
+
+	return results, nil
+}
+
+type memoryRowSorter struct {
+	rows    []*memoryRow
+	orderBy []*memoryOrder
+	err     error
+}
+
+func (s *memoryRowSorter) Len() int {
+	return len(s.rows)
+}
+
+func (s *memoryRowSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+}
+
+func (s *memoryRowSorter) Less(i, j int) bool {
+	for _, order := range s.orderBy {
+		a, err := order.expr(s.rows[i])
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		b, err := order.expr(s.rows[j])
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		c := 0
+
+		// NULL values are larger than any other values
+		switch {
+		case a == nil && b == nil:
+			c = 0
+		case a == nil:
+			c = 1
+		case b == nil:
+			c = -1
+		default:
+			if c, err = compareMemoryValues(a, b); err != nil {
+				s.err = err
+				return false
+			}
+		}
+
+		if order.desc {
+			c = -c
+		}
+
+		if c != 0 {
+			return c < 0
+		}
+	}
+
+	return false
+}
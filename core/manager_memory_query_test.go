@@ -0,0 +1,101 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	sq "github.com/lann/squirrel"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getMemoryQueryFixtures() *InMemoryNodeManager {
+	m := getMemoryManager()
+
+	for i, name := range []string{"Thomas", "Rabaix", "Gonode"} {
+		node := m.NewNode("core.user")
+		node.Name = name
+		node.Weight = i
+		node.Data.(*User).Username = name
+		node.Data.(*User).Name = "user"
+
+		m.Save(node, false)
+	}
+
+	return m
+}
+
+func Test_MemoryQuery_Parse(t *testing.T) {
+	q, err := parseMemoryQuery(sq.Select("*").From("test_nodes").Where("weight > ?", 1).OrderBy("name DESC").Limit(10).Offset(2).PlaceholderFormat(sq.Dollar))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "test_nodes", q.table)
+	assert.Equal(t, 1, len(q.orderBy))
+	assert.Equal(t, int64(10), q.limit)
+	assert.Equal(t, int64(2), q.offset)
+}
+
+func Test_MemoryQuery_Parse_Invalid(t *testing.T) {
+	_, err := parseMemoryQuery(sq.Select("*").From("test_nodes").Where("weight >"))
+
+	assert.NotNil(t, err)
+}
+
+func Test_MemoryQuery_Filter(t *testing.T) {
+	m := getMemoryQueryFixtures()
+	b := m.SelectBuilder(NewSelectOptions())
+
+	assert.Equal(t, 3, m.FindBy(b, 0, 10).Len())
+	assert.Equal(t, 1, m.FindBy(b.Where("name = ?", "Thomas"), 0, 10).Len())
+	assert.Equal(t, 2, m.FindBy(b.Where(sq.Eq{"name": []string{"Thomas", "Gonode"}}), 0, 10).Len())
+	assert.Equal(t, 2, m.FindBy(b.Where("weight >= ? AND enabled = ?", 1, true), 0, 10).Len())
+	assert.Equal(t, 2, m.FindBy(b.Where("weight = ? OR name LIKE ?", 0, "Go%"), 0, 10).Len())
+	assert.Equal(t, 0, m.FindBy(b.Where("deleted = ?", true), 0, 10).Len())
+	assert.Equal(t, 3, m.FindBy(b.Where("parent_uuid IS NOT NULL"), 0, 10).Len())
+}
+
+func Test_MemoryQuery_Json(t *testing.T) {
+	m := getMemoryQueryFixtures()
+	b := m.SelectBuilder(NewSelectOptions())
+
+	assert.Equal(t, 1, m.FindBy(b.Where("data->>'username' = ?", "Rabaix"), 0, 10).Len())
+	assert.Equal(t, 2, m.FindBy(b.Where(NewExprSlice("data->'username' ??| array["+sq.Placeholders(2)+"]", []string{"Rabaix", "Gonode"})), 0, 10).Len())
+	assert.Equal(t, 3, m.FindBy(b.Where("data @> ?", `{"name": "user"}`), 0, 10).Len())
+}
+
+func Test_MemoryQuery_OrderBy(t *testing.T) {
+	m := getMemoryQueryFixtures()
+	b := m.SelectBuilder(NewSelectOptions())
+
+	nodes := m.FindBy(b.OrderBy("name ASC"), 0, 10)
+
+	assert.Equal(t, "Gonode", nodes.Front().Value.(*Node).Name)
+	assert.Equal(t, "Thomas", nodes.Back().Value.(*Node).Name)
+
+	nodes = m.FindBy(b.OrderBy("data->'username' DESC"), 1, 1)
+
+	assert.Equal(t, 1, nodes.Len())
+	assert.Equal(t, "Rabaix", nodes.Front().Value.(*Node).Name)
+}
+
+func Test_MemoryQuery_Array(t *testing.T) {
+	m := getMemoryQueryFixtures()
+	b := m.SelectBuilder(NewSelectOptions())
+
+	nodes := m.FindBy(b.OrderBy("name ASC"), 0, 10)
+	gonode := nodes.Front().Value.(*Node)
+	rabaix := nodes.Front().Next().Value.(*Node)
+
+	m.Move(rabaix.Uuid, gonode.Uuid)
+
+	assert.Equal(t, 1, m.FindBy(b.Where("parents @> ARRAY[?]::uuid[]", gonode.Uuid.CleanString()), 0, 10).Len())
+	assert.Equal(t, 0, m.FindBy(b.Where("parents @> ARRAY[?]::uuid[]", rabaix.Uuid.CleanString()), 0, 10).Len())
+	assert.Equal(t, 2, m.FindBy(b.Where("cardinality(parents) = ?", 0), 0, 10).Len())
+	assert.Equal(t, "Rabaix", m.FindBy(b.OrderBy("cardinality(parents) DESC"), 0, 1).Front().Value.(*Node).Name)
+
+	_, err := parseMemoryQuery(b.Where("unknown(parents) = ?", 0))
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,659 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"errors"
+	sq "github.com/lann/squirrel"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func getMemoryManager() *InMemoryNodeManager {
+	return &InMemoryNodeManager{
+		Handlers: HandlerCollection{
+			"core.user": &UserHandler{},
+		},
+		Prefix: "test",
+	}
+}
+
+func Test_InMemoryNodeManager_Save_Insert(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	node.Data.(*User).Username = "user-a"
+
+	_, err := m.Save(node, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, node.Id)
+	assert.NotEqual(t, GetEmptyReference(), node.Uuid)
+	assert.Equal(t, node.Uuid.String(), node.Slug)
+
+	saved := m.Find(node.Uuid)
+
+	assert.NotNil(t, saved)
+	assert.Equal(t, "User A", saved.Name)
+	assert.Equal(t, "user-a", saved.Data.(*User).Username)
+
+	// the stored node is not shared
+	saved.Data.(*User).Username = "altered"
+	assert.Equal(t, "user-a", m.Find(node.Uuid).Data.(*User).Username)
+
+	audits := m.FindBy(m.SelectBuilder(NewSelectOptions()).From("test_nodes_audit"), 0, 10)
+	assert.Equal(t, 1, audits.Len())
+}
+
+func Test_InMemoryNodeManager_Save_Revision(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	m.Save(node, false)
+
+	node.Name = "Updated"
+	_, err := m.Save(node, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, node.Revision)
+	assert.Equal(t, 2, m.Find(node.Uuid).Revision)
+
+	audits := m.FindBy(m.SelectBuilder(NewSelectOptions()).From("test_nodes_audit").Where(sq.Eq{"uuid": node.Uuid.String()}).OrderBy("revision DESC"), 0, 10)
+	assert.Equal(t, 2, audits.Len())
+	assert.Equal(t, "Updated", audits.Front().Value.(*Node).Name)
+
+	// outdated revision
+	node.Revision = 1
+	_, err = m.Save(node, true)
+
+	assert.IsType(t, RevisionError, err)
+}
+
+func Test_InMemoryNodeManager_Save_Not_Found(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Id = 42
+
+	_, err := m.Save(node, true)
+
+	assert.Equal(t, NotFoundError, err)
+	assert.Nil(t, m.Find(node.Uuid))
+}
+
+func Test_InMemoryNodeManager_Save_Unique_Slug(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Slug = "the-slug"
+	m.Save(node, false)
+
+	node = m.NewNode("core.user")
+	node.Slug = "the-slug"
+
+	_, err := m.Save(node, false)
+
+	assert.IsType(t, &ConstraintError{}, err)
+	assert.Equal(t, "test_slug", err.(*ConstraintError).Constraint)
+	assert.Equal(t, 1, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Decode_Error(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	m.Save(node, false)
+
+	m.store.tables["test_nodes"][0].data = []byte("{corrupted")
+
+	nodes, err := m.FindByContext(context.Background(), m.SelectBuilder(NewSelectOptions()), 0, 10)
+
+	assert.Nil(t, nodes)
+	assert.IsType(t, &DecodeError{}, err)
+	assert.Equal(t, node.Uuid, err.(*DecodeError).Uuid)
+
+	assert.Panics(t, func() {
+		m.Find(node.Uuid)
+	})
+}
+
+func Test_InMemoryNodeManager_Iterate(t *testing.T) {
+	m := getMemoryManager()
+
+	for _, name := range []string{"User A", "User B", "User C"} {
+		node := m.NewNode("core.user")
+		node.Name = name
+		m.Save(node, false)
+	}
+
+	it, err := m.Iterate(m.SelectBuilder(NewSelectOptions()).OrderBy("name DESC"))
+
+	assert.Nil(t, err)
+
+	names := []string{}
+	for it.Next() {
+		names = append(names, it.Node().Name)
+	}
+
+	assert.Nil(t, it.Err())
+	assert.Nil(t, it.Close())
+	assert.Equal(t, []string{"User C", "User B", "User A"}, names)
+	assert.False(t, it.Next())
+}
+
+func Test_InMemoryNodeManager_Iterate_Error(t *testing.T) {
+	m := getMemoryManager()
+
+	m.Save(m.NewNode("core.user"), false)
+	m.Save(m.NewNode("core.user"), false)
+
+	m.store.tables["test_nodes"][1].data = []byte("{corrupted")
+
+	it, err := m.Iterate(m.SelectBuilder(NewSelectOptions()).OrderBy("id ASC"))
+
+	assert.Nil(t, err)
+	assert.True(t, it.Next())
+	assert.False(t, it.Next())
+	assert.IsType(t, &DecodeError{}, it.Err())
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	it, err = m.IterateContext(ctx, m.SelectBuilder(NewSelectOptions()))
+
+	assert.Nil(t, err)
+
+	cancel()
+
+	assert.False(t, it.Next())
+	assert.Equal(t, context.Canceled, it.Err())
+}
+
+func Test_InMemoryNodeManager_Count_Aggregate(t *testing.T) {
+	m := getMemoryManager()
+
+	for _, name := range []string{"user-a", "user-b", "user-b", ""} {
+		node := m.NewNode("core.user")
+		node.Data.(*User).Username = name
+		if name == "" {
+			node.Status = 1
+		}
+		m.Save(node, false)
+	}
+
+	query := m.SelectBuilder(NewSelectOptions()).Where("type = ?", "core.user")
+
+	count, err := m.Count(query)
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), count)
+
+	values, err := m.Aggregate(query, "data.username", 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*AggregateValue{{Value: "user-b", Count: 2}, {Value: "", Count: 1}, {Value: "user-a", Count: 1}}, values)
+
+	values, err = m.Aggregate(query, "status", 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*AggregateValue{{Value: "0", Count: 3}}, values)
+
+	values, err = m.Aggregate(query, "data.missing", 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(values))
+
+	_, err = m.Aggregate(query, "data.user'name", 0)
+
+	assert.Equal(t, InvalidFieldError, err)
+
+	_, err = m.Aggregate(query, "password", 0)
+
+	assert.Equal(t, InvalidFieldError, err)
+}
+
+func Test_InMemoryNodeManager_Remove(t *testing.T) {
+	m := getMemoryManager()
+
+	for i := 0; i < 3; i++ {
+		m.Save(m.NewNode("core.user"), false)
+	}
+
+	err := m.Remove(m.SelectBuilder(NewSelectOptions()))
+
+	assert.Nil(t, err)
+
+	nodes := m.FindBy(m.SelectBuilder(NewSelectOptions()).Where("deleted = ?", true), 0, 10)
+	assert.Equal(t, 3, nodes.Len())
+}
+
+func Test_InMemoryNodeManager_Move(t *testing.T) {
+	m := getMemoryManager()
+
+	root := m.NewNode("core.user")
+	m.Save(root, false)
+
+	parent := m.NewNode("core.user")
+	m.Save(parent, false)
+
+	child := m.NewNode("core.user")
+	m.Save(child, false)
+
+	affected, err := m.Move(child.Uuid, parent.Uuid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	affected, err = m.Move(parent.Uuid, root.Uuid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	saved := m.Find(child.Uuid)
+	assert.Equal(t, parent.Uuid, saved.ParentUuid)
+	assert.Equal(t, []Reference{root.Uuid, parent.Uuid}, saved.Parents)
+
+	// cannot move a node into one of its children
+	affected, err = m.Move(root.Uuid, child.Uuid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), affected)
+}
+
+func Test_InMemoryNodeManager_Reset(t *testing.T) {
+	m := getMemoryManager()
+
+	m.Save(m.NewNode("core.user"), false)
+	m.Reset()
+
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Transaction_Commit(t *testing.T) {
+	m := getMemoryManager()
+
+	var node *Node
+
+	err := m.Transaction(func(tx NodeManager) error {
+		node = tx.NewNode("core.user")
+		tx.Save(node, false)
+
+		child := tx.NewNode("core.user")
+		tx.Save(child, false)
+
+		_, err := tx.Move(child.Uuid, node.Uuid)
+
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, m.Find(node.Uuid))
+	assert.Equal(t, 2, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Transaction_Rollback(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "Original"
+	m.Save(node, false)
+
+	err := m.Transaction(func(tx NodeManager) error {
+		node.Name = "Updated"
+		tx.Save(node, true)
+
+		tx.Save(tx.NewNode("core.user"), false)
+
+		return errors.New("abort")
+	})
+
+	assert.Equal(t, "abort", err.Error())
+	assert.Equal(t, "Original", m.Find(node.Uuid).Name)
+	assert.Equal(t, 1, m.Find(node.Uuid).Revision)
+	assert.Equal(t, 1, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+	assert.Equal(t, 1, m.FindBy(m.SelectBuilder(NewSelectOptions()).From("test_nodes_audit"), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Transaction_Panic(t *testing.T) {
+	m := getMemoryManager()
+
+	assert.Panics(t, func() {
+		m.Transaction(func(tx NodeManager) error {
+			tx.Save(tx.NewNode("core.user"), false)
+
+			panic("boom")
+		})
+	})
+
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Transaction_Notifications(t *testing.T) {
+	m := getMemoryManager()
+	m.Subscriber = NewSubscriber("", log.New(ioutil.Discard, "", 0))
+
+	m.Transaction(func(tx NodeManager) error {
+		tx.Save(tx.NewNode("core.user"), false)
+
+		assert.Equal(t, 0, len(m.Subscriber.notify))
+
+		return errors.New("abort")
+	})
+
+	assert.Equal(t, 0, len(m.Subscriber.notify))
+
+	m.Transaction(func(tx NodeManager) error {
+		tx.Save(tx.NewNode("core.user"), false)
+
+		// nested transactions join the current one
+		return tx.Transaction(func(tx NodeManager) error {
+			tx.Save(tx.NewNode("core.user"), false)
+
+			return nil
+		})
+	})
+
+	assert.Equal(t, 2, len(m.Subscriber.notify))
+}
+
+func Test_InMemoryNodeManager_Restore(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	node.Data.(*User).Name = "User"
+	node.Data.(*User).Password = "secret"
+	node.Data.(*User).Username = "user-a"
+	m.Save(node, true)
+
+	node.Name = "User B"
+	node.Weight = 2
+	node.Data.(*User).Username = "user-b"
+	m.Save(node, true)
+
+	m.Subscriber = NewSubscriber("", log.New(ioutil.Discard, "", 0))
+
+	restored, err := m.Restore(node.Uuid, 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, restored.Revision)
+	assert.Equal(t, "User A", restored.Name)
+	assert.Equal(t, 1, restored.Weight)
+	assert.Equal(t, "user-a", restored.Data.(*User).Username)
+
+	assert.Equal(t, "User A", m.Find(node.Uuid).Name)
+
+	// the Update and the Restore events
+	assert.Equal(t, 2, len(m.Subscriber.notify))
+	<-m.Subscriber.notify
+	event := CreateModelEvent(<-m.Subscriber.notify)
+	assert.Equal(t, "Restore", event.Action)
+	assert.Equal(t, "1", event.Extra)
+	assert.Equal(t, 3, event.Revision)
+
+	_, err = m.Restore(node.Uuid, 10)
+	assert.Equal(t, NotFoundError, err)
+
+	_, err = m.Restore(GetRootReference(), 1)
+	assert.Equal(t, NotFoundError, err)
+}
+
+func Test_InMemoryNodeManager_Restore_Invalid(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	node.Data.(*User).Name = "User"
+	node.Data.(*User).Password = "secret"
+	m.Save(node, true)
+
+	node.Name = "User B"
+	node.Data.(*User).Username = "user-b"
+	m.Save(node, true)
+
+	// the first revision has no username
+	_, err := m.Restore(node.Uuid, 1)
+
+	assert.IsType(t, &ValidationErrors{}, err)
+	assert.True(t, err.(*ValidationErrors).Errors.HasError("data.username"))
+	assert.Equal(t, 2, m.Find(node.Uuid).Revision)
+}
+
+func Test_InMemoryNodeManager_Undelete(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	m.Save(node, true)
+
+	m.RemoveOne(node)
+
+	assert.True(t, m.Find(node.Uuid).Deleted)
+
+	m.Subscriber = NewSubscriber("", log.New(ioutil.Discard, "", 0))
+
+	node, err := m.Undelete(m.Find(node.Uuid))
+
+	assert.Nil(t, err)
+	assert.False(t, node.Deleted)
+	assert.Equal(t, 3, node.Revision)
+	assert.False(t, m.Find(node.Uuid).Deleted)
+
+	var event *ModelEvent
+	for len(m.Subscriber.notify) > 0 {
+		event = CreateModelEvent(<-m.Subscriber.notify)
+	}
+
+	assert.Equal(t, "Undelete", event.Action)
+	assert.Equal(t, 3, event.Revision)
+}
+
+type purgeUserHandler struct {
+	UserHandler
+
+	purged []*Node
+}
+
+func (h *purgeUserHandler) Purge(node *Node) error {
+	h.purged = append(h.purged, node)
+
+	return nil
+}
+
+func Test_InMemoryNodeManager_Purge(t *testing.T) {
+	handler := &purgeUserHandler{}
+
+	m := &InMemoryNodeManager{
+		Handlers: HandlerCollection{
+			"core.user": handler,
+		},
+		Prefix: "test",
+	}
+
+	nodes := []*Node{}
+	for _, name := range []string{"User A", "User B", "User C"} {
+		node := m.NewNode("core.user")
+		node.Name = name
+		m.Save(node, true)
+		m.Save(node, true)
+
+		nodes = append(nodes, node)
+	}
+
+	m.RemoveOne(nodes[0])
+	m.RemoveOne(nodes[1])
+
+	audit := NewSelectOptions()
+	audit.TableSuffix = "nodes_audit"
+
+	// only the first node is purged
+	purged, err := m.Purge(m.SelectBuilder(NewSelectOptions()).Where("name = ?", "User A"), &PurgeOptions{KeepAudit: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Nil(t, m.Find(nodes[0].Uuid))
+	assert.Equal(t, 3, m.FindBy(m.SelectBuilder(audit).Where("uuid = ?", nodes[0].Uuid.String()), 0, 10).Len())
+	assert.Equal(t, 1, len(handler.purged))
+
+	// the node not deleted is kept
+	purged, err = m.Purge(m.SelectBuilder(NewSelectOptions()), &PurgeOptions{KeepBinaries: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Nil(t, m.Find(nodes[1].Uuid))
+	assert.NotNil(t, m.Find(nodes[2].Uuid))
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(audit).Where("uuid = ?", nodes[1].Uuid.String()), 0, 10).Len())
+	assert.Equal(t, 1, len(handler.purged))
+}
+
+func Test_InMemoryNodeManager_Purge_Rollback(t *testing.T) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	m.Save(node, true)
+	m.RemoveOne(node)
+
+	err := m.Transaction(func(tx NodeManager) error {
+		purged, err := tx.Purge(tx.SelectBuilder(NewSelectOptions()), nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), purged)
+		assert.Nil(t, tx.Find(node.Uuid))
+
+		return errors.New("rollback")
+	})
+
+	assert.NotNil(t, err)
+	assert.NotNil(t, m.Find(node.Uuid))
+
+	audit := NewSelectOptions()
+	audit.TableSuffix = "nodes_audit"
+
+	assert.Equal(t, 2, m.FindBy(m.SelectBuilder(audit), 0, 10).Len())
+}
+
+type contextUserHandler struct {
+	UserHandler
+
+	contexts []context.Context
+}
+
+func (h *contextUserHandler) PreUpdateContext(ctx context.Context, node *Node, m NodeManager) error {
+	h.contexts = append(h.contexts, ctx)
+
+	return nil
+}
+
+func (h *contextUserHandler) PostUpdateContext(ctx context.Context, node *Node, m NodeManager) error {
+	h.contexts = append(h.contexts, ctx)
+
+	return nil
+}
+
+func (h *contextUserHandler) PreInsertContext(ctx context.Context, node *Node, m NodeManager) error {
+	h.contexts = append(h.contexts, ctx)
+
+	return nil
+}
+
+func (h *contextUserHandler) PostInsertContext(ctx context.Context, node *Node, m NodeManager) error {
+	h.contexts = append(h.contexts, ctx)
+
+	return nil
+}
+
+func (h *contextUserHandler) ValidateContext(ctx context.Context, node *Node, m NodeManager, e Errors) {
+	h.contexts = append(h.contexts, ctx)
+}
+
+func Test_InMemoryNodeManager_HandlerContext(t *testing.T) {
+	handler := &contextUserHandler{}
+
+	m := &InMemoryNodeManager{
+		Handlers: HandlerCollection{
+			"core.user": handler,
+		},
+		Prefix: "test",
+	}
+
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	node := m.NewNode("core.user")
+	m.SaveContext(ctx, node, false)
+	m.SaveContext(ctx, node, true)
+	m.ValidateContext(ctx, node)
+
+	assert.Equal(t, 5, len(handler.contexts))
+
+	for _, c := range handler.contexts {
+		assert.Equal(t, "value", c.Value("key"))
+	}
+}
+
+// the handler saves a nested node and fails, like a listener storing a thumbnail
+type failingUserHandler struct {
+	UserHandler
+}
+
+func (h *failingUserHandler) PostInsert(node *Node, m NodeManager) error {
+	if node.Name != "User A" {
+		return nil
+	}
+
+	nested := m.NewNode("core.user")
+	nested.Name = "Nested"
+
+	if _, err := m.Save(nested, false); err != nil {
+		return err
+	}
+
+	return errors.New("Unable to store the nested node")
+}
+
+func Test_InMemoryNodeManager_Save_Handler_Error(t *testing.T) {
+	m := &InMemoryNodeManager{
+		Handlers: HandlerCollection{
+			"core.user": &failingUserHandler{},
+		},
+		Prefix: "test",
+	}
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+
+	_, err := m.Save(node, false)
+
+	assert.Equal(t, errors.New("Unable to store the nested node"), err)
+
+	// the node and the nested node are rolled back
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+
+	audit := NewSelectOptions()
+	audit.TableSuffix = "nodes_audit"
+
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(audit), 0, 10).Len())
+}
+
+func Test_InMemoryNodeManager_Context_Canceled(t *testing.T) {
+	m := getMemoryManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := m.SaveContext(ctx, m.NewNode("core.user"), false)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = m.MoveContext(ctx, GetRootReference(), GetRootReference())
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = m.FindByContext(ctx, m.SelectBuilder(NewSelectOptions()), 0, 10)
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, context.Canceled, m.NotifyContext(ctx, "test_manager_action", "payload"))
+
+	assert.Equal(t, 0, m.FindBy(m.SelectBuilder(NewSelectOptions()), 0, 10).Len())
+}
@@ -7,6 +7,7 @@ package core
 
 import (
 	"container/list"
+	"context"
 	sq "github.com/lann/squirrel"
 	"github.com/stretchr/testify/mock"
 )
@@ -82,3 +83,247 @@ func (m *MockedManager) Move(uuid, parentUuid Reference) (int64, error) {
 
 	return args.Get(0).(int64), args.Error(1)
 }
+
+// The function is called with the mocked manager, so the expectations are set
+// on the same instance
+func (m *MockedManager) Transaction(f func(tx NodeManager) error) error {
+	return f(m)
+}
+
+// The context methods forward the calls to the methods without context, so the
+// expectations are defined the same way
+func (m *MockedManager) FindByContext(ctx context.Context, query sq.SelectBuilder, offset uint64, limit uint64) (*list.List, error) {
+	return m.FindBy(query, offset, limit), nil
+}
+
+func (m *MockedManager) FindOneByContext(ctx context.Context, query sq.SelectBuilder) (*Node, error) {
+	return m.FindOneBy(query), nil
+}
+
+func (m *MockedManager) FindContext(ctx context.Context, uuid Reference) (*Node, error) {
+	return m.Find(uuid), nil
+}
+
+func (m *MockedManager) RemoveContext(ctx context.Context, query sq.SelectBuilder) error {
+	return m.Remove(query)
+}
+
+func (m *MockedManager) RemoveOneContext(ctx context.Context, node *Node) (*Node, error) {
+	return m.RemoveOne(node)
+}
+
+func (m *MockedManager) SaveContext(ctx context.Context, node *Node, revision bool) (*Node, error) {
+	return m.Save(node, revision)
+}
+
+func (m *MockedManager) NotifyContext(ctx context.Context, channel string, payload string) error {
+	m.Notify(channel, payload)
+
+	return nil
+}
+
+func (m *MockedManager) ValidateContext(ctx context.Context, node *Node) (bool, Errors) {
+	return m.Validate(node)
+}
+
+func (m *MockedManager) MoveContext(ctx context.Context, uuid, parentUuid Reference) (int64, error) {
+	return m.Move(uuid, parentUuid)
+}
+
+func (m *MockedManager) TransactionContext(ctx context.Context, f func(tx NodeManager) error) error {
+	return m.Transaction(f)
+}
+
+func (m *MockedManager) Iterate(query sq.SelectBuilder) (NodeIterator, error) {
+	args := m.Mock.Called(query)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(NodeIterator), args.Error(1)
+}
+
+func (m *MockedManager) IterateContext(ctx context.Context, query sq.SelectBuilder) (NodeIterator, error) {
+	return m.Iterate(query)
+}
+
+func (m *MockedManager) Count(query sq.SelectBuilder) (uint64, error) {
+	args := m.Mock.Called(query)
+
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockedManager) CountContext(ctx context.Context, query sq.SelectBuilder) (uint64, error) {
+	return m.Count(query)
+}
+
+func (m *MockedManager) Aggregate(query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	args := m.Mock.Called(query, field, limit)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*AggregateValue), args.Error(1)
+}
+
+func (m *MockedManager) AggregateContext(ctx context.Context, query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	return m.Aggregate(query, field, limit)
+}
+
+func (m *MockedManager) Restore(uuid Reference, revision int) (*Node, error) {
+	args := m.Mock.Called(uuid, revision)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Node), args.Error(1)
+}
+
+func (m *MockedManager) RestoreContext(ctx context.Context, uuid Reference, revision int) (*Node, error) {
+	return m.Restore(uuid, revision)
+}
+
+func (m *MockedManager) Undelete(node *Node) (*Node, error) {
+	args := m.Mock.Called(node)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Node), args.Error(1)
+}
+
+func (m *MockedManager) UndeleteContext(ctx context.Context, node *Node) (*Node, error) {
+	return m.Undelete(node)
+}
+
+func (m *MockedManager) Purge(query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	args := m.Mock.Called(query, options)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedManager) PurgeContext(ctx context.Context, query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	return m.Purge(query, options)
+}
+
+func (m *MockedManager) FindChildren(uuid Reference) (*list.List, error) {
+	args := m.Mock.Called(uuid)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*list.List), args.Error(1)
+}
+
+func (m *MockedManager) FindChildrenContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return m.FindChildren(uuid)
+}
+
+func (m *MockedManager) FindDescendants(uuid Reference, depth int) (*list.List, error) {
+	args := m.Mock.Called(uuid, depth)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*list.List), args.Error(1)
+}
+
+func (m *MockedManager) FindDescendantsContext(ctx context.Context, uuid Reference, depth int) (*list.List, error) {
+	return m.FindDescendants(uuid, depth)
+}
+
+func (m *MockedManager) FindAncestors(uuid Reference) (*list.List, error) {
+	args := m.Mock.Called(uuid)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*list.List), args.Error(1)
+}
+
+func (m *MockedManager) FindAncestorsContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return m.FindAncestors(uuid)
+}
+
+func (m *MockedManager) Copy(uuid, parent Reference, deep bool) (*Node, error) {
+	args := m.Mock.Called(uuid, parent, deep)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Node), args.Error(1)
+}
+
+func (m *MockedManager) CopyContext(ctx context.Context, uuid, parent Reference, deep bool) (*Node, error) {
+	return m.Copy(uuid, parent, deep)
+}
+
+func (m *MockedManager) Reorder(parent Reference, uuids []Reference) (*list.List, error) {
+	args := m.Mock.Called(parent, uuids)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*list.List), args.Error(1)
+}
+
+func (m *MockedManager) ReorderContext(ctx context.Context, parent Reference, uuids []Reference) (*list.List, error) {
+	return m.Reorder(parent, uuids)
+}
+
+func (m *MockedManager) Link(relation *Relation) (*Relation, error) {
+	args := m.Mock.Called(relation)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Relation), args.Error(1)
+}
+
+func (m *MockedManager) LinkContext(ctx context.Context, relation *Relation) (*Relation, error) {
+	return m.Link(relation)
+}
+
+func (m *MockedManager) Unlink(from, to Reference, kind string) (int64, error) {
+	args := m.Mock.Called(from, to, kind)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedManager) UnlinkContext(ctx context.Context, from, to Reference, kind string) (int64, error) {
+	return m.Unlink(from, to, kind)
+}
+
+func (m *MockedManager) FindRelations(from, to Reference, kind string) ([]*Relation, error) {
+	args := m.Mock.Called(from, to, kind)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]*Relation), args.Error(1)
+}
+
+func (m *MockedManager) FindRelationsContext(ctx context.Context, from, to Reference, kind string) ([]*Relation, error) {
+	return m.FindRelations(from, to, kind)
+}
+
+func (m *MockedManager) MigrateNodes(query sq.SelectBuilder) (int64, error) {
+	args := m.Mock.Called(query)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockedManager) MigrateNodesContext(ctx context.Context, query sq.SelectBuilder) (int64, error) {
+	return m.MigrateNodes(query)
+}
@@ -7,15 +7,20 @@ package core
 
 import (
 	"container/list"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
 	sq "github.com/lann/squirrel"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/twinj/uuid"
+	"io"
 	"log"
+	"net"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,6 +30,14 @@ type PgNodeManager struct {
 	Db       *sql.DB
 	ReadOnly bool
 	Prefix   string
+	// the referential integrity rules of the reference fields
+	Integrity IntegrityRules
+	// the connections used by the read queries outside a transaction, the writes
+	// and the revision check stay on the master connection
+	Replicas []*sql.DB
+
+	tx   *sql.Tx
+	next uint32
 }
 
 type SelectOptions struct {
@@ -46,12 +59,107 @@ func (m *PgNodeManager) SelectBuilder(options *SelectOptions) sq.SelectBuilder {
 		PlaceholderFormat(sq.Dollar)
 }
 
+// The common methods of sql.DB and sql.Tx
+type pgRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Return the transaction if one is running, the connection pool otherwise
+func (m *PgNodeManager) runner() pgRunner {
+	if m.tx != nil {
+		return m.tx
+	}
+
+	return m.Db
+}
+
+// Return the runner of the read queries: the transaction if one is running, the
+// master connection if the context requires to read your writes, otherwise the
+// replicas are used in turn.
+func (m *PgNodeManager) reader(ctx context.Context) pgRunner {
+	if m.tx != nil || len(m.Replicas) == 0 || ReadYourWrites(ctx) {
+		return m.runner()
+	}
+
+	return m.Replicas[atomic.AddUint32(&m.next, 1)%uint32(len(m.Replicas))]
+}
+
+type readYourWritesContextKey int
+
+const readYourWritesKey readYourWritesContextKey = 0
+
+// Return a context whose read queries are sent to the master connection, so the
+// changes are visible even if the replicas lag behind
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey, true)
+}
+
+func ReadYourWrites(ctx context.Context) bool {
+	v, _ := ctx.Value(readYourWritesKey).(bool)
+
+	return v
+}
+
+// Run the function inside a transaction, the transaction is rolled back if the
+// function returns an error or panics. A transaction started from a transaction
+// joins the current one. As PostgreSQL delivers the notifications on commit,
+// the notifications sent inside the transaction are dropped on rollback.
+func (m *PgNodeManager) Transaction(f func(tx NodeManager) error) error {
+	return m.TransactionContext(context.Background(), f)
+}
+
+// The transaction is rolled back if the context is done before the commit
+func (m *PgNodeManager) TransactionContext(ctx context.Context, f func(tx NodeManager) error) (err error) {
+	if m.tx != nil {
+		return f(m)
+	}
+
+	tx, err := m.Db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	manager := &PgNodeManager{
+		Logger:    m.Logger,
+		Handlers:  m.Handlers,
+		Db:        m.Db,
+		ReadOnly:  m.ReadOnly,
+		Prefix:    m.Prefix,
+		Integrity: m.Integrity,
+		Replicas:  m.Replicas,
+		tx:        tx,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+
+			panic(r)
+		}
+	}()
+
+	if err = f(manager); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return pgError(tx.Commit())
+}
+
 func (m *PgNodeManager) Notify(channel string, payload string) {
+	PanicOnError(m.NotifyContext(context.Background(), channel, payload))
+}
+
+func (m *PgNodeManager) NotifyContext(ctx context.Context, channel string, payload string) error {
 	//	m.Logger.Printf("[PgNode] NOTIFY %s, %s ", channel, payload)
 
-	_, err := m.Db.Exec(fmt.Sprintf("NOTIFY %s, '%s'", channel, strings.Replace(payload, "'", "''", -1)))
+	_, err := m.runner().ExecContext(ctx, fmt.Sprintf("NOTIFY %s, '%s'", channel, strings.Replace(payload, "'", "''", -1)))
 
-	PanicOnError(err)
+	return pgError(err)
 }
 
 func (m *PgNodeManager) NewNode(t string) *Node {
@@ -59,48 +167,160 @@ func (m *PgNodeManager) NewNode(t string) *Node {
 }
 
 func (m *PgNodeManager) FindBy(query sq.SelectBuilder, offset uint64, limit uint64) *list.List {
+	list, err := m.FindByContext(context.Background(), query, offset, limit)
+
+	PanicOnError(err)
+
+	return list
+}
+
+func (m *PgNodeManager) FindByContext(ctx context.Context, query sq.SelectBuilder, offset uint64, limit uint64) (*list.List, error) {
 	query = query.Limit(limit).Offset(offset)
 
-	rawSql, _, _ := query.ToSql()
+	rawSql, args, err := query.ToSql()
 
-	rows, err := query.
-		RunWith(m.Db).
-		Query()
+	if err != nil {
+		return nil, err
+	}
 
-	list := list.New()
+	rows, err := m.reader(ctx).QueryContext(ctx, rawSql, args...)
 
 	if err != nil {
 		if m.Logger != nil {
 			m.Logger.Printf("[PgNode] Error while runing the request: `%s`, %s ", rawSql, err)
 		}
 
-		PanicOnError(err)
+		return nil, pgError(err)
 	}
 
+	defer rows.Close()
+
+	list := list.New()
+
 	for rows.Next() {
-		node := m.hydrate(rows)
+		node, err := m.hydrate(rows)
+
+		if err != nil {
+			return nil, err
+		}
 
 		list.PushBack(node)
 	}
 
-	return list
+	return list, pgError(rows.Err())
 }
 
 func (m *PgNodeManager) FindOneBy(query sq.SelectBuilder) *Node {
-	list := m.FindBy(query, 0, 1)
+	node, err := m.FindOneByContext(context.Background(), query)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *PgNodeManager) FindOneByContext(ctx context.Context, query sq.SelectBuilder) (*Node, error) {
+	list, err := m.FindByContext(ctx, query, 0, 1)
+
+	if err != nil {
+		return nil, err
+	}
 
 	if list.Len() == 1 {
-		return list.Front().Value.(*Node)
+		return list.Front().Value.(*Node), nil
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (m *PgNodeManager) Find(uuid Reference) *Node {
-	return m.FindOneBy(m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": uuid.String()}))
+	node, err := m.FindContext(context.Background(), uuid)
+
+	PanicOnError(err)
+
+	return node
+}
+
+func (m *PgNodeManager) FindContext(ctx context.Context, uuid Reference) (*Node, error) {
+	return m.FindOneByContext(ctx, m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": uuid.String()}))
+}
+
+func (m *PgNodeManager) Count(query sq.SelectBuilder) (uint64, error) {
+	return m.CountContext(context.Background(), query)
+}
+
+// The limit and the offset of the query are applied before counting
+func (m *PgNodeManager) CountContext(ctx context.Context, query sq.SelectBuilder) (uint64, error) {
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+
+	err = m.reader(ctx).QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS c", rawSql), args...).Scan(&count)
+
+	return count, pgError(err)
+}
+
+func (m *PgNodeManager) Aggregate(query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	return m.AggregateContext(context.Background(), query, field, limit)
+}
+
+// Group the nodes matching the query by the field's value, the most used values
+// come first and the null values are ignored. There is no limit if the limit is 0.
+func (m *PgNodeManager) AggregateContext(ctx context.Context, query sq.SelectBuilder, field string, limit uint64) ([]*AggregateValue, error) {
+	column, path, err := parseAggregateField(field)
+
+	if err != nil {
+		return nil, err
+	}
+
+	expr := fmt.Sprintf("a.%s::text", column)
+	if len(path) > 0 {
+		expr = fmt.Sprintf("a.%s #>> '{%s}'", column, strings.Join(path, ","))
+	}
+
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rawSql = fmt.Sprintf("SELECT %s AS value, COUNT(*) AS count FROM (%s) AS a WHERE %s IS NOT NULL GROUP BY 1 ORDER BY 2 DESC, 1 ASC", expr, rawSql, expr)
+
+	if limit > 0 {
+		rawSql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := m.reader(ctx).QueryContext(ctx, rawSql, args...)
+
+	if err != nil {
+		if m.Logger != nil {
+			m.Logger.Printf("[PgNode] Error while runing the request: `%s`, %s ", rawSql, err)
+		}
+
+		return nil, pgError(err)
+	}
+
+	defer rows.Close()
+
+	values := make([]*AggregateValue, 0)
+
+	for rows.Next() {
+		v := &AggregateValue{}
+
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, pgError(err)
+		}
+
+		values = append(values, v)
+	}
+
+	return values, pgError(rows.Err())
 }
 
-func (m *PgNodeManager) hydrate(rows *sql.Rows) *Node {
+func (m *PgNodeManager) hydrate(rows *sql.Rows) (*Node, error) {
 	node := &Node{}
 
 	data := json.RawMessage{}
@@ -139,7 +359,9 @@ func (m *PgNodeManager) hydrate(rows *sql.Rows) *Node {
 		&node.Weight,
 	)
 
-	PanicOnError(err)
+	if err != nil {
+		return nil, pgError(err)
+	}
 
 	var tmpUuid uuid.UUID
 
@@ -166,63 +388,105 @@ func (m *PgNodeManager) hydrate(rows *sql.Rows) *Node {
 
 	node.Parents = pUuids
 
-	m.Handlers.Get(node).Load(data, meta, node)
+	if err := loadNode(m.Handlers.Get(node), data, meta, node); err != nil {
+		return nil, &DecodeError{Uuid: node.Uuid, Err: err}
+	}
 
-	return node
+	return node, nil
 }
 
 func (m *PgNodeManager) Remove(query sq.SelectBuilder) error {
+	return m.RemoveContext(context.Background(), query)
+}
+
+func (m *PgNodeManager) RemoveContext(ctx context.Context, query sq.SelectBuilder) error {
 	query = query.Where("deleted != ?", true)
 
 	now := time.Now()
 
-	for {
-		nodes := m.FindBy(query, 0, 1024)
+	it, err := m.IterateContext(ctx, query)
 
-		if nodes.Len() == 0 {
-			return nil
-		}
+	if err != nil {
+		return err
+	}
+
+	defer it.Close()
 
-		for e := nodes.Front(); e != nil; e = e.Next() {
-			node := e.Value.(*Node)
-			node.Deleted = true
-			node.UpdatedAt = now
+	for it.Next() {
+		node := it.Node()
+		node.Deleted = true
+		node.UpdatedAt = now
 
-			m.Save(node, false)
+		if _, err := m.SaveContext(ctx, node, false); err != nil {
+			return err
+		}
 
-			m.sendNotification(m.Prefix+"_manager_action", &ModelEvent{
-				Type:     node.Type,
-				Name:     node.Name,
-				Action:   "SoftDelete",
-				Subject:  node.Uuid.CleanString(),
-				Revision: node.Revision,
-				Date:     node.UpdatedAt,
-			})
+		err := m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+			Type:     node.Type,
+			Name:     node.Name,
+			Action:   "SoftDelete",
+			Subject:  node.Uuid.CleanString(),
+			Revision: node.Revision,
+			Date:     node.UpdatedAt,
+		})
 
-			m.Logger.Printf("[PgNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+		if err != nil {
+			return err
 		}
+
+		m.Logger.Printf("[PgNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
 	}
+
+	return it.Err()
 }
 
 func (m *PgNodeManager) RemoveOne(node *Node) (*Node, error) {
-	node.UpdatedAt = time.Now()
-	node.Deleted = true
+	return m.RemoveOneContext(context.Background(), node)
+}
 
-	m.Logger.Printf("[PgNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+// The delete rules of the node's relations and the integrity rules are applied
+// in the same transaction
+func (m *PgNodeManager) RemoveOneContext(ctx context.Context, node *Node) (*Node, error) {
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		if err := restrictReferences(ctx, tx, m.Integrity, node); err != nil {
+			return err
+		}
 
-	m.sendNotification(m.Prefix+"_manager_action", &ModelEvent{
-		Type:     node.Type,
-		Action:   "SoftDelete",
-		Subject:  node.Uuid.CleanString(),
-		Revision: node.Revision,
-		Date:     node.UpdatedAt,
-		Name:     node.Name,
+		node.UpdatedAt = time.Now()
+		node.Deleted = true
+
+		m.Logger.Printf("[PgNode] Soft Delete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+
+		err := tx.(*PgNodeManager).sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+			Type:     node.Type,
+			Action:   "SoftDelete",
+			Subject:  node.Uuid.CleanString(),
+			Revision: node.Revision,
+			Date:     node.UpdatedAt,
+			Name:     node.Name,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.SaveContext(ctx, node, true); err != nil {
+			return err
+		}
+
+		if err := removeRelations(ctx, tx, node); err != nil {
+			return err
+		}
+
+		return removeReferences(ctx, tx, m.Integrity, node, func(tx NodeManager, node *Node) error {
+			return tx.(*PgNodeManager).detach(ctx, node)
+		})
 	})
 
-	return m.Save(node, true)
+	return node, err
 }
 
-func (m *PgNodeManager) insertNode(node *Node, table string) (*Node, error) {
+func (m *PgNodeManager) insertNode(ctx context.Context, node *Node, table string) (*Node, error) {
 	if node.Uuid == GetEmptyReference() {
 		node.Uuid = GetReference(uuid.NewV4())
 	}
@@ -264,23 +528,43 @@ func (m *PgNodeManager) insertNode(node *Node, table string) (*Node, error) {
 		node.Weight,
 	).
 		Suffix("RETURNING \"id\"").
-		RunWith(m.Db).
 		PlaceholderFormat(sq.Dollar)
 
-	err := query.QueryRow().Scan(&node.Id)
+	rawSql, args, err := query.ToSql()
 
-	return node, err
+	if err != nil {
+		return node, err
+	}
+
+	err = m.runner().QueryRowContext(ctx, rawSql, args...).Scan(&node.Id)
+
+	return node, pgError(err)
 }
 
 func (m *PgNodeManager) Move(uuid, parentUuid Reference) (int64, error) {
+	return m.MoveContext(context.Background(), uuid, parentUuid)
+}
 
-	tx, err := m.Db.Begin()
+func (m *PgNodeManager) MoveContext(ctx context.Context, uuid, parentUuid Reference) (int64, error) {
+	var affectedRows int64
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) (err error) {
+		if affectedRows, err = tx.(*PgNodeManager).move(ctx, uuid, parentUuid); err != nil || affectedRows == 0 {
+			return err
+		}
+
+		return tx.(*PgNodeManager).sendNotification(ctx, m.Prefix+"_manager_action", newMoveEvent(uuid, parentUuid))
+	})
 
 	if err != nil {
 		return 0, err
 	}
 
-	r, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET parent_uuid = $1 WHERE uuid = $2 AND EXISTS(SELECT uuid FROM %s WHERE uuid = $3 and $4 <> ALL(parents))`,
+	return affectedRows, nil
+}
+
+func (m *PgNodeManager) move(ctx context.Context, uuid, parentUuid Reference) (int64, error) {
+	r, err := m.tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET parent_uuid = $1 WHERE uuid = $2 AND EXISTS(SELECT uuid FROM %s WHERE uuid = $3 and $4 <> ALL(parents))`,
 		m.Prefix+"_nodes", m.Prefix+"_nodes"),
 		parentUuid.CleanString(),
 		uuid.CleanString(),
@@ -288,52 +572,63 @@ func (m *PgNodeManager) Move(uuid, parentUuid Reference) (int64, error) {
 		uuid.CleanString())
 
 	if err != nil {
-		tx.Rollback()
-
-		return 0, err
+		return 0, pgError(err)
 	}
 
 	affectedRows, err := r.RowsAffected()
 
 	if err != nil {
-		tx.Rollback()
-
-		return 0, err
+		return 0, pgError(err)
 	}
 
 	if affectedRows > 0 {
-		tx.Exec(fmt.Sprintf(`WITH RECURSIVE  r AS (
-					SELECT uuid, parent_uuid, parents
-					FROM %s r
-					WHERE uuid = $1::uuid
-				UNION ALL
-					SELECT c.uuid, c.parent_uuid, array_append(r.parents, c.parent_uuid) AS parents
-					FROM %s c
-					JOIN r ON c.parent_uuid = r.uuid
-			)
-			UPDATE %s n SET parents = r.parents FROM r WHERE r.uuid = n.uuid`,
-			m.Prefix+"_nodes",
-			m.Prefix+"_nodes",
-			m.Prefix+"_nodes"),
-			parentUuid.CleanString())
-	}
-
-	err = tx.Commit()
+		if err := m.updateParents(ctx, parentUuid); err != nil {
+			return 0, err
+		}
+	}
 
-	if err != nil {
-		tx.Rollback()
+	return affectedRows, nil
+}
 
-		return 0, err
+// recompute the parents of the descendants of the node
+func (m *PgNodeManager) updateParents(ctx context.Context, uuid Reference) error {
+	_, err := m.runner().ExecContext(ctx, fmt.Sprintf(`WITH RECURSIVE  r AS (
+				SELECT uuid, parent_uuid, parents
+				FROM %s r
+				WHERE uuid = $1::uuid
+			UNION ALL
+				SELECT c.uuid, c.parent_uuid, array_append(r.parents, c.parent_uuid) AS parents
+				FROM %s c
+				JOIN r ON c.parent_uuid = r.uuid
+		)
+		UPDATE %s n SET parents = r.parents FROM r WHERE r.uuid = n.uuid`,
+		m.Prefix+"_nodes",
+		m.Prefix+"_nodes",
+		m.Prefix+"_nodes"),
+		uuid.CleanString())
+
+	return pgError(err)
+}
+
+// detach the node from its parent, the node becomes a root node and the parents
+// of its descendants are recomputed. The audit table is not altered.
+func (m *PgNodeManager) detach(ctx context.Context, node *Node) error {
+	_, err := m.runner().ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET parent_uuid = $1, parents = '{}' WHERE id = $2`, m.Prefix+"_nodes"),
+		emptyUuid.CleanString(),
+		node.Id)
+
+	if err != nil {
+		return pgError(err)
 	}
 
-	return affectedRows, nil
+	return m.updateParents(ctx, node.Uuid)
 }
 
-func (m *PgNodeManager) updateNode(node *Node, table string) (*Node, error) {
+func (m *PgNodeManager) updateNode(ctx context.Context, node *Node, table string) (*Node, error) {
 
 	PanicIf(node.Id == 0, "Cannot update node without id")
 
-	query := sq.Update(m.Prefix+"_nodes").RunWith(m.Db).PlaceholderFormat(sq.Dollar).
+	query := sq.Update(m.Prefix+"_nodes").PlaceholderFormat(sq.Dollar).
 		Set("uuid", node.Uuid.CleanString()).
 		Set("type", node.Type).
 		Set("revision", node.Revision).
@@ -354,22 +649,47 @@ func (m *PgNodeManager) updateNode(node *Node, table string) (*Node, error) {
 		Set("weight", node.Weight).
 		Where("id = ?", node.Id)
 
-	result, err := query.Exec()
+	rawSql, args, err := query.ToSql()
 
-	PanicOnError(err)
+	if err != nil {
+		return node, err
+	}
+
+	result, err := m.runner().ExecContext(ctx, rawSql, args...)
+
+	if err != nil {
+		return node, pgError(err)
+	}
 
 	affected, err := result.RowsAffected()
 
-	PanicOnError(err)
+	if err != nil {
+		return node, pgError(err)
+	}
 
 	if affected == 0 {
 		return node, errors.New("Zero affected rows for current node")
 	}
 
-	return node, err
+	return node, nil
 }
 
 func (m *PgNodeManager) Save(node *Node, revision bool) (*Node, error) {
+	return m.SaveContext(context.Background(), node, revision)
+}
+
+func (m *PgNodeManager) SaveContext(ctx context.Context, node *Node, revision bool) (*Node, error) {
+	if m.tx == nil {
+		// the audit row, the node and the nodes saved by the handlers are stored together
+		err := m.TransactionContext(ctx, func(tx NodeManager) error {
+			_, err := tx.SaveContext(ctx, node, revision)
+
+			return err
+		})
+
+		return node, err
+	}
+
 	if m.Logger != nil {
 		m.Logger.Printf("[PgNode] Saving uuid: %s, id: %d, type: %s, revision: %d", node.Uuid, node.Id, node.Type, node.Revision)
 	}
@@ -381,23 +701,29 @@ func (m *PgNodeManager) Save(node *Node, revision bool) (*Node, error) {
 	handler := m.Handlers.Get(node)
 
 	if node.Id == 0 {
-		handler.PreInsert(node, m)
+		if err = handlerPreInsert(ctx, handler, node, m); err != nil {
+			return node, err
+		}
 
-		node, err = m.insertNode(node, m.Prefix+"_nodes_audit")
-		PanicOnError(err)
+		if node, err = m.insertNode(ctx, node, m.Prefix+"_nodes_audit"); err != nil {
+			return node, err
+		}
 
 		node.Id = 0
 
-		node, err = m.insertNode(node, m.Prefix+"_nodes")
-		PanicOnError(err)
+		if node, err = m.insertNode(ctx, node, m.Prefix+"_nodes"); err != nil {
+			return node, err
+		}
 
 		if m.Logger != nil {
 			m.Logger.Printf("[PgNode] Creating node uuid: %s, id: %d, type: %s, revision: %d", node.Uuid, node.Id, node.Type, node.Revision)
 		}
 
-		handler.PostInsert(node, m)
+		if err = handlerPostInsert(ctx, handler, node, m); err != nil {
+			return node, err
+		}
 
-		m.sendNotification(m.Prefix+"_manager_action", &ModelEvent{
+		err = m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
 			Type:        node.Type,
 			Action:      "Create",
 			Subject:     node.Uuid.CleanString(),
@@ -410,12 +736,23 @@ func (m *PgNodeManager) Save(node *Node, revision bool) (*Node, error) {
 		return node, err
 	}
 
-	handler.PreUpdate(node, m)
+	if err = handlerPreUpdate(ctx, handler, node, m); err != nil {
+		return node, err
+	}
 
 	// 1. check if the one in the datastore is older
-	saved := m.FindOneBy(m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": node.Uuid.String()}))
+	saved, err := m.FindOneByContext(ctx, m.SelectBuilder(NewSelectOptions()).Where(sq.Eq{"uuid": node.Uuid.String()}))
 
-	if saved != nil && node.Revision != saved.Revision {
+	if err != nil {
+		return node, err
+	}
+
+	// the node has been purged or the id is not valid
+	if saved == nil {
+		return node, NotFoundError
+	}
+
+	if node.Revision != saved.Revision {
 		m.Logger.Printf("[PgNode] Invalid revision for node: %s, saved rev: %d, current rev: %d", node.Uuid, saved.Revision, node.Revision)
 
 		return node, NewRevisionError(fmt.Sprintf("Invalid revision for node: %s, saved rev: %d, current rev: %d", node.Uuid, saved.Revision, node.Revision))
@@ -429,25 +766,31 @@ func (m *PgNodeManager) Save(node *Node, revision bool) (*Node, error) {
 		// 3. Update the revision number
 		node.Revision++
 		node.CreatedAt = saved.CreatedAt
-		node.UpdatedAt = saved.UpdatedAt
+		node.UpdatedAt = time.Now()
 
 		m.Logger.Printf("[PgNode] Increment revision - uuid: %s, id: %d, type: %s, revision: %d", node.Uuid, node.Id, node.Type, node.Revision)
 	}
 
-	node, err = m.updateNode(node, m.Prefix+"_nodes")
-	PanicOnError(err)
+	if node, err = m.updateNode(ctx, node, m.Prefix+"_nodes"); err != nil {
+		return node, err
+	}
 
-	handler.PostUpdate(node, m)
+	if err = handlerPostUpdate(ctx, handler, node, m); err != nil {
+		return node, err
+	}
 
 	if revision {
 		id := node.Id
-		_, err = m.insertNode(node, m.Prefix+"_nodes_audit")
+		_, err = m.insertNode(ctx, node, m.Prefix+"_nodes_audit")
 
 		node.Id = id
-		PanicOnError(err)
+
+		if err != nil {
+			return node, err
+		}
 	}
 
-	m.sendNotification(m.Prefix+"_manager_action", &ModelEvent{
+	err = m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
 		Type:        node.Type,
 		Action:      "Update",
 		Subject:     node.Uuid.CleanString(),
@@ -460,32 +803,397 @@ func (m *PgNodeManager) Save(node *Node, revision bool) (*Node, error) {
 	return node, err
 }
 
-func (m *PgNodeManager) sendNotification(channel string, element interface{}) {
+func (m *PgNodeManager) sendNotification(ctx context.Context, channel string, element interface{}) error {
 	data, _ := json.Marshal(element)
 
-	m.Notify(channel, string(data[:]))
+	return m.NotifyContext(ctx, channel, string(data[:]))
+}
+
+func (m *PgNodeManager) Restore(uuid Reference, revision int) (*Node, error) {
+	return m.RestoreContext(context.Background(), uuid, revision)
+}
+
+func (m *PgNodeManager) RestoreContext(ctx context.Context, uuid Reference, revision int) (*Node, error) {
+	return restoreNode(ctx, m, m.Prefix, uuid, revision)
 }
 
 func (m *PgNodeManager) Validate(node *Node) (bool, Errors) {
-	errors := NewErrors()
+	return m.ValidateContext(context.Background(), node)
+}
 
-	if node.Name == "" {
-		errors.AddError("name", "Username cannot be empty")
+func (m *PgNodeManager) ValidateContext(ctx context.Context, node *Node) (bool, Errors) {
+	return validateNode(ctx, node, m, m.Handlers, m.Integrity)
+}
+
+// Convert the driver's errors into the core's errors, the other errors are
+// returned as is.
+func pgError(err error) error {
+	if err == nil {
+		return nil
 	}
 
-	if node.Slug == "" {
-		errors.AddError("slug", "Name cannot be empty")
+	if e, ok := err.(*pq.Error); ok {
+		switch {
+		case e.Code.Class() == "08": // connection exception
+			return &ConnectionError{Err: err}
+		case e.Code.Class() == "57" && e.Code != "57014": // shutdown, 57014 is a canceled query
+			return &ConnectionError{Err: err}
+		case e.Code.Class() == "23": // integrity constraint violation
+			return &ConstraintError{Constraint: e.Constraint, Err: err}
+		}
+
+		return err
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return &ConnectionError{Err: err}
+	}
+
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &ConnectionError{Err: err}
+	}
+
+	return err
+}
+
+func (m *PgNodeManager) Undelete(node *Node) (*Node, error) {
+	return m.UndeleteContext(context.Background(), node)
+}
+
+func (m *PgNodeManager) UndeleteContext(ctx context.Context, node *Node) (*Node, error) {
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Undelete: Uuid:%+v - type: %s", node.Uuid, node.Type)
+	}
+
+	return undeleteNode(ctx, m, m.Prefix, node)
+}
+
+func (m *PgNodeManager) MigrateNodes(query sq.SelectBuilder) (int64, error) {
+	return m.MigrateNodesContext(context.Background(), query)
+}
+
+func (m *PgNodeManager) MigrateNodesContext(ctx context.Context, query sq.SelectBuilder) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	migrated, err := migrateNodes(ctx, m, m.Handlers, query, func(tx NodeManager, node *Node) error {
+		return tx.(*PgNodeManager).updateVersion(ctx, node)
+	})
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] MigrateNodes: %d nodes migrated", migrated)
+	}
+
+	return migrated, err
+}
+
+// store the data, the meta and the version of the current node only, the audit
+// table is not altered
+func (m *PgNodeManager) updateVersion(ctx context.Context, node *Node) error {
+	_, err := m.runner().ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET data = $1, meta = $2, version = $3 WHERE id = $4`, m.Prefix+"_nodes"),
+		string(InterfaceToJsonMessage(node.Type, node.Data)[:]),
+		string(InterfaceToJsonMessage(node.Type, node.Meta)[:]),
+		node.Version,
+		node.Id)
+
+	return pgError(err)
+}
+
+func (m *PgNodeManager) Purge(query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	return m.PurgeContext(context.Background(), query, options)
+}
+
+// Delete the soft deleted nodes matching the query from the datastore, the rows
+// are deleted in one transaction and the handler's data are removed once the
+// transaction is committed. The number of purged nodes is returned.
+func (m *PgNodeManager) PurgeContext(ctx context.Context, query sq.SelectBuilder, options *PurgeOptions) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if options == nil {
+		options = &PurgeOptions{}
+	}
+
+	query = query.Where("deleted = ?", true)
+
+	purged := make([]*Node, 0)
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		ptx := tx.(*PgNodeManager)
+
+		it, err := ptx.IterateContext(ctx, query)
+
+		if err != nil {
+			return err
+		}
+
+		defer it.Close()
+
+		for it.Next() {
+			node := it.Node()
+
+			if err := ptx.purge(ctx, node, options); err != nil {
+				return err
+			}
+
+			purged = append(purged, node)
+		}
+
+		return it.Err()
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if options.KeepBinaries {
+		return int64(len(purged)), nil
+	}
+
+	return int64(len(purged)), purgeHandlers(m.Handlers, purged)
+}
+
+func (m *PgNodeManager) purge(ctx context.Context, node *Node, options *PurgeOptions) error {
+	if _, err := m.tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, m.Prefix+"_nodes"), node.Id); err != nil {
+		return pgError(err)
+	}
+
+	if !options.KeepAudit {
+		if _, err := m.tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE uuid = $1`, m.Prefix+"_nodes_audit"), node.Uuid.CleanString()); err != nil {
+			return pgError(err)
+		}
+	}
+
+	if _, err := m.deleteRelations(ctx, node.Uuid, GetEmptyReference(), ""); err != nil {
+		return err
+	}
+
+	if _, err := m.deleteRelations(ctx, GetEmptyReference(), node.Uuid, ""); err != nil {
+		return err
+	}
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Purge: Uuid:%+v - type: %s", node.Uuid, node.Type)
+	}
+
+	return m.sendNotification(ctx, m.Prefix+"_manager_action", &ModelEvent{
+		Type:     node.Type,
+		Action:   "Purge",
+		Subject:  node.Uuid.CleanString(),
+		Revision: node.Revision,
+		Date:     time.Now(),
+		Name:     node.Name,
+	})
+}
+
+func (m *PgNodeManager) FindChildren(uuid Reference) (*list.List, error) {
+	return m.FindChildrenContext(context.Background(), uuid)
+}
+
+func (m *PgNodeManager) FindChildrenContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return findChildren(ctx, m, uuid)
+}
+
+func (m *PgNodeManager) FindDescendants(uuid Reference, depth int) (*list.List, error) {
+	return m.FindDescendantsContext(context.Background(), uuid, depth)
+}
+
+func (m *PgNodeManager) FindDescendantsContext(ctx context.Context, uuid Reference, depth int) (*list.List, error) {
+	return findDescendants(ctx, m, uuid, depth)
+}
+
+func (m *PgNodeManager) FindAncestors(uuid Reference) (*list.List, error) {
+	return m.FindAncestorsContext(context.Background(), uuid)
+}
+
+func (m *PgNodeManager) FindAncestorsContext(ctx context.Context, uuid Reference) (*list.List, error) {
+	return findAncestors(ctx, m, uuid)
+}
+
+func (m *PgNodeManager) Copy(uuid, parent Reference, deep bool) (*Node, error) {
+	return m.CopyContext(context.Background(), uuid, parent, deep)
+}
+
+func (m *PgNodeManager) CopyContext(ctx context.Context, uuid, parent Reference, deep bool) (*Node, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Copy: Uuid:%+v - parent: %+v - deep: %t", uuid, parent, deep)
+	}
+
+	return copyNode(ctx, m, m.Handlers, m.Prefix, uuid, parent, deep)
+}
+
+func (m *PgNodeManager) Reorder(parent Reference, uuids []Reference) (*list.List, error) {
+	return m.ReorderContext(context.Background(), parent, uuids)
+}
+
+func (m *PgNodeManager) ReorderContext(ctx context.Context, parent Reference, uuids []Reference) (*list.List, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Reorder: parent: %+v - children: %d", parent, len(uuids))
+	}
+
+	return reorderChildren(ctx, m, m.Prefix, parent, uuids, func(tx NodeManager, node *Node) error {
+		return tx.(*PgNodeManager).updateWeight(ctx, node)
+	})
+}
+
+// update the weight of the current node only, the audit table is not altered
+func (m *PgNodeManager) updateWeight(ctx context.Context, node *Node) error {
+	_, err := m.runner().ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET weight = $1 WHERE id = $2`, m.Prefix+"_nodes"), node.Weight, node.Id)
+
+	return pgError(err)
+}
+
+func (m *PgNodeManager) Link(relation *Relation) (*Relation, error) {
+	return m.LinkContext(context.Background(), relation)
+}
+
+func (m *PgNodeManager) LinkContext(ctx context.Context, relation *Relation) (*Relation, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Link: from: %+v - to: %+v - kind: %s", relation.From, relation.To, relation.Kind)
+	}
+
+	return linkNodes(ctx, m, m.Prefix, relation, func(tx NodeManager, relation *Relation) error {
+		return tx.(*PgNodeManager).storeRelation(ctx, relation)
+	})
+}
+
+// update the relation if it exists, insert it otherwise
+func (m *PgNodeManager) storeRelation(ctx context.Context, relation *Relation) error {
+	table := m.Prefix + "_relations"
+
+	result, err := m.runner().ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET weight = $1, on_delete = $2 WHERE from_uuid = $3 AND to_uuid = $4 AND kind = $5`, table),
+		relation.Weight,
+		relation.OnDelete,
+		relation.From.CleanString(),
+		relation.To.CleanString(),
+		relation.Kind)
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return pgError(err)
+	}
+
+	_, err = m.runner().ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (from_uuid, to_uuid, kind, weight, on_delete, created_at) VALUES ($1, $2, $3, $4, $5, $6)`, table),
+		relation.From.CleanString(),
+		relation.To.CleanString(),
+		relation.Kind,
+		relation.Weight,
+		relation.OnDelete,
+		relation.CreatedAt)
+
+	return pgError(err)
+}
+
+func (m *PgNodeManager) Unlink(from, to Reference, kind string) (int64, error) {
+	return m.UnlinkContext(context.Background(), from, to, kind)
+}
+
+func (m *PgNodeManager) UnlinkContext(ctx context.Context, from, to Reference, kind string) (int64, error) {
+	PanicIf(m.ReadOnly, "The manager is readonly, cannot alter the datastore")
+
+	if m.Logger != nil {
+		m.Logger.Printf("[PgNode] Unlink: from: %+v - to: %+v - kind: %s", from, to, kind)
+	}
+
+	return unlinkNodes(ctx, m, m.Prefix, from, to, kind, func(tx NodeManager) (int64, error) {
+		return tx.(*PgNodeManager).deleteRelations(ctx, from, to, kind)
+	})
+}
+
+func (m *PgNodeManager) deleteRelations(ctx context.Context, from, to Reference, kind string) (int64, error) {
+	rawSql, args, err := sq.Delete(m.Prefix + "_relations").
+		Where(relationConditions(from, to, kind)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, err
 	}
 
-	if node.Type == "" {
-		errors.AddError("type", "Type cannot be empty")
+	result, err := m.runner().ExecContext(ctx, rawSql, args...)
+
+	if err != nil {
+		return 0, pgError(err)
 	}
 
-	if node.Status < 0 || node.Status > 3 {
-		errors.AddError("status", "Invalid status")
+	deleted, err := result.RowsAffected()
+
+	return deleted, pgError(err)
+}
+
+func (m *PgNodeManager) FindRelations(from, to Reference, kind string) ([]*Relation, error) {
+	return m.FindRelationsContext(context.Background(), from, to, kind)
+}
+
+// Return the relations matching the references and the kind (an empty reference
+// or an empty kind matches all the values), ordered by kind and weight.
+func (m *PgNodeManager) FindRelationsContext(ctx context.Context, from, to Reference, kind string) ([]*Relation, error) {
+	query := sq.Select("from_uuid", "to_uuid", "kind", "weight", "on_delete", "created_at").
+		From(m.Prefix+"_relations").
+		OrderBy("kind ASC", "weight ASC", "id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	if conditions := relationConditions(from, to, kind); len(conditions) > 0 {
+		query = query.Where(conditions)
 	}
 
-	m.Handlers.Get(node).Validate(node, m, errors)
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.reader(ctx).QueryContext(ctx, rawSql, args...)
+
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	defer rows.Close()
+
+	relations := make([]*Relation, 0)
+
+	for rows.Next() {
+		relation := &Relation{}
+		From, To := "", ""
+
+		if err := rows.Scan(&From, &To, &relation.Kind, &relation.Weight, &relation.OnDelete, &relation.CreatedAt); err != nil {
+			return nil, pgError(err)
+		}
+
+		tmpUuid, _ := uuid.Parse(From)
+		relation.From = GetReference(tmpUuid)
+		tmpUuid, _ = uuid.Parse(To)
+		relation.To = GetReference(tmpUuid)
+
+		relations = append(relations, relation)
+	}
+
+	return relations, pgError(rows.Err())
+}
+
+// the conditions matching the relations, an empty value matches all the values
+func relationConditions(from, to Reference, kind string) sq.Eq {
+	conditions := sq.Eq{}
+
+	if !isEmptyReference(from) {
+		conditions["from_uuid"] = from.CleanString()
+	}
+
+	if !isEmptyReference(to) {
+		conditions["to_uuid"] = to.CleanString()
+	}
+
+	if kind != "" {
+		conditions["kind"] = kind
+	}
 
-	return !errors.HasErrors(), errors
+	return conditions
 }
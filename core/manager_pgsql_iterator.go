@@ -0,0 +1,153 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	"sync/atomic"
+)
+
+// number of rows fetched at once from a cursor
+const pgCursorFetchSize = 256
+
+var pgCursorSequence uint64
+
+// pgNodeIterator reads the rows as they come from the server. Inside a transaction,
+// a server side cursor is used and the rows are fetched by batch, so the transaction
+// can still be used to alter the nodes while iterating.
+type pgNodeIterator struct {
+	ctx     context.Context
+	manager *PgNodeManager
+	rows    *sql.Rows
+	cursor  string
+	buffer  []*Node
+	done    bool
+	closed  bool
+	node    *Node
+	err     error
+}
+
+func (m *PgNodeManager) Iterate(query sq.SelectBuilder) (NodeIterator, error) {
+	return m.IterateContext(context.Background(), query)
+}
+
+// The iterator holds a connection until it is closed
+func (m *PgNodeManager) IterateContext(ctx context.Context, query sq.SelectBuilder) (NodeIterator, error) {
+	rawSql, args, err := query.ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	it := &pgNodeIterator{
+		ctx:     ctx,
+		manager: m,
+	}
+
+	if m.tx == nil {
+		if it.rows, err = m.Db.QueryContext(ctx, rawSql, args...); err != nil {
+			return nil, pgError(err)
+		}
+
+		return it, nil
+	}
+
+	it.cursor = fmt.Sprintf("%s_iterator_%d", m.Prefix, atomic.AddUint64(&pgCursorSequence, 1))
+
+	if _, err = m.tx.ExecContext(ctx, fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", it.cursor, rawSql), args...); err != nil {
+		if m.Logger != nil {
+			m.Logger.Printf("[PgNode] Error while declaring the cursor: `%s`, %s ", rawSql, err)
+		}
+
+		return nil, pgError(err)
+	}
+
+	return it, nil
+}
+
+func (it *pgNodeIterator) Next() bool {
+	if it.closed || it.err != nil {
+		return false
+	}
+
+	if it.rows != nil {
+		if !it.rows.Next() {
+			it.err = pgError(it.rows.Err())
+
+			return false
+		}
+
+		it.node, it.err = it.manager.hydrate(it.rows)
+
+		return it.err == nil
+	}
+
+	if len(it.buffer) == 0 && !it.done {
+		it.err = it.fetch()
+	}
+
+	if it.err != nil || len(it.buffer) == 0 {
+		return false
+	}
+
+	it.node, it.buffer = it.buffer[0], it.buffer[1:]
+
+	return true
+}
+
+// load the next batch of nodes, the rows are closed before returning so the
+// transaction is available to the caller
+func (it *pgNodeIterator) fetch() error {
+	rows, err := it.manager.tx.QueryContext(it.ctx, fmt.Sprintf("FETCH %d FROM %s", pgCursorFetchSize, it.cursor))
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		node, err := it.manager.hydrate(rows)
+
+		if err != nil {
+			return err
+		}
+
+		it.buffer = append(it.buffer, node)
+	}
+
+	it.done = len(it.buffer) < pgCursorFetchSize
+
+	return pgError(rows.Err())
+}
+
+func (it *pgNodeIterator) Node() *Node {
+	return it.node
+}
+
+func (it *pgNodeIterator) Err() error {
+	return it.err
+}
+
+func (it *pgNodeIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+
+	it.closed = true
+	it.buffer = nil
+
+	if it.rows != nil {
+		return pgError(it.rows.Close())
+	}
+
+	_, err := it.manager.tx.ExecContext(it.ctx, "CLOSE "+it.cursor)
+
+	return pgError(err)
+}
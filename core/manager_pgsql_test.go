@@ -0,0 +1,41 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_PgNodeManager_Reader(t *testing.T) {
+	// the connections are opened lazily, no database is required
+	master, _ := sql.Open("postgres", "postgres://localhost/master")
+	replica1, _ := sql.Open("postgres", "postgres://localhost/replica1")
+	replica2, _ := sql.Open("postgres", "postgres://localhost/replica2")
+
+	m := &PgNodeManager{Db: master}
+
+	assert.Equal(t, master, m.reader(context.Background()))
+
+	m.Replicas = []*sql.DB{replica1, replica2}
+
+	first := m.reader(context.Background())
+	second := m.reader(context.Background())
+
+	assert.NotEqual(t, master, first)
+	assert.NotEqual(t, master, second)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, first, m.reader(context.Background()))
+
+	assert.Equal(t, master, m.reader(WithReadYourWrites(context.Background())))
+}
+
+func Test_ReadYourWrites(t *testing.T) {
+	assert.False(t, ReadYourWrites(context.Background()))
+	assert.True(t, ReadYourWrites(WithReadYourWrites(context.Background())))
+}
@@ -0,0 +1,402 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	"time"
+)
+
+// NodeTree is a node with its children, the children are ordered by weight
+type NodeTree struct {
+	Node     *Node       `json:"node"`
+	Children []*NodeTree `json:"children"`
+}
+
+// Build the tree of the root node, the descendants must be ordered by depth
+// (see FindDescendants). The descendants without a parent in the list are ignored.
+func BuildNodeTree(root *Node, descendants *list.List) *NodeTree {
+	tree := &NodeTree{Node: root, Children: make([]*NodeTree, 0)}
+
+	trees := map[string]*NodeTree{
+		root.Uuid.CleanString(): tree,
+	}
+
+	for e := descendants.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*Node)
+
+		parent, ok := trees[node.ParentUuid.CleanString()]
+
+		if !ok {
+			continue
+		}
+
+		sub := &NodeTree{Node: node, Children: make([]*NodeTree, 0)}
+		parent.Children = append(parent.Children, sub)
+		trees[node.Uuid.CleanString()] = sub
+	}
+
+	return tree
+}
+
+// return the node used as the starting point of a tree query, the deleted nodes
+// are not part of the tree
+func findTreeNode(ctx context.Context, m NodeManager, uuid Reference) (*Node, error) {
+	node, err := m.FindContext(ctx, uuid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil || node.Deleted {
+		return nil, NotFoundError
+	}
+
+	return node, nil
+}
+
+// the direct children ordered by weight
+func findChildren(ctx context.Context, m NodeManager, uuid Reference) (*list.List, error) {
+	node, err := findTreeNode(ctx, m, uuid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.SelectBuilder(NewSelectOptions()).
+		Where("parent_uuid = ?", node.Uuid.CleanString()).
+		Where("deleted = ?", false).
+		OrderBy("weight ASC", "id ASC")
+
+	return iterateToList(ctx, m, query)
+}
+
+// the descendants up to the depth (all of them if depth is 0) ordered by depth,
+// then by weight. The parents column holds the uuids of the ancestors starting
+// from the root, so the GIN index on this column is used.
+func findDescendants(ctx context.Context, m NodeManager, uuid Reference, depth int) (*list.List, error) {
+	node, err := findTreeNode(ctx, m, uuid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.SelectBuilder(NewSelectOptions()).
+		Where("parents @> ARRAY[?]::uuid[]", node.Uuid.CleanString()).
+		Where("deleted = ?", false).
+		OrderBy("cardinality(parents) ASC", "weight ASC", "id ASC")
+
+	if depth > 0 {
+		query = query.Where("cardinality(parents) <= ?", len(node.Parents)+depth)
+	}
+
+	return iterateToList(ctx, m, query)
+}
+
+// the ancestors starting from the root
+func findAncestors(ctx context.Context, m NodeManager, uuid Reference) (*list.List, error) {
+	node, err := findTreeNode(ctx, m, uuid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(node.Parents) == 0 {
+		return list.New(), nil
+	}
+
+	uuids := make([]string, 0, len(node.Parents))
+
+	for _, p := range node.Parents {
+		uuids = append(uuids, p.CleanString())
+	}
+
+	query := m.SelectBuilder(NewSelectOptions()).
+		Where(sq.Eq{"uuid": uuids}).
+		OrderBy("cardinality(parents) ASC")
+
+	return iterateToList(ctx, m, query)
+}
+
+// Copy the node, and its descendants if deep is true, under the parent (an empty
+// reference copies the node at the root level). The copies get new uuids and keep
+// the uuid of the copied node in Source. Once the transaction is committed, the
+// handlers implementing HandlerCopy duplicate their data, ie the vault's binaries.
+func copyNode(ctx context.Context, m NodeManager, handlers Handlers, prefix string, uuid, parent Reference, deep bool) (*Node, error) {
+	var root *Node
+
+	sources := make([]*Node, 0)
+	copies := make([]*Node, 0)
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		source, err := findTreeNode(ctx, tx, uuid)
+
+		if err != nil {
+			return err
+		}
+
+		parents := make([]Reference, 0)
+
+		if !isEmptyReference(parent) {
+			p, err := findTreeNode(ctx, tx, parent)
+
+			if err != nil {
+				return err
+			}
+
+			parents = append(append(parents, p.Parents...), p.Uuid)
+		}
+
+		// the subtree is loaded before saving the copies, so a node copied
+		// inside its own subtree is only copied once
+		descendants := list.New()
+
+		if deep {
+			if descendants, err = findDescendants(ctx, tx, uuid, 0); err != nil {
+				return err
+			}
+		}
+
+		if root, err = newCopy(handlers, source, parent, parents); err != nil {
+			return err
+		}
+
+		if root.Slug, err = copySlug(ctx, tx, parent, source.Slug); err != nil {
+			return err
+		}
+
+		if _, err := tx.SaveContext(ctx, root, true); err != nil {
+			return err
+		}
+
+		sources = append(sources, source)
+		copies = append(copies, root)
+
+		copied := map[string]*Node{
+			source.Uuid.CleanString(): root,
+		}
+
+		// the descendants are ordered by depth, so the parent is always copied first
+		for e := descendants.Front(); e != nil; e = e.Next() {
+			node := e.Value.(*Node)
+
+			p, ok := copied[node.ParentUuid.CleanString()]
+
+			if !ok {
+				continue
+			}
+
+			c, err := newCopy(handlers, node, p.Uuid, append(append(make([]Reference, 0, len(p.Parents)+1), p.Parents...), p.Uuid))
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.SaveContext(ctx, c, true); err != nil {
+				return err
+			}
+
+			sources = append(sources, node)
+			copies = append(copies, c)
+			copied[node.Uuid.CleanString()] = c
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Type:     root.Type,
+			Action:   "Copy",
+			Subject:  root.Uuid.CleanString(),
+			Revision: root.Revision,
+			Date:     root.CreatedAt,
+			Name:     root.Name,
+			Extra:    source.Uuid.CleanString(),
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return root, copyHandlers(handlers, sources, copies)
+}
+
+// create an unsaved copy of the node, the data and the meta are copied with the handler
+func newCopy(handlers Handlers, source *Node, parent Reference, parents []Reference) (*Node, error) {
+	node := *source
+
+	node.Id = 0
+	node.Uuid = GetEmptyReference()
+	node.Source = source.Uuid
+	node.ParentUuid = parent
+	node.Parents = parents
+	node.Revision = 1
+	node.CreatedAt = time.Now()
+	node.UpdatedAt = node.CreatedAt
+
+	err := handlers.Get(source).Load(InterfaceToJsonMessage(source.Type, source.Data), InterfaceToJsonMessage(source.Type, source.Meta), &node)
+
+	return &node, err
+}
+
+// the slug is unique for a parent, a suffix is added if the parent already has a
+// child with the same slug
+func copySlug(ctx context.Context, m NodeManager, parent Reference, slug string) (string, error) {
+	candidate := slug
+
+	for i := 1; ; i++ {
+		count, err := m.CountContext(ctx, m.SelectBuilder(NewSelectOptions()).
+			Where("parent_uuid = ?", parent.CleanString()).
+			Where("slug = ?", candidate))
+
+		if err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return candidate, nil
+		}
+
+		if i == 1 {
+			candidate = slug + "-copy"
+		} else {
+			candidate = fmt.Sprintf("%s-copy-%d", slug, i)
+		}
+	}
+}
+
+// Duplicate the data stored by the handlers for the copied nodes, the first error
+// is returned once all the nodes are processed.
+func copyHandlers(handlers Handlers, sources []*Node, copies []*Node) error {
+	var first error
+
+	for i, source := range sources {
+		h, ok := handlers.Get(source).(HandlerCopy)
+
+		if !ok {
+			continue
+		}
+
+		if err := h.Copy(source, copies[i]); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Set the weight of the parent's children following the order of the uuids, the
+// children not listed are moved after them and keep their order. Only the weight
+// is updated by the update function: no revision is created and one Reorder event
+// is sent. The children are returned in the new order.
+func reorderChildren(ctx context.Context, m NodeManager, prefix string, parent Reference, uuids []Reference, update func(tx NodeManager, node *Node) error) (*list.List, error) {
+	ordered := list.New()
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		node, err := findTreeNode(ctx, tx, parent)
+
+		if err != nil {
+			return err
+		}
+
+		children, err := findChildren(ctx, tx, parent)
+
+		if err != nil {
+			return err
+		}
+
+		nodes := map[string]*Node{}
+
+		for e := children.Front(); e != nil; e = e.Next() {
+			nodes[e.Value.(*Node).Uuid.CleanString()] = e.Value.(*Node)
+		}
+
+		// the uuids must be distinct children of the parent
+		for _, uuid := range uuids {
+			child, ok := nodes[uuid.CleanString()]
+
+			if !ok {
+				return ValidationError
+			}
+
+			ordered.PushBack(child)
+			delete(nodes, uuid.CleanString())
+		}
+
+		for e := children.Front(); e != nil; e = e.Next() {
+			if _, ok := nodes[e.Value.(*Node).Uuid.CleanString()]; ok {
+				ordered.PushBack(e.Value)
+			}
+		}
+
+		weight := 0
+
+		for e := ordered.Front(); e != nil; e = e.Next() {
+			child := e.Value.(*Node)
+			weight++
+
+			if child.Weight == weight {
+				continue
+			}
+
+			child.Weight = weight
+
+			if err := update(tx, child); err != nil {
+				return err
+			}
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Type:     node.Type,
+			Action:   "Reorder",
+			Subject:  node.Uuid.CleanString(),
+			Revision: node.Revision,
+			Date:     time.Now(),
+			Name:     node.Name,
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ordered, nil
+}
+
+// load all the nodes matching the query, FindBy requires a limit
+func iterateToList(ctx context.Context, m NodeManager, query sq.SelectBuilder) (*list.List, error) {
+	it, err := m.IterateContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer it.Close()
+
+	nodes := list.New()
+
+	for it.Next() {
+		nodes.PushBack(it.Node())
+	}
+
+	return nodes, it.Err()
+}
+
+// the event sent once a node is moved, the extra value is the new parent
+func newMoveEvent(uuid, parentUuid Reference) *ModelEvent {
+	return &ModelEvent{
+		Action:  "Move",
+		Subject: uuid.CleanString(),
+		Date:    time.Now(),
+		Extra:   parentUuid.CleanString(),
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"container/list"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// root
+//
+//	├── a (weight 2)
+//	│   └── a1
+//	│       └── a11
+//	└── b (weight 1)
+func getTreeFixtures() (*InMemoryNodeManager, map[string]*Node) {
+	m := getMemoryManager()
+
+	nodes := map[string]*Node{}
+
+	for _, name := range []string{"root", "a", "b", "a1", "a11"} {
+		node := m.NewNode("core.user")
+		node.Name = name
+		m.Save(node, false)
+
+		nodes[name] = node
+	}
+
+	nodes["a"].Weight = 2
+	m.Save(nodes["a"], false)
+
+	m.Move(nodes["a"].Uuid, nodes["root"].Uuid)
+	m.Move(nodes["b"].Uuid, nodes["root"].Uuid)
+	m.Move(nodes["a1"].Uuid, nodes["a"].Uuid)
+	m.Move(nodes["a11"].Uuid, nodes["a1"].Uuid)
+
+	return m, nodes
+}
+
+func getTreeNames(l *list.List) []string {
+	names := []string{}
+
+	for e := l.Front(); e != nil; e = e.Next() {
+		names = append(names, e.Value.(*Node).Name)
+	}
+
+	return names
+}
+
+func Test_FindChildren(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	children, err := m.FindChildren(nodes["root"].Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "a"}, getTreeNames(children))
+
+	children, err = m.FindChildren(nodes["a11"].Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, children.Len())
+
+	_, err = m.FindChildren(GetRootReference())
+
+	assert.Equal(t, NotFoundError, err)
+}
+
+func Test_FindDescendants(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	descendants, err := m.FindDescendants(nodes["root"].Uuid, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "a", "a1", "a11"}, getTreeNames(descendants))
+
+	descendants, err = m.FindDescendants(nodes["root"].Uuid, 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "a", "a1"}, getTreeNames(descendants))
+
+	descendants, err = m.FindDescendants(nodes["a"].Uuid, 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a1"}, getTreeNames(descendants))
+
+	// the deleted nodes are not part of the tree
+	m.RemoveOne(m.Find(nodes["b"].Uuid))
+
+	descendants, err = m.FindDescendants(nodes["root"].Uuid, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "a1", "a11"}, getTreeNames(descendants))
+}
+
+func Test_FindAncestors(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	ancestors, err := m.FindAncestors(nodes["a11"].Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"root", "a", "a1"}, getTreeNames(ancestors))
+
+	ancestors, err = m.FindAncestors(nodes["root"].Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, ancestors.Len())
+}
+
+func Test_BuildNodeTree(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	root := m.Find(nodes["root"].Uuid)
+	descendants, _ := m.FindDescendants(root.Uuid, 0)
+
+	tree := BuildNodeTree(root, descendants)
+
+	assert.Equal(t, "root", tree.Node.Name)
+	assert.Equal(t, 2, len(tree.Children))
+	assert.Equal(t, "b", tree.Children[0].Node.Name)
+	assert.Equal(t, 0, len(tree.Children[0].Children))
+	assert.Equal(t, "a", tree.Children[1].Node.Name)
+	assert.Equal(t, "a1", tree.Children[1].Children[0].Node.Name)
+	assert.Equal(t, "a11", tree.Children[1].Children[0].Children[0].Node.Name)
+}
+
+func Test_Copy(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	node, err := m.Copy(nodes["a"].Uuid, nodes["b"].Uuid, true)
+
+	assert.Nil(t, err)
+	assert.NotEqual(t, nodes["a"].Uuid, node.Uuid)
+	assert.Equal(t, nodes["a"].Uuid, node.Source)
+	assert.Equal(t, nodes["a"].Slug, node.Slug)
+	assert.Equal(t, nodes["b"].Uuid, node.ParentUuid)
+	assert.Equal(t, []Reference{nodes["root"].Uuid, nodes["b"].Uuid}, node.Parents)
+	assert.Equal(t, 1, node.Revision)
+
+	descendants, err := m.FindDescendants(nodes["b"].Uuid, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "a1", "a11"}, getTreeNames(descendants))
+
+	a11 := descendants.Back().Value.(*Node)
+
+	assert.Equal(t, nodes["a11"].Uuid, a11.Source)
+	assert.Equal(t, []Reference{nodes["root"].Uuid, nodes["b"].Uuid, node.Uuid, descendants.Front().Next().Value.(*Node).Uuid}, a11.Parents)
+
+	// the source is not altered
+	descendants, err = m.FindDescendants(nodes["a"].Uuid, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a1", "a11"}, getTreeNames(descendants))
+}
+
+func Test_Copy_Shallow_Same_Parent(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	node, err := m.Copy(nodes["a"].Uuid, nodes["root"].Uuid, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, nodes["a"].Slug+"-copy", node.Slug)
+
+	node, err = m.Copy(nodes["a"].Uuid, nodes["root"].Uuid, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, nodes["a"].Slug+"-copy-2", node.Slug)
+
+	children, err := m.FindChildren(node.Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, children.Len())
+
+	_, err = m.Copy(GetRootReference(), nodes["root"].Uuid, false)
+
+	assert.Equal(t, NotFoundError, err)
+}
+
+func Test_Reorder(t *testing.T) {
+	m, nodes := getTreeFixtures()
+
+	c := m.NewNode("core.user")
+	c.Name = "c"
+	m.Save(c, false)
+	m.Move(c.Uuid, nodes["root"].Uuid)
+
+	// the children not listed are moved after the listed ones
+	children, err := m.Reorder(nodes["root"].Uuid, []Reference{c.Uuid, nodes["a"].Uuid})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "a", "b"}, getTreeNames(children))
+
+	children, err = m.FindChildren(nodes["root"].Uuid)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "a", "b"}, getTreeNames(children))
+	assert.Equal(t, 3, children.Back().Value.(*Node).Weight)
+
+	// no revision is created
+	assert.Equal(t, nodes["a"].Revision, m.Find(nodes["a"].Uuid).Revision)
+
+	_, err = m.Reorder(nodes["root"].Uuid, []Reference{nodes["a1"].Uuid})
+
+	assert.Equal(t, ValidationError, err)
+
+	_, err = m.Reorder(nodes["root"].Uuid, []Reference{nodes["a"].Uuid, nodes["a"].Uuid})
+
+	assert.Equal(t, ValidationError, err)
+
+	children, _ = m.FindChildren(nodes["root"].Uuid)
+
+	assert.Equal(t, []string{"c", "a", "b"}, getTreeNames(children))
+}
@@ -0,0 +1,123 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	sq "github.com/lann/squirrel"
+)
+
+// the number of nodes migrated in one transaction by MigrateNodes
+const migrateBatchSize = 100
+
+// NodeMigration converts the raw data and meta of a node to the next version
+type NodeMigration func(data, meta json.RawMessage) (json.RawMessage, json.RawMessage, error)
+
+// return the current data and meta structure version of the handler's nodes
+func GetHandlerVersion(h Handler) int {
+	if hm, ok := h.(HandlerMigration); ok {
+		return hm.GetVersion()
+	}
+
+	return 1
+}
+
+// Apply the migrations from the node's version to the handler's version, the
+// node's version is updated once all the migrations are applied.
+func migrateNode(h Handler, node *Node, data, meta json.RawMessage) (json.RawMessage, json.RawMessage, error) {
+	hm, ok := h.(HandlerMigration)
+
+	if !ok || node.Version >= hm.GetVersion() {
+		return data, meta, nil
+	}
+
+	migrations := hm.GetMigrations()
+
+	for version := node.Version; version < hm.GetVersion(); version++ {
+		migration, ok := migrations[version]
+
+		if !ok {
+			return nil, nil, fmt.Errorf("No migration from version %d for the type %s", version, node.Type)
+		}
+
+		var err error
+
+		if data, meta, err = migration(data, meta); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	node.Version = hm.GetVersion()
+
+	return data, meta, nil
+}
+
+// Load the data and the meta of a stored node with the handler, the node is
+// migrated first if it has been stored with an older version.
+func loadNode(h Handler, data, meta json.RawMessage, node *Node) error {
+	data, meta, err := migrateNode(h, node, data, meta)
+
+	if err != nil {
+		return err
+	}
+
+	return h.Load(data, meta, node)
+}
+
+// Rewrite the nodes matching the query stored with an older version than the one
+// of their handler, by batch of migrateBatchSize nodes per transaction. The nodes
+// are migrated while being loaded, then the update function provided by the
+// manager stores the data, the meta and the version only: no revision is created
+// and no event is sent. The number of migrated nodes is returned.
+func migrateNodes(ctx context.Context, m NodeManager, handlers Handlers, query sq.SelectBuilder, update func(tx NodeManager, node *Node) error) (int64, error) {
+	var migrated int64
+
+	for _, code := range handlers.GetKeys() {
+		h, ok := handlers.GetByCode(code).(HandlerMigration)
+
+		if !ok {
+			continue
+		}
+
+		outdated := query.Where(sq.Eq{"type": code}).Where("version < ?", h.GetVersion())
+
+		for {
+			count := 0
+
+			err := m.TransactionContext(ctx, func(tx NodeManager) error {
+				nodes, err := tx.FindByContext(ctx, outdated, 0, migrateBatchSize)
+
+				if err != nil {
+					return err
+				}
+
+				for e := nodes.Front(); e != nil; e = e.Next() {
+					if err := update(tx, e.Value.(*Node)); err != nil {
+						return err
+					}
+
+					count++
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return migrated, err
+			}
+
+			migrated += int64(count)
+
+			if count < migrateBatchSize {
+				break
+			}
+		}
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,112 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// the version 2 of the user sets the name from the username
+type MigratedUserHandler struct {
+	UserHandler
+
+	Version int
+}
+
+func (h *MigratedUserHandler) GetVersion() int {
+	return h.Version
+}
+
+func (h *MigratedUserHandler) GetMigrations() map[int]NodeMigration {
+	return map[int]NodeMigration{
+		1: func(data, meta json.RawMessage) (json.RawMessage, json.RawMessage, error) {
+			values := map[string]interface{}{}
+
+			if err := json.Unmarshal(data, &values); err != nil {
+				return nil, nil, err
+			}
+
+			values["name"] = values["username"]
+
+			data, err := json.Marshal(values)
+
+			return data, meta, err
+		},
+	}
+}
+
+func getMigrationFixtures() (*InMemoryNodeManager, *Node) {
+	m := getMemoryManager()
+
+	node := m.NewNode("core.user")
+	node.Name = "User A"
+	node.Data.(*User).Username = "user-a"
+
+	m.Save(node, false)
+
+	m.Handlers = HandlerCollection{
+		"core.user": &MigratedUserHandler{Version: 2},
+	}
+
+	return m, node
+}
+
+func Test_Migration_Hydrate(t *testing.T) {
+	m, node := getMigrationFixtures()
+
+	assert.Equal(t, 1, node.Version)
+
+	node = m.Find(node.Uuid)
+
+	assert.Equal(t, 2, node.Version)
+	assert.Equal(t, "user-a", node.Data.(*User).Name)
+
+	// the new nodes are created with the current version
+	assert.Equal(t, 2, m.NewNode("core.user").Version)
+}
+
+func Test_Migration_Missing(t *testing.T) {
+	m, node := getMigrationFixtures()
+
+	m.Handlers = HandlerCollection{
+		"core.user": &MigratedUserHandler{Version: 3},
+	}
+
+	_, err := m.FindContext(context.Background(), node.Uuid)
+
+	assert.IsType(t, &DecodeError{}, err)
+}
+
+func Test_MigrateNodes(t *testing.T) {
+	m, node := getMigrationFixtures()
+
+	outdated := m.SelectBuilder(NewSelectOptions()).Where("version < ?", 2)
+
+	count, _ := m.Count(outdated)
+	assert.Equal(t, uint64(1), count)
+
+	migrated, err := m.MigrateNodes(m.SelectBuilder(NewSelectOptions()))
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), migrated)
+
+	count, _ = m.Count(outdated)
+	assert.Equal(t, uint64(0), count)
+
+	// no revision is created
+	saved := m.Find(node.Uuid)
+
+	assert.Equal(t, node.Revision, saved.Revision)
+	assert.Equal(t, "user-a", saved.Data.(*User).Name)
+
+	migrated, err = m.MigrateNodes(m.SelectBuilder(NewSelectOptions()))
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), migrated)
+}
@@ -0,0 +1,449 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchError is returned when a patch document is invalid or when an operation
+// cannot be applied, ie the path does not exist.
+type PatchError struct {
+	Op      string
+	Path    string
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("Invalid patch: %s", e.Message)
+	}
+
+	return fmt.Sprintf("Unable to apply the %s operation on %s: %s", e.Op, e.Path, e.Message)
+}
+
+// PatchTestError is returned when the value of a test operation does not match
+// the document, so the test operations can be used as preconditions.
+type PatchTestError struct {
+	Path string
+}
+
+func (e *PatchTestError) Error() string {
+	return fmt.Sprintf("The test operation failed on %s", e.Path)
+}
+
+func (o *PatchOperation) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for name, field := range map[string]*string{"op": &o.Op, "path": &o.Path, "from": &o.From} {
+		if _, ok := raw[name]; !ok {
+			continue
+		}
+
+		if err := json.Unmarshal(raw[name], field); err != nil {
+			return err
+		}
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		if _, ok := raw["value"]; !ok {
+			return &PatchError{Message: fmt.Sprintf("the %s operation requires a value", o.Op)}
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw["value"]))
+		decoder.UseNumber()
+
+		return decoder.Decode(&o.Value)
+	case "move", "copy":
+		if _, ok := raw["from"]; !ok {
+			return &PatchError{Message: fmt.Sprintf("the %s operation requires a from", o.Op)}
+		}
+	case "remove":
+	default:
+		return &PatchError{Message: fmt.Sprintf("unknown operation %q", o.Op)}
+	}
+
+	return nil
+}
+
+// Apply the operations in order to a value decoded from json, the value is
+// altered. The first failing operation stops the patch.
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	var err error
+
+	for _, o := range p {
+		if doc, err = o.apply(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func (o *PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(o.Path)
+
+	if err != nil {
+		return nil, &PatchError{Op: o.Op, Path: o.Path, Message: err.Error()}
+	}
+
+	var value interface{}
+
+	switch o.Op {
+	case "add":
+		doc, err = addJsonValue(doc, path, copyJsonValue(o.Value))
+	case "remove":
+		doc, _, err = removeJsonValue(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return copyJsonValue(o.Value), nil
+		}
+
+		if doc, _, err = removeJsonValue(doc, path); err == nil {
+			doc, err = addJsonValue(doc, path, copyJsonValue(o.Value))
+		}
+	case "test":
+		if value, err = getJsonValue(doc, path); err == nil && !isEqualJsonValue(value, o.Value) {
+			return nil, &PatchTestError{Path: o.Path}
+		}
+	case "move", "copy":
+		var from []string
+
+		if from, err = parsePointer(o.From); err != nil {
+			break
+		}
+
+		if o.Op == "move" {
+			if strings.HasPrefix(o.Path, o.From+"/") {
+				return nil, &PatchError{Op: o.Op, Path: o.Path, Message: "a value cannot be moved into one of its children"}
+			}
+
+			doc, value, err = removeJsonValue(doc, from)
+		} else if value, err = getJsonValue(doc, from); err == nil {
+			value = copyJsonValue(value)
+		}
+
+		if err == nil {
+			doc, err = addJsonValue(doc, path, value)
+		}
+	default:
+		err = fmt.Errorf("unknown operation")
+	}
+
+	if err != nil {
+		return nil, &PatchError{Op: o.Op, Path: o.Path, Message: err.Error()}
+	}
+
+	return doc, nil
+}
+
+// Apply a RFC 7396 JSON Merge Patch to a value decoded from json: the objects
+// are merged recursively, a null value removes the key and the other values
+// replace the target value.
+func MergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	target, ok := doc.(map[string]interface{})
+
+	if !ok {
+		target = map[string]interface{}{}
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(target, key)
+		} else {
+			target[key] = MergePatch(target[key], value)
+		}
+	}
+
+	return target
+}
+
+// Apply the patch to the json representation of the node, the json of the
+// patched node is returned.
+func PatchNode(node *Node, patch Patch) ([]byte, error) {
+	doc, err := toJsonValue(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if doc, err = patch.Apply(doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+// Apply the merge patch to the json representation of the node, the json of
+// the patched node is returned.
+func MergePatchNode(node *Node, patch interface{}) ([]byte, error) {
+	doc, err := toJsonValue(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(MergePatch(doc, patch))
+}
+
+// decode a RFC 6901 JSON Pointer, the empty pointer is the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("the pointer must start with a /")
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// return the index of the token in an array, the "-" token is the end of the
+// array if allowed
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+
+	i, err := strconv.Atoi(token)
+
+	if err != nil || i < 0 || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	if i > length || (i == length && !end) {
+		return 0, fmt.Errorf("the array index %d is out of bounds", i)
+	}
+
+	return i, nil
+}
+
+func getJsonValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+
+			if !ok {
+				return nil, fmt.Errorf("the key %q does not exist", token)
+			}
+
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("the value is not a container")
+		}
+	}
+
+	return doc, nil
+}
+
+// alter the container of the last token, the parent values are updated as the
+// arrays might be reallocated.
+func alterJsonValue(doc interface{}, path []string, f func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		child, ok := v[path[0]]
+
+		if !ok {
+			return nil, fmt.Errorf("the key %q does not exist", path[0])
+		}
+
+		child, err := alterJsonValue(child, path[1:], f)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v[path[0]] = child
+
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(v), false)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if v[i], err = alterJsonValue(v[i], path[1:], f); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("the value is not a container")
+}
+
+func addJsonValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return alterJsonValue(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), true)
+
+			if err != nil {
+				return nil, err
+			}
+
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+
+			return v, nil
+		}
+
+		return nil, fmt.Errorf("the value is not a container")
+	})
+}
+
+func removeJsonValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("the whole document cannot be removed")
+	}
+
+	var removed interface{}
+
+	doc, err := alterJsonValue(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+
+			if !ok {
+				return nil, fmt.Errorf("the key %q does not exist", token)
+			}
+
+			removed = value
+			delete(v, token)
+
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			removed = v[i]
+
+			return append(v[:i], v[i+1:]...), nil
+		}
+
+		return nil, fmt.Errorf("the value is not a container")
+	})
+
+	return doc, removed, err
+}
+
+func copyJsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+
+		for key, value := range v {
+			c[key] = copyJsonValue(value)
+		}
+
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+
+		for i, value := range v {
+			c[i] = copyJsonValue(value)
+		}
+
+		return c
+	}
+
+	return value
+}
+
+// compare the values recursively, the numbers are compared by value
+func isEqualJsonValue(a, b interface{}) bool {
+	switch v := a.(type) {
+	case map[string]interface{}:
+		w, ok := b.(map[string]interface{})
+
+		if !ok || len(v) != len(w) {
+			return false
+		}
+
+		for key, value := range v {
+			if other, ok := w[key]; !ok || !isEqualJsonValue(value, other) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		w, ok := b.([]interface{})
+
+		if !ok || len(v) != len(w) {
+			return false
+		}
+
+		for i := range v {
+			if !isEqualJsonValue(v[i], w[i]) {
+				return false
+			}
+		}
+
+		return true
+	case json.Number:
+		w, ok := b.(json.Number)
+
+		if !ok {
+			return false
+		}
+
+		x, errA := v.Float64()
+		y, errB := w.Float64()
+
+		return errA == nil && errB == nil && x == y
+	}
+
+	return isSameJsonValue(a, b)
+}
@@ -0,0 +1,167 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getPatch(t *testing.T, data string) Patch {
+	patch := Patch{}
+
+	assert.Nil(t, json.Unmarshal([]byte(data), &patch))
+
+	return patch
+}
+
+func Test_Patch_Apply(t *testing.T) {
+	doc, _ := toJsonValue(map[string]interface{}{
+		"title":  "Hello",
+		"tags":   []string{"a", "b"},
+		"a/b":    1,
+		"author": map[string]interface{}{"name": "Thomas"},
+	})
+
+	patch := getPatch(t, `[
+		{"op": "test", "path": "/a~1b", "value": 1.0},
+		{"op": "replace", "path": "/title", "value": "Hello world"},
+		{"op": "add", "path": "/tags/1", "value": "c"},
+		{"op": "add", "path": "/tags/-", "value": "d"},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "copy", "from": "/author/name", "path": "/author/alias"},
+		{"op": "move", "from": "/author/name", "path": "/name"},
+		{"op": "add", "path": "/author/age", "value": 30}
+	]`)
+
+	doc, err := patch.Apply(doc)
+
+	assert.Nil(t, err)
+
+	expected, _ := toJsonValue(map[string]interface{}{
+		"title":  "Hello world",
+		"tags":   []string{"c", "b", "d"},
+		"a/b":    1,
+		"name":   "Thomas",
+		"author": map[string]interface{}{"alias": "Thomas", "age": 30},
+	})
+
+	assert.Equal(t, expected, doc)
+
+	// the whole document is replaced
+	doc, err = getPatch(t, `[{"op": "replace", "path": "", "value": {"a": 1}}]`).Apply(doc)
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"a": json.Number("1")}, doc)
+}
+
+func Test_Patch_Apply_Errors(t *testing.T) {
+	for _, data := range []string{
+		`[{"op": "replace", "path": "/missing", "value": 1}]`,
+		`[{"op": "remove", "path": "/tags/2"}]`,
+		`[{"op": "add", "path": "/tags/01", "value": 1}]`,
+		`[{"op": "add", "path": "/title/a", "value": 1}]`,
+		`[{"op": "add", "path": "title", "value": 1}]`,
+		`[{"op": "move", "from": "/author", "path": "/author/name"}]`,
+		`[{"op": "remove", "path": ""}]`,
+	} {
+		doc, _ := toJsonValue(map[string]interface{}{
+			"title":  "Hello",
+			"tags":   []string{"a", "b"},
+			"author": map[string]interface{}{"name": "Thomas"},
+		})
+
+		_, err := getPatch(t, data).Apply(doc)
+
+		assert.IsType(t, &PatchError{}, err, data)
+	}
+
+	doc, _ := toJsonValue(map[string]interface{}{"revision": 3, "tags": []string{"a"}})
+
+	_, err := getPatch(t, `[{"op": "test", "path": "/tags", "value": ["a"]}, {"op": "test", "path": "/revision", "value": 2}]`).Apply(doc)
+
+	assert.Equal(t, &PatchTestError{Path: "/revision"}, err)
+
+	// invalid documents
+	for _, data := range []string{
+		`[{"op": "add", "path": "/title"}]`,
+		`[{"op": "copy", "path": "/title"}]`,
+		`[{"op": "rename", "path": "/title"}]`,
+	} {
+		err := json.Unmarshal([]byte(data), &Patch{})
+
+		assert.IsType(t, &PatchError{}, err, data)
+	}
+}
+
+func Test_Patch_Serialization(t *testing.T) {
+	patch := Patch{
+		&PatchOperation{Op: "add", Path: "/a", Value: nil},
+		&PatchOperation{Op: "move", From: "/a", Path: "/b"},
+		&PatchOperation{Op: "remove", Path: "/b"},
+	}
+
+	data, _ := json.Marshal(patch)
+
+	assert.Equal(t, `[{"op":"add","path":"/a","value":null},{"op":"move","from":"/a","path":"/b"},{"op":"remove","path":"/b"}]`, string(data))
+	assert.Equal(t, patch, getPatch(t, string(data)))
+}
+
+func Test_MergePatch(t *testing.T) {
+	doc, _ := toJsonValue(map[string]interface{}{
+		"title": "Hello",
+		"tags":  []string{"a", "b"},
+		"author": map[string]interface{}{
+			"name": "Thomas",
+			"age":  30,
+		},
+	})
+
+	var patch interface{}
+
+	json.Unmarshal([]byte(`{"title": "Hello world", "tags": ["c"], "author": {"age": null, "city": "Paris"}, "new": {"a": null}}`), &patch)
+
+	expected, _ := toJsonValue(map[string]interface{}{
+		"title": "Hello world",
+		"tags":  []string{"c"},
+		"author": map[string]interface{}{
+			"name": "Thomas",
+			"city": "Paris",
+		},
+		"new": map[string]interface{}{},
+	})
+
+	assert.Equal(t, expected, MergePatch(doc, patch))
+	assert.Equal(t, "value", MergePatch(doc, "value"))
+}
+
+func Test_PatchNode(t *testing.T) {
+	node := NewNode()
+	node.Type = "core.user"
+	node.Name = "User A"
+	node.Revision = 3
+	node.Data = &User{Name: "user-a"}
+
+	data, err := PatchNode(node, getPatch(t, `[{"op": "test", "path": "/revision", "value": 3}, {"op": "replace", "path": "/data/name", "value": "user-b"}]`))
+
+	assert.Nil(t, err)
+
+	patched := map[string]interface{}{}
+	json.Unmarshal(data, &patched)
+
+	assert.Equal(t, "User A", patched["name"])
+	assert.Equal(t, "user-b", patched["data"].(map[string]interface{})["name"])
+
+	data, err = MergePatchNode(node, map[string]interface{}{"name": "User B"})
+
+	assert.Nil(t, err)
+
+	json.Unmarshal(data, &patched)
+
+	assert.Equal(t, "User B", patched["name"])
+	assert.Equal(t, "user-a", patched["data"].(map[string]interface{})["name"])
+}
@@ -7,6 +7,7 @@ package core
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
 	pq "github.com/lib/pq"
 	"log"
@@ -28,8 +29,18 @@ type Listener interface {
 	Handle(notification *pq.Notification, manager NodeManager) (int, error)
 }
 
+// ListenerContext is the context aware version of the Listener, the context is
+// canceled when the subscriber stops.
+type ListenerContext interface {
+	Listener
+
+	HandleContext(ctx context.Context, notification *pq.Notification, manager NodeManager) (int, error)
+}
+
 type SubscriberHander func(notification *pq.Notification) (int, error)
 
+type SubscriberHanderContext func(ctx context.Context, notification *pq.Notification) (int, error)
+
 type ModelEvent struct {
 	Subject     string    `json:"subject"`
 	Action      string    `json:"action"`
@@ -41,11 +52,18 @@ type ModelEvent struct {
 	NewRevision bool      `json:"new_revision"`
 }
 
+// Create a new subscriber, if the conninfo is empty the notifications are not
+// received from PostgreSQL but from the Publish function.
 func NewSubscriber(conninfo string, logger *log.Logger) *Subscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Subscriber{
+		ctx:      ctx,
+		cancel:   cancel,
 		conninfo: conninfo,
 		handlers: make(map[string]*list.List, 1024),
 		exit:     make(chan int),
+		notify:   make(chan *pq.Notification, 1024),
 		logger:   logger,
 		channels: make([]string, 0),
 	}
@@ -60,9 +78,12 @@ func CreateModelEvent(notification *pq.Notification) *ModelEvent {
 }
 
 type Subscriber struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
 	conninfo string
 	handlers map[string]*list.List
 	listener *pq.Listener
+	notify   chan *pq.Notification
 	exit     chan int
 	init     bool
 	logger   *log.Logger
@@ -73,7 +94,11 @@ func (s *Subscriber) Stop() {
 	s.logger.Printf("Sending a stop to channel subscriber\n")
 
 	s.exit <- 1
-	s.listener.Close()
+	s.cancel()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
 }
 
 func (s *Subscriber) Register() {
@@ -83,6 +108,12 @@ func (s *Subscriber) Register() {
 
 	s.init = true
 
+	if s.conninfo == "" {
+		go s.waitAndDispatch()
+
+		return
+	}
+
 	// listen to the specific channel
 	s.listener = pq.NewListener(s.conninfo, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
 		if err != nil {
@@ -95,6 +126,8 @@ func (s *Subscriber) Register() {
 		PanicOnError(err)
 	}
 
+	s.notify = s.listener.Notify
+
 	go s.waitAndDispatch()
 }
 
@@ -102,7 +135,7 @@ func (s *Subscriber) waitAndDispatch() {
 	// iterate over received notifications, for now, we start only one consumer with no concurrence
 	for {
 		select {
-		case notification := <-s.listener.Notify:
+		case notification := <-s.notify:
 
 			if notification == nil {
 				s.logger.Printf("pubsub:handler:%s - rreceived a nil notification, the underlying driver reconnect", notification.Channel)
@@ -115,21 +148,28 @@ func (s *Subscriber) waitAndDispatch() {
 			if _, ok := s.handlers[notification.Channel]; ok {
 				// go some handlers register
 				for e := s.handlers[notification.Channel].Front(); e != nil; e = e.Next() {
-					go func(f SubscriberHander) {
+					go func(f SubscriberHanderContext) {
 						s.logger.Printf("pubsub:handler:%s - payload:%s", notification.Channel, notification.Extra)
 
-						if state, _ := f(notification); state != PubSubListenContinue {
+						ctx, cancel := context.WithCancel(s.ctx)
+						defer cancel()
+
+						if state, _ := f(ctx, notification); state != PubSubListenContinue {
 							// close listener
 							s.handlers[notification.Channel].Remove(e)
 							s.logger.Printf("pubsub:handler:%s - removing on handler for channel - state != PubSubListenContinue", notification.Channel)
 						}
-					}(e.Value.(SubscriberHander))
+					}(e.Value.(SubscriberHanderContext))
 				}
 			} else {
 				s.logger.Printf("pubsub:handler:%s - skipping, no handler for channel", notification.Channel)
 			}
 
 		case <-time.After(20 * time.Second):
+			if s.listener == nil {
+				continue
+			}
+
 			go func() {
 				s.listener.Ping()
 			}()
@@ -145,10 +185,18 @@ func (s *Subscriber) waitAndDispatch() {
 }
 
 func (s *Subscriber) ListenMessage(name string, handler SubscriberHander) {
+	s.ListenMessageContext(name, func(ctx context.Context, notification *pq.Notification) (int, error) {
+		return handler(notification)
+	})
+}
+
+// The handler receives a context canceled once the handler returns or when the
+// subscriber stops.
+func (s *Subscriber) ListenMessageContext(name string, handler SubscriberHanderContext) {
 	if _, ok := s.handlers[name]; !ok {
 		s.handlers[name] = list.New()
 
-		if s.init {
+		if s.init && s.listener != nil {
 			err := s.listener.Listen(name)
 			PanicOnError(err)
 		} else {
@@ -158,3 +206,20 @@ func (s *Subscriber) ListenMessage(name string, handler SubscriberHander) {
 
 	s.handlers[name].PushBack(handler)
 }
+
+// Send a notification to the registered handlers without PostgreSQL, the
+// notification is dropped if the subscriber is connected to PostgreSQL or
+// if the queue is full.
+func (s *Subscriber) Publish(channel string, payload string) {
+	if s.conninfo != "" {
+		s.logger.Printf("pubsub:handler:%s - skipping, the subscriber is connected to PostgreSQL", channel)
+
+		return
+	}
+
+	select {
+	case s.notify <- &pq.Notification{Channel: channel, Extra: payload}:
+	default:
+		s.logger.Printf("pubsub:handler:%s - dropping notification, the queue is full", channel)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// the relation is removed with one of the nodes
+	RelationUnlink = "unlink"
+	// the related node is removed with the node holding the relation
+	RelationCascade = "cascade"
+)
+
+// Relation is a typed link from a node to another one, ie the cover image of a
+// blog post. The relations are stored in the <prefix>_relations table, a relation
+// is unique for a (From, To, Kind) triplet.
+type Relation struct {
+	From      Reference `json:"from"`
+	To        Reference `json:"to"`
+	Kind      string    `json:"kind"`
+	Weight    int       `json:"weight"`
+	OnDelete  string    `json:"on_delete"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewRelation(from, to Reference, kind string) *Relation {
+	return &Relation{
+		From:      from,
+		To:        to,
+		Kind:      kind,
+		Weight:    1,
+		OnDelete:  RelationUnlink,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Store the relation once both nodes are found, the weight and the delete rule
+// of an existing relation are updated. The store function is provided by the
+// manager and runs inside the transaction.
+func linkNodes(ctx context.Context, m NodeManager, prefix string, relation *Relation, store func(tx NodeManager, relation *Relation) error) (*Relation, error) {
+	if relation.OnDelete == "" {
+		relation.OnDelete = RelationUnlink
+	}
+
+	if relation.Kind == "" || relation.From.CleanString() == relation.To.CleanString() {
+		return nil, ValidationError
+	}
+
+	if relation.OnDelete != RelationUnlink && relation.OnDelete != RelationCascade {
+		return nil, ValidationError
+	}
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) error {
+		from, err := findTreeNode(ctx, tx, relation.From)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := findTreeNode(ctx, tx, relation.To); err != nil {
+			return err
+		}
+
+		relation.CreatedAt = time.Now()
+
+		if err := store(tx, relation); err != nil {
+			return err
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Type:     from.Type,
+			Action:   "Link",
+			Subject:  from.Uuid.CleanString(),
+			Revision: from.Revision,
+			Date:     relation.CreatedAt,
+			Name:     from.Name,
+			Extra:    relation.To.CleanString(),
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return relation, nil
+}
+
+// Delete the relations matching the references and the kind, an empty reference
+// or an empty kind matches all the values. The delete function is provided by the
+// manager and the number of deleted relations is returned.
+func unlinkNodes(ctx context.Context, m NodeManager, prefix string, from, to Reference, kind string, delete func(tx NodeManager) (int64, error)) (int64, error) {
+	if isEmptyReference(from) && isEmptyReference(to) {
+		return 0, ValidationError
+	}
+
+	var deleted int64
+
+	err := m.TransactionContext(ctx, func(tx NodeManager) (err error) {
+		if deleted, err = delete(tx); err != nil || deleted == 0 {
+			return err
+		}
+
+		data, _ := json.Marshal(&ModelEvent{
+			Action:  "Unlink",
+			Subject: from.CleanString(),
+			Date:    time.Now(),
+			Extra:   to.CleanString(),
+		})
+
+		return tx.NotifyContext(ctx, prefix+"_manager_action", string(data))
+	})
+
+	return deleted, err
+}
+
+// Apply the delete rules of the relations once the node is removed: the related
+// nodes are removed if the rule is RelationCascade, then all the relations from
+// and to the node are removed. The node must be saved as deleted before, so a
+// cycle of cascade relations ends.
+func removeRelations(ctx context.Context, m NodeManager, node *Node) error {
+	relations, err := m.FindRelationsContext(ctx, node.Uuid, GetEmptyReference(), "")
+
+	if err != nil {
+		return err
+	}
+
+	for _, relation := range relations {
+		if relation.OnDelete != RelationCascade {
+			continue
+		}
+
+		related, err := m.FindContext(ctx, relation.To)
+
+		if err != nil {
+			return err
+		}
+
+		if related == nil || related.Deleted {
+			continue
+		}
+
+		if _, err := m.RemoveOneContext(ctx, related); err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.UnlinkContext(ctx, node.Uuid, GetEmptyReference(), ""); err != nil {
+		return err
+	}
+
+	_, err = m.UnlinkContext(ctx, GetEmptyReference(), node.Uuid, "")
+
+	return err
+}
+
+// check if the relation matches the references and the kind, see unlinkNodes
+func matchRelation(relation *Relation, from, to Reference, kind string) bool {
+	if !isEmptyReference(from) && relation.From.CleanString() != from.CleanString() {
+		return false
+	}
+
+	if !isEmptyReference(to) && relation.To.CleanString() != to.CleanString() {
+		return false
+	}
+
+	return kind == "" || relation.Kind == kind
+}
+
+// the references are pointers, so the values are compared
+func isEmptyReference(reference Reference) bool {
+	return reference.CleanString() == emptyUuid.CleanString()
+}
@@ -0,0 +1,152 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getRelationFixtures() (*InMemoryNodeManager, []*Node) {
+	m := getMemoryManager()
+
+	nodes := make([]*Node, 0)
+
+	for _, name := range []string{"post", "cover", "related"} {
+		node := m.NewNode("core.user")
+		node.Name = name
+		m.Save(node, false)
+
+		nodes = append(nodes, node)
+	}
+
+	return m, nodes
+}
+
+func Test_Link(t *testing.T) {
+	m, nodes := getRelationFixtures()
+
+	relation, err := m.Link(NewRelation(nodes[0].Uuid, nodes[1].Uuid, "cover"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, RelationUnlink, relation.OnDelete)
+
+	r := NewRelation(nodes[0].Uuid, nodes[2].Uuid, "related")
+	r.Weight = 2
+
+	_, err = m.Link(r)
+	assert.Nil(t, err)
+
+	r = NewRelation(nodes[0].Uuid, nodes[1].Uuid, "related")
+	r.Weight = 1
+
+	_, err = m.Link(r)
+	assert.Nil(t, err)
+
+	relations, err := m.FindRelations(nodes[0].Uuid, GetEmptyReference(), "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(relations))
+	assert.Equal(t, "cover", relations[0].Kind)
+	assert.Equal(t, nodes[1].Uuid, relations[1].To)
+	assert.Equal(t, nodes[2].Uuid, relations[2].To)
+
+	// the relation is updated
+	r = NewRelation(nodes[0].Uuid, nodes[1].Uuid, "related")
+	r.Weight = 3
+
+	_, err = m.Link(r)
+	assert.Nil(t, err)
+
+	relations, err = m.FindRelations(nodes[0].Uuid, GetEmptyReference(), "related")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(relations))
+	assert.Equal(t, nodes[2].Uuid, relations[0].To)
+
+	relations, err = m.FindRelations(GetEmptyReference(), nodes[1].Uuid, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(relations))
+}
+
+func Test_Link_Invalid(t *testing.T) {
+	m, nodes := getRelationFixtures()
+
+	_, err := m.Link(NewRelation(nodes[0].Uuid, nodes[1].Uuid, ""))
+	assert.Equal(t, ValidationError, err)
+
+	_, err = m.Link(NewRelation(nodes[0].Uuid, nodes[0].Uuid, "self"))
+	assert.Equal(t, ValidationError, err)
+
+	r := NewRelation(nodes[0].Uuid, nodes[1].Uuid, "cover")
+	r.OnDelete = "restrict"
+
+	_, err = m.Link(r)
+	assert.Equal(t, ValidationError, err)
+
+	_, err = m.Link(NewRelation(nodes[0].Uuid, GetRootReference(), "cover"))
+	assert.Equal(t, NotFoundError, err)
+}
+
+func Test_Unlink(t *testing.T) {
+	m, nodes := getRelationFixtures()
+
+	m.Link(NewRelation(nodes[0].Uuid, nodes[1].Uuid, "cover"))
+	m.Link(NewRelation(nodes[0].Uuid, nodes[1].Uuid, "related"))
+	m.Link(NewRelation(nodes[0].Uuid, nodes[2].Uuid, "related"))
+
+	deleted, err := m.Unlink(nodes[0].Uuid, nodes[1].Uuid, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	// the relations are restored on rollback
+	m.Transaction(func(tx NodeManager) error {
+		tx.Unlink(nodes[0].Uuid, GetEmptyReference(), "related")
+
+		return errors.New("rollback")
+	})
+
+	relations, _ := m.FindRelations(nodes[0].Uuid, GetEmptyReference(), "")
+	assert.Equal(t, 1, len(relations))
+
+	_, err = m.Unlink(GetEmptyReference(), GetEmptyReference(), "related")
+	assert.Equal(t, ValidationError, err)
+}
+
+func Test_RemoveOne_Relations(t *testing.T) {
+	m, nodes := getRelationFixtures()
+
+	cover := NewRelation(nodes[0].Uuid, nodes[1].Uuid, "cover")
+	cover.OnDelete = RelationCascade
+
+	m.Link(cover)
+	m.Link(NewRelation(nodes[0].Uuid, nodes[2].Uuid, "related"))
+	m.Link(NewRelation(nodes[2].Uuid, nodes[0].Uuid, "related"))
+
+	// a cycle of cascade relations
+	back := NewRelation(nodes[1].Uuid, nodes[0].Uuid, "post")
+	back.OnDelete = RelationCascade
+
+	m.Link(back)
+
+	_, err := m.RemoveOne(m.Find(nodes[0].Uuid))
+
+	assert.Nil(t, err)
+	assert.True(t, m.Find(nodes[1].Uuid).Deleted)
+	assert.False(t, m.Find(nodes[2].Uuid).Deleted)
+
+	relations, _ := m.FindRelations(GetEmptyReference(), nodes[2].Uuid, "")
+	assert.Equal(t, 0, len(relations))
+
+	relations, _ = m.FindRelations(nodes[2].Uuid, GetEmptyReference(), "")
+	assert.Equal(t, 0, len(relations))
+
+	relations, _ = m.FindRelations(nodes[1].Uuid, GetEmptyReference(), "")
+	assert.Equal(t, 0, len(relations))
+}
@@ -0,0 +1,232 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaMigration is a versioned change of the database schema. The versions
+// are ordered, a timestamp (ie 20160131120000) avoids the conflicts between the
+// plugins. The {prefix} placeholder of the statements is replaced by the table prefix.
+type SchemaMigration struct {
+	Version int64
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// SchemaMigrations collects the migrations of the core and of the plugins
+type SchemaMigrations struct {
+	migrations []*SchemaMigration
+}
+
+// Add the migrations, the versions must be unique
+func (s *SchemaMigrations) Add(migrations ...*SchemaMigration) {
+	for _, migration := range migrations {
+		for _, m := range s.migrations {
+			PanicIf(m.Version == migration.Version, fmt.Sprintf("The migration version %d is already used by %s", m.Version, m.Name))
+		}
+
+		s.migrations = append(s.migrations, migration)
+	}
+}
+
+// return the migrations ordered by version
+func (s *SchemaMigrations) All() []*SchemaMigration {
+	migrations := make([]*SchemaMigration, len(s.migrations))
+	copy(migrations, s.migrations)
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations
+}
+
+// SchemaStatus is the state of a migration, AppliedAt is nil for a pending migration
+type SchemaStatus struct {
+	Migration *SchemaMigration
+	AppliedAt *time.Time
+}
+
+// SchemaMigrator applies the migrations to the PostgreSQL database, the applied
+// versions are stored in the <prefix>_migrations table. Each migration runs in
+// its own transaction.
+type SchemaMigrator struct {
+	Db         *sql.DB
+	Prefix     string
+	Logger     *log.Logger
+	Migrations *SchemaMigrations
+}
+
+func (m *SchemaMigrator) table() string {
+	return m.Prefix + "_migrations"
+}
+
+func (m *SchemaMigrator) createTable(ctx context.Context) error {
+	_, err := m.Db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (
+		"version" BIGINT NOT NULL,
+		"name" CHARACTER VARYING( 255 ) COLLATE "pg_catalog"."default" NOT NULL,
+		"applied_at" TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+		PRIMARY KEY ( "version" )
+	)`, m.table()))
+
+	return pgError(err)
+}
+
+// return the applied versions with their date
+func (m *SchemaMigrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.Db.QueryContext(ctx, fmt.Sprintf(`SELECT version, applied_at FROM "%s"`, m.table()))
+
+	if err != nil {
+		return nil, pgError(err)
+	}
+
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, pgError(err)
+		}
+
+		versions[version] = appliedAt
+	}
+
+	return versions, pgError(rows.Err())
+}
+
+// Return the status of the migrations ordered by version
+func (m *SchemaMigrator) Status(ctx context.Context) ([]*SchemaStatus, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]*SchemaStatus, 0)
+
+	for _, migration := range m.Migrations.All() {
+		s := &SchemaStatus{Migration: migration}
+
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// Apply the pending migrations in order, the applied migrations are returned. The
+// first error stops the process, the failing migration is rolled back.
+func (m *SchemaMigrator) Migrate(ctx context.Context) ([]*SchemaMigration, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := make([]*SchemaMigration, 0)
+
+	for _, migration := range m.Migrations.All() {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.run(ctx, migration, migration.Up, fmt.Sprintf(`INSERT INTO "%s" (version, name, applied_at) VALUES ($1, $2, $3)`, m.table()),
+			migration.Version, migration.Name, time.Now())
+
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated = append(migrated, migration)
+	}
+
+	return migrated, nil
+}
+
+// Revert the last applied migrations in reverse order, the reverted migrations
+// are returned.
+func (m *SchemaMigrator) Rollback(ctx context.Context, steps int) ([]*SchemaMigration, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := m.Migrations.All()
+	reverted := make([]*SchemaMigration, 0)
+
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := migrations[i]
+
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.run(ctx, migration, migration.Down, fmt.Sprintf(`DELETE FROM "%s" WHERE version = $1`, m.table()), migration.Version)
+
+		if err != nil {
+			return reverted, err
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// Revert all the applied migrations
+func (m *SchemaMigrator) Reset(ctx context.Context) ([]*SchemaMigration, error) {
+	return m.Rollback(ctx, len(m.Migrations.All()))
+}
+
+// run the statements and the query updating the migrations table in one transaction
+func (m *SchemaMigrator) run(ctx context.Context, migration *SchemaMigration, statements []string, query string, args ...interface{}) error {
+	if m.Logger != nil {
+		m.Logger.Printf("[Schema] Running migration %d: %s", migration.Version, migration.Name)
+	}
+
+	tx, err := m.Db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return pgError(err)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, strings.Replace(statement, "{prefix}", m.Prefix, -1)); err != nil {
+			tx.Rollback()
+
+			return fmt.Errorf("Migration %d (%s) failed: %s", migration.Version, migration.Name, pgError(err))
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+
+		return pgError(err)
+	}
+
+	return pgError(tx.Commit())
+}
@@ -0,0 +1,112 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"fmt"
+)
+
+// the columns shared by the nodes and the nodes_audit tables
+const nodeColumns = `"id" INTEGER DEFAULT nextval('{prefix}_%s_id_seq'::regclass) NOT NULL UNIQUE,
+	"uuid" UUid NOT NULL,
+	"type" CHARACTER VARYING( 64 ) COLLATE "pg_catalog"."default" NOT NULL,
+	"name" CHARACTER VARYING( 2044 ) COLLATE "pg_catalog"."default" DEFAULT ''::CHARACTER VARYING NOT NULL,
+	"enabled" BOOLEAN DEFAULT 'true' NOT NULL,
+	"current" BOOLEAN DEFAULT 'false' NOT NULL,
+	"revision" INTEGER DEFAULT '1' NOT NULL,
+	"version" INTEGER DEFAULT '1' NOT NULL,
+	"status" INTEGER DEFAULT '0' NOT NULL,
+	"deleted" BOOLEAN DEFAULT 'false' NOT NULL,
+	"data" jsonb DEFAULT '{}'::jsonb NOT NULL,
+	"meta" jsonb DEFAULT '{}'::jsonb NOT NULL,
+	"slug" CHARACTER VARYING( 256 ) COLLATE "default" NOT NULL,
+	"source" UUid,
+	"set_uuid" UUid,
+	"parent_uuid" UUid,
+	"parents" UUid[],
+	"created_at" TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+	"created_by" UUid NOT NULL,
+	"updated_at" TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+	"updated_by" UUid NOT NULL,
+	"weight" INTEGER DEFAULT '0' NOT NULL,
+	PRIMARY KEY ( "id" )`
+
+// Return the migrations of the tables used by the PgNodeManager. The first ones
+// do not fail if the tables already exist, so a schema created before the
+// migrations table can be migrated.
+func GetNodeSchemaMigrations() []*SchemaMigration {
+	return []*SchemaMigration{
+		{
+			Version: 20150101000000,
+			Name:    "create_nodes",
+			Up: []string{
+				`CREATE SEQUENCE IF NOT EXISTS "{prefix}_nodes_id_seq" INCREMENT 1 MINVALUE 0 MAXVALUE 2147483647 START 1 CACHE 1`,
+				`CREATE TABLE IF NOT EXISTS "{prefix}_nodes" (
+					` + fmt.Sprintf(nodeColumns, "nodes") + `,
+					CONSTRAINT "{prefix}_slug" UNIQUE( "parent_uuid","slug","revision" ),
+					CONSTRAINT "{prefix}_uuid" UNIQUE( "revision","uuid" )
+				)`,
+				`CREATE INDEX IF NOT EXISTS "{prefix}_uuid_idx" ON "{prefix}_nodes" USING btree( "uuid" ASC NULLS LAST )`,
+				`CREATE INDEX IF NOT EXISTS "{prefix}_uuid_current_idx" ON "{prefix}_nodes" USING btree( "uuid" ASC NULLS LAST, "current" ASC NULLS LAST )`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS "{prefix}_nodes"`,
+				`DROP SEQUENCE IF EXISTS "{prefix}_nodes_id_seq" CASCADE`,
+			},
+		},
+		{
+			Version: 20150101000001,
+			Name:    "create_nodes_audit",
+			Up: []string{
+				`CREATE SEQUENCE IF NOT EXISTS "{prefix}_nodes_audit_id_seq" INCREMENT 1 MINVALUE 0 MAXVALUE 2147483647 START 1 CACHE 1`,
+				`CREATE TABLE IF NOT EXISTS "{prefix}_nodes_audit" (
+					` + fmt.Sprintf(nodeColumns, "nodes_audit") + `
+				)`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS "{prefix}_nodes_audit"`,
+				`DROP SEQUENCE IF EXISTS "{prefix}_nodes_audit_id_seq" CASCADE`,
+			},
+		},
+		{
+			// tree queries: the descendants and the ancestors use the parents array, the children use the parent_uuid
+			Version: 20160101000000,
+			Name:    "create_tree_indexes",
+			Up: []string{
+				`CREATE INDEX IF NOT EXISTS "{prefix}_parents_idx" ON "{prefix}_nodes" USING gin( "parents" )`,
+				`CREATE INDEX IF NOT EXISTS "{prefix}_parent_uuid_weight_idx" ON "{prefix}_nodes" USING btree( "parent_uuid" ASC NULLS LAST, "weight" ASC NULLS LAST )`,
+			},
+			Down: []string{
+				`DROP INDEX IF EXISTS "{prefix}_parents_idx"`,
+				`DROP INDEX IF EXISTS "{prefix}_parent_uuid_weight_idx"`,
+			},
+		},
+		{
+			// the relations between the nodes, the from_uuid column is indexed by the unique constraint
+			Version: 20160102000000,
+			Name:    "create_relations",
+			Up: []string{
+				`CREATE SEQUENCE IF NOT EXISTS "{prefix}_relations_id_seq" INCREMENT 1 MINVALUE 0 MAXVALUE 2147483647 START 1 CACHE 1`,
+				`CREATE TABLE IF NOT EXISTS "{prefix}_relations" (
+					"id" INTEGER DEFAULT nextval('{prefix}_relations_id_seq'::regclass) NOT NULL UNIQUE,
+					"from_uuid" UUid NOT NULL,
+					"to_uuid" UUid NOT NULL,
+					"kind" CHARACTER VARYING( 64 ) COLLATE "pg_catalog"."default" NOT NULL,
+					"weight" INTEGER DEFAULT '0' NOT NULL,
+					"on_delete" CHARACTER VARYING( 16 ) COLLATE "pg_catalog"."default" DEFAULT 'unlink'::CHARACTER VARYING NOT NULL,
+					"created_at" TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+					PRIMARY KEY ( "id" ),
+					CONSTRAINT "{prefix}_relation" UNIQUE( "from_uuid","to_uuid","kind" )
+				)`,
+				`CREATE INDEX IF NOT EXISTS "{prefix}_relations_to_uuid_idx" ON "{prefix}_relations" USING btree( "to_uuid" ASC NULLS LAST )`,
+			},
+			Down: []string{
+				`DROP TABLE IF EXISTS "{prefix}_relations"`,
+				`DROP SEQUENCE IF EXISTS "{prefix}_relations_id_seq" CASCADE`,
+			},
+		},
+	}
+}
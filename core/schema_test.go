@@ -0,0 +1,42 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_SchemaMigrations_All(t *testing.T) {
+	migrations := &SchemaMigrations{}
+	migrations.Add(&SchemaMigration{Version: 3, Name: "c"}, &SchemaMigration{Version: 1, Name: "a"})
+	migrations.Add(&SchemaMigration{Version: 2, Name: "b"})
+
+	names := []string{}
+
+	for _, migration := range migrations.All() {
+		names = append(names, migration.Name)
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	assert.Panics(t, func() {
+		migrations.Add(&SchemaMigration{Version: 2, Name: "d"})
+	})
+}
+
+func Test_GetNodeSchemaMigrations(t *testing.T) {
+	migrations := &SchemaMigrations{}
+
+	assert.NotPanics(t, func() {
+		migrations.Add(GetNodeSchemaMigrations()...)
+	})
+
+	for _, migration := range migrations.All() {
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
@@ -50,27 +50,41 @@ func (s *Serializer) Deserialize(r io.Reader, o interface{}) error {
 	var buffer bytes.Buffer
 	read, err := buffer.ReadFrom(r)
 
-	reader := bytes.NewReader(buffer.Bytes())
+	if err != nil {
+		return err
+	}
 
-	PanicOnError(err)
-	PanicIf(read == 0, "no data read from the request")
+	if read == 0 {
+		return EmptyDataError
+	}
+
+	reader := bytes.NewReader(buffer.Bytes())
 
 	switch o.(type) {
 	case *Node:
 		node := o.(*Node)
 		if node.Type == "" {
 			// we need to deserialize twice to load the correct Meta/Data structure
-			err := Deserialize(reader, node)
-
-			PanicOnError(err)
+			if err := Deserialize(reader, node); err != nil {
+				return err
+			}
 
 			reader.Seek(0, 0)
 			node.Data, node.Meta = s.Handlers.Get(node).GetStruct()
 		}
 
 		if _, ok := s.deserializers[node.Type]; ok {
-			return s.deserializers[node.Type](reader, node)
+			err = s.deserializers[node.Type](reader, node)
+		} else {
+			err = Deserialize(reader, node)
+		}
+
+		// the data and the meta are decoded with the current structure
+		if s.Handlers != nil {
+			node.Version = GetHandlerVersion(s.Handlers.Get(node))
 		}
+
+		return err
 	}
 
 	return Deserialize(reader, o)
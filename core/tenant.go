@@ -0,0 +1,105 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+const DefaultTenant = "default"
+
+// Tenant is an isolated set of nodes sharing the server with the other tenants:
+// the nodes are stored in the tables with the tenant prefix, the binaries in the
+// vault of the handlers and the tokens are signed with the tenant guard key.
+type Tenant struct {
+	Name     string
+	Prefix   string
+	Manager  NodeManager
+	Handlers Handlers
+	GuardKey []byte
+}
+
+// return the channel used by the manager to notify the actions on the nodes
+func (t *Tenant) ActionChannel() string {
+	return t.Prefix + "_manager_action"
+}
+
+// Tenants is the registry of the tenants, the default one is used when no tenant
+// is resolved for a request.
+type Tenants struct {
+	Default *Tenant
+	tenants map[string]*Tenant
+}
+
+func NewTenants(tenant *Tenant) *Tenants {
+	t := &Tenants{
+		Default: tenant,
+		tenants: make(map[string]*Tenant),
+	}
+
+	t.Add(tenant)
+
+	return t
+}
+
+// Add the tenant, the names and the prefixes must be unique
+func (t *Tenants) Add(tenant *Tenant) {
+	for _, existing := range t.tenants {
+		PanicIf(existing.Name == tenant.Name, fmt.Sprintf("The tenant %s is already registered", tenant.Name))
+		PanicIf(existing.Prefix == tenant.Prefix, fmt.Sprintf("The prefix %s is already used by the tenant %s", tenant.Prefix, existing.Name))
+	}
+
+	t.tenants[tenant.Name] = tenant
+}
+
+// return the tenant or nil if the tenant does not exist
+func (t *Tenants) Get(name string) *Tenant {
+	return t.tenants[name]
+}
+
+// return the tenants, the default one first and then by name
+func (t *Tenants) All() []*Tenant {
+	tenants := make([]*Tenant, 0, len(t.tenants))
+
+	for _, tenant := range t.tenants {
+		tenants = append(tenants, tenant)
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		if tenants[i] == t.Default || tenants[j] == t.Default {
+			return tenants[i] == t.Default
+		}
+
+		return tenants[i].Name < tenants[j].Name
+	})
+
+	return tenants
+}
+
+// return the tenant stored in the context, or the default tenant
+func (t *Tenants) FromContext(ctx context.Context) *Tenant {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return tenant
+	}
+
+	return t.Default
+}
+
+type tenantContextKey int
+
+const tenantKey tenantContextKey = 0
+
+func NewTenantContext(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantKey).(*Tenant)
+
+	return tenant, ok
+}
@@ -0,0 +1,47 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package core
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Tenants(t *testing.T) {
+	tenants := NewTenants(&Tenant{Name: DefaultTenant, Prefix: "test"})
+	tenants.Add(&Tenant{Name: "foo", Prefix: "foo"})
+	tenants.Add(&Tenant{Name: "acme", Prefix: "acme"})
+
+	names := []string{}
+
+	for _, tenant := range tenants.All() {
+		names = append(names, tenant.Name)
+	}
+
+	assert.Equal(t, []string{DefaultTenant, "acme", "foo"}, names)
+	assert.Equal(t, "acme_manager_action", tenants.Get("acme").ActionChannel())
+	assert.Nil(t, tenants.Get("bar"))
+
+	assert.Panics(t, func() {
+		tenants.Add(&Tenant{Name: "acme", Prefix: "bar"})
+	})
+
+	assert.Panics(t, func() {
+		tenants.Add(&Tenant{Name: "bar", Prefix: "acme"})
+	})
+}
+
+func Test_Tenants_FromContext(t *testing.T) {
+	tenants := NewTenants(&Tenant{Name: DefaultTenant, Prefix: "test"})
+	tenants.Add(&Tenant{Name: "acme", Prefix: "acme"})
+
+	assert.Equal(t, tenants.Default, tenants.FromContext(context.Background()))
+
+	ctx := NewTenantContext(context.Background(), tenants.Get("acme"))
+
+	assert.Equal(t, tenants.Get("acme"), tenants.FromContext(ctx))
+}
@@ -7,21 +7,85 @@ package helper
 
 import (
 	"encoding/json"
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/middleware"
+	"log"
 	"net/http"
+	"runtime/debug"
+	"strings"
 )
 
-func SendWithHttpCode(res http.ResponseWriter, code int, message string) {
+const (
+	RequestIdHeader = "X-Request-Id"
+
+	ERROR_INVALID_PARAMETER = "invalid_parameter"
+	ERROR_AUTHENTICATION    = "authentication_failed"
+	ERROR_INTERNAL          = "internal_error"
+)
+
+// ErrorResponse is the body of all the error responses. The status is always KO,
+// the code is a machine readable identifier of the error and the errors hold
+// the field errors of a validation.
+type ErrorResponse struct {
+	Status    string              `json:"status"`
+	Code      string              `json:"code"`
+	HttpCode  int                 `json:"http_code"`
+	Message   string              `json:"message"`
+	Errors    map[string][]string `json:"errors,omitempty"`
+	RequestId string              `json:"request_id,omitempty"`
+}
+
+// Return the code of the http status, ie not_found or precondition_failed
+func GetErrorCode(httpCode int) string {
+	if text := http.StatusText(httpCode); text != "" {
+		return strings.Replace(strings.ToLower(text), " ", "_", -1)
+	}
+
+	return ERROR_INTERNAL
+}
+
+// Send the error response, the request id is read from the response headers
+func SendErrorResponse(res http.ResponseWriter, e *ErrorResponse) {
+	e.Status = "KO"
+
+	if e.Code == "" {
+		e.Code = GetErrorCode(e.HttpCode)
+	}
+
+	if e.RequestId == "" {
+		e.RequestId = res.Header().Get(RequestIdHeader)
+	}
+
 	res.Header().Set("Content-Type", "application/json")
 
-	res.WriteHeader(code)
+	res.WriteHeader(e.HttpCode)
+
+	data, _ := json.Marshal(e)
+
+	res.Write(data)
+}
+
+func SendError(res http.ResponseWriter, httpCode int, code string, message string) {
+	SendErrorResponse(res, &ErrorResponse{
+		Code:     code,
+		HttpCode: httpCode,
+		Message:  message,
+	})
+}
+
+func SendWithHttpCode(res http.ResponseWriter, code int, message string) {
+	if code < 200 || code >= 300 {
+		SendError(res, code, "", message)
 
-	status := "KO"
-	if code >= 200 && code < 300 {
-		status = "OK"
+		return
 	}
 
+	res.Header().Set("Content-Type", "application/json")
+
+	res.WriteHeader(code)
+
 	data, _ := json.Marshal(map[string]string{
-		"status":  status,
+		"status":  "OK",
 		"message": message,
 	})
 
@@ -29,18 +93,41 @@ func SendWithHttpCode(res http.ResponseWriter, code int, message string) {
 }
 
 func SendWithStatus(status string, message string, res http.ResponseWriter) {
-	res.Header().Set("Content-Type", "application/json")
-
 	if status == "KO" {
-		res.WriteHeader(http.StatusInternalServerError)
+		SendWithHttpCode(res, http.StatusInternalServerError, message)
 	} else {
-		res.WriteHeader(http.StatusOK)
+		SendWithHttpCode(res, http.StatusOK, message)
 	}
+}
 
-	data, _ := json.Marshal(map[string]string{
-		"status":  status,
-		"message": message,
-	})
+// Expose the id set by the RequestID middleware in the X-Request-Id header, so
+// the clients and the error responses can refer to the request.
+func RequestIdMiddleware(c *web.C, h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(*c); id != "" {
+			w.Header().Set(RequestIdHeader, id)
+		}
 
-	res.Write(data)
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Recover the panics of the handlers, the panic is logged with the stack and an
+// internal error is sent, the panic's value is not exposed to the client.
+func Recoverer(c *web.C, h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[%s] panic: %+v\n%s", middleware.GetReqID(*c), err, debug.Stack())
+
+				SendError(w, http.StatusInternalServerError, ERROR_INTERNAL, http.StatusText(http.StatusInternalServerError))
+			}
+		}()
+
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
 }
@@ -0,0 +1,89 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package helper
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getErrorResponse(t *testing.T, w *httptest.ResponseRecorder) *ErrorResponse {
+	e := &ErrorResponse{}
+
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), e))
+
+	return e
+}
+
+func Test_GetErrorCode(t *testing.T) {
+	assert.Equal(t, "not_found", GetErrorCode(http.StatusNotFound))
+	assert.Equal(t, "precondition_failed", GetErrorCode(http.StatusPreconditionFailed))
+	assert.Equal(t, "internal_server_error", GetErrorCode(http.StatusInternalServerError))
+	assert.Equal(t, ERROR_INTERNAL, GetErrorCode(999))
+}
+
+func Test_SendError(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIdHeader, "host/abc-000001")
+
+	SendError(w, http.StatusPreconditionFailed, ERROR_INVALID_PARAMETER, "Invalid `depth` condition")
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, &ErrorResponse{
+		Status:    "KO",
+		Code:      ERROR_INVALID_PARAMETER,
+		HttpCode:  http.StatusPreconditionFailed,
+		Message:   "Invalid `depth` condition",
+		RequestId: "host/abc-000001",
+	}, getErrorResponse(t, w))
+}
+
+func Test_SendWithHttpCode(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	SendWithHttpCode(w, http.StatusOK, "binary stored")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"message":"binary stored","status":"OK"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+
+	SendWithHttpCode(w, http.StatusNotFound, "Element not found")
+
+	e := getErrorResponse(t, w)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "KO", e.Status)
+	assert.Equal(t, "not_found", e.Code)
+	assert.Equal(t, "Element not found", e.Message)
+}
+
+func Test_Recoverer(t *testing.T) {
+	mux := web.New()
+	mux.Use(middleware.RequestID)
+	mux.Use(RequestIdMiddleware)
+	mux.Use(Recoverer)
+	mux.Get("/panic", func(res http.ResponseWriter, req *http.Request) {
+		panic("the secret")
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+
+	e := getErrorResponse(t, w)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ERROR_INTERNAL, e.Code)
+	assert.Equal(t, "Internal Server Error", e.Message)
+	assert.NotEqual(t, "", e.RequestId)
+	assert.Equal(t, w.Header().Get(RequestIdHeader), e.RequestId)
+}
@@ -7,25 +7,55 @@ package api
 
 import (
 	"bytes"
+	"container/list"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	sq "github.com/lann/squirrel"
 	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/search"
 	"io"
 	"log"
+	"strconv"
+	"time"
 )
 
 const (
 	OPERATION_OK = "OK"
 	OPERATION_KO = "KO"
+
+	MERGE_PATCH_CONTENT_TYPE = "application/merge-patch+json"
+	JSON_PATCH_CONTENT_TYPE  = "application/json-patch+json"
+)
+
+var (
+	UnsupportedPatchError = errors.New("Unsupported patch format, use application/merge-patch+json or application/json-patch+json")
+	TypeMismatchError     = errors.New("The type of the node cannot be altered")
 )
 
 type ApiPager struct {
-	Elements []interface{} `json:"elements"`
-	Page     uint64        `json:"page"`
-	PerPage  uint64        `json:"per_page"`
-	Next     uint64        `json:"next"`
-	Previous uint64        `json:"previous"`
+	Elements       []interface{}                     `json:"elements"`
+	Page           uint64                            `json:"page"`
+	PerPage        uint64                            `json:"per_page"`
+	Next           uint64                            `json:"next"`
+	Previous       uint64                            `json:"previous"`
+	NextCursor     string                            `json:"next_cursor,omitempty"`
+	PreviousCursor string                            `json:"previous_cursor,omitempty"`
+	Total          *uint64                           `json:"total,omitempty"`
+	Facets         map[string][]*core.AggregateValue `json:"facets,omitempty"`
+}
+
+// The optional information computed while searching nodes, the total and the
+// facets are computed on the whole result set. The cursors are generated if
+// the OrderBy is set, the query must be ordered by those fields and by the id.
+type FindOptions struct {
+	Total     bool
+	Facets    []string
+	FacetSize uint64
+	OrderBy   []*search.Param
+	Cursor    *search.Cursor
+	Expand    *Expand
 }
 
 type Api struct {
@@ -45,34 +75,109 @@ func (a *Api) SelectBuilder(options *core.SelectOptions) sq.SelectBuilder {
 	return a.Manager.SelectBuilder(options)
 }
 
-func (a *Api) Find(w io.Writer, query sq.SelectBuilder, page uint64, perPage uint64) error {
-	list := a.Manager.FindBy(query, (page-1)*perPage, perPage+1)
+// The context is the request's context, it is forwarded to the manager. The
+// options can be nil.
+func (a *Api) Find(ctx context.Context, w io.Writer, query sq.SelectBuilder, page uint64, perPage uint64, options *FindOptions) error {
+	if options == nil {
+		options = &FindOptions{}
+	}
+
+	pageQuery := query
+	offset := (page - 1) * perPage
+
+	// the cursor replaces the page
+	if options.Cursor != nil {
+		pageQuery = query.Where(search.GetCursorCondition(options.OrderBy, options.Cursor))
+		offset = 0
+		page = 0
+	}
+
+	list, err := a.Manager.FindByContext(ctx, pageQuery, offset, perPage+1)
+
+	if err != nil {
+		return err
+	}
 
 	pager := &ApiPager{
 		Page:    page,
 		PerPage: perPage,
 	}
 
+	if options.Total {
+		total, err := a.Manager.CountContext(ctx, query)
+
+		if err != nil {
+			return err
+		}
+
+		pager.Total = &total
+	}
+
+	if len(options.Facets) > 0 {
+		pager.Facets = make(map[string][]*core.AggregateValue)
+
+		for _, field := range options.Facets {
+			if pager.Facets[field], err = a.Manager.AggregateContext(ctx, query, field, options.FacetSize); err != nil {
+				return err
+			}
+		}
+	}
+
 	pager.Elements = make([]interface{}, 0)
 
+	nodes := make([]*core.Node, 0, perPage)
+	for e := list.Front(); e != nil && uint64(len(nodes)) < perPage; e = e.Next() {
+		nodes = append(nodes, e.Value.(*core.Node))
+	}
+
+	more := uint64(list.Len()) > perPage
+	previous := options.Cursor != nil && options.Cursor.Previous
+
+	if previous { // the nodes located before the cursor are read backward
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	}
+
 	if page > 1 {
 		pager.Previous = page - 1
 	}
 
-	counter := uint64(0)
-	for e := list.Front(); e != nil; e = e.Next() {
-		if counter == perPage {
-			pager.Next = page + 1
-			break
+	if page > 0 && more {
+		pager.Next = page + 1
+	}
+
+	if options.OrderBy != nil && len(nodes) > 0 {
+		if more || previous {
+			if err := a.setCursor(&pager.NextCursor, options.OrderBy, nodes[len(nodes)-1], false); err != nil {
+				return err
+			}
 		}
 
-		b := bytes.NewBuffer([]byte{})
-		a.Serializer.Serialize(b, e.Value.(*core.Node))
+		if (previous && more) || (!previous && (options.Cursor != nil || page > 1)) {
+			if err := a.setCursor(&pager.PreviousCursor, options.OrderBy, nodes[0], true); err != nil {
+				return err
+			}
+		}
+	}
 
-		message := json.RawMessage(b.Bytes())
-		pager.Elements = append(pager.Elements, &message)
+	loaded := map[string]*core.Node{}
 
-		counter++
+	if options.Expand != nil {
+		if loaded, err = a.loadExpanded(ctx, nodes, options.Expand); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range nodes {
+		data, err := a.serializeExpanded(node, options.Expand, loaded, 0)
+
+		if err != nil {
+			return err
+		}
+
+		message := json.RawMessage(data)
+		pager.Elements = append(pager.Elements, &message)
 	}
 
 	core.Serialize(w, pager)
@@ -80,24 +185,67 @@ func (a *Api) Find(w io.Writer, query sq.SelectBuilder, page uint64, perPage uin
 	return nil
 }
 
-func (a *Api) Save(r io.Reader, w io.Writer) error {
-	node := core.NewNode()
+func (a *Api) setCursor(token *string, orderBy []*search.Param, node *core.Node, previous bool) error {
+	cursor, err := search.NewCursor(orderBy, node, previous)
+
+	if err != nil {
+		return err
+	}
 
-	err := a.Serializer.Deserialize(r, node)
+	*token = cursor.String()
 
-	core.PanicOnError(err)
+	return nil
+}
+
+func (a *Api) Save(ctx context.Context, r io.Reader, w io.Writer) error {
+	node := core.NewNode()
+
+	if err := a.Serializer.Deserialize(r, node); err != nil {
+		return &InvalidBodyError{Err: err}
+	}
 
 	if a.Logger != nil {
 		a.Logger.Printf("trying to save node.uuid=%s, node.type=%s", node.Uuid, node.Type)
 	}
 
-	saved := a.Manager.Find(node.Uuid)
+	saved, err := a.Manager.FindContext(ctx, node.Uuid)
+
+	if err != nil {
+		return err
+	}
+
+	if err := a.store(ctx, a.Manager, node, saved); err != nil {
+		return err
+	}
+
+	a.Serializer.Serialize(w, node)
+
+	return nil
+}
+
+// Save the node with the manager, the saved node is nil if the node is a new one.
+// The validation errors are returned with the core.ValidationErrors.
+func (a *Api) store(ctx context.Context, m core.NodeManager, node *core.Node, saved *core.Node) error {
+	if err := checkIfMatch(ctx, saved); err != nil {
+		return err
+	}
 
 	if saved != nil {
 		a.Logger.Printf("find uuid: %s", node.Uuid)
 
-		core.PanicUnless(node.Type == saved.Type, "Type mismatch")
-		core.PanicIf(saved.Deleted, "Cannot save a deleted node, restore it first ...")
+		if node.Type != saved.Type {
+			return TypeMismatchError
+		}
+
+		// the node must be undeleted first
+		if saved.Deleted {
+			return core.AlreadyDeletedError
+		}
+
+		// the If-Match header replaces the revision check of the body
+		if _, ok := IfMatchFromContext(ctx); ok {
+			node.Revision = saved.Revision
+		}
 
 		if node.Revision != saved.Revision {
 			return core.RevisionError
@@ -117,20 +265,103 @@ func (a *Api) Save(r io.Reader, w io.Writer) error {
 		a.Logger.Printf("saving node.id=%d, node.uuid=%s", node.Id, node.Uuid)
 	}
 
-	if ok, errors := a.Manager.Validate(node); !ok {
-		core.Serialize(w, errors)
+	if ok, errors := m.ValidateContext(ctx, node); !ok {
+		return &core.ValidationErrors{Errors: errors}
+	}
 
-		return core.ValidationError
+	if _, err := m.SaveContext(ctx, node, true); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Apply a patch to the stored node, the content type selects the format: a RFC
+// 7396 JSON Merge Patch or a RFC 6902 JSON Patch. The test operations of a JSON
+// Patch can be used as preconditions, ie on the revision. The patched node is
+// validated and saved as a new revision.
+func (a *Api) Patch(ctx context.Context, uuid string, contentType string, r io.Reader, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return err
+	}
+
+	saved, err := a.Manager.FindContext(ctx, reference)
+
+	if err != nil {
+		return err
+	}
+
+	if saved == nil {
+		return core.NotFoundError
+	}
+
+	if saved.Deleted {
+		return core.AlreadyDeletedError
+	}
+
+	if err := checkIfMatch(ctx, saved); err != nil {
+		return err
+	}
+
+	var data []byte
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	switch contentType {
+	case JSON_PATCH_CONTENT_TYPE:
+		patch := core.Patch{}
+
+		if err := decoder.Decode(&patch); err != nil {
+			if e, ok := err.(*core.PatchError); ok {
+				return e
+			}
+
+			return &core.PatchError{Message: err.Error()}
+		}
+
+		data, err = core.PatchNode(saved, patch)
+	case MERGE_PATCH_CONTENT_TYPE:
+		var patch interface{}
+
+		if err := decoder.Decode(&patch); err != nil {
+			return &core.PatchError{Message: err.Error()}
+		}
+
+		data, err = core.MergePatchNode(saved, patch)
+	default:
+		return UnsupportedPatchError
+	}
+
+	if err != nil {
+		return err
 	}
 
-	a.Manager.Save(node, true)
+	// the patched json is decoded with the structures of the stored node's type
+	node := core.NewNode()
+	node.Type = saved.Type
+	node.Data, node.Meta = a.Serializer.Handlers.Get(node).GetStruct()
+
+	if err := a.Serializer.Deserialize(bytes.NewReader(data), node); err != nil {
+		return &core.PatchError{Message: err.Error()}
+	}
+
+	if node.Type != saved.Type || node.Uuid.CleanString() != saved.Uuid.CleanString() {
+		return &core.PatchError{Message: "the uuid and the type cannot be altered"}
+	}
+
+	if err := a.store(ctx, a.Manager, node, saved); err != nil {
+		return err
+	}
 
 	a.Serializer.Serialize(w, node)
 
 	return nil
 }
 
-func (a *Api) Move(nodeUuid, parentUuid string, w io.Writer) error {
+func (a *Api) Move(ctx context.Context, nodeUuid, parentUuid string, w io.Writer) error {
 
 	nodeReference, err := core.GetReferenceFromString(nodeUuid)
 
@@ -144,7 +375,7 @@ func (a *Api) Move(nodeUuid, parentUuid string, w io.Writer) error {
 		return err
 	}
 
-	affectedNodes, err := a.Manager.Move(nodeReference, parentReference)
+	affectedNodes, err := a.Manager.MoveContext(ctx, nodeReference, parentReference)
 
 	if err != nil {
 		return err
@@ -158,17 +389,27 @@ func (a *Api) Move(nodeUuid, parentUuid string, w io.Writer) error {
 	return nil
 }
 
-func (a *Api) FindOne(uuid string, w io.Writer) error {
+// Copy the node, and its descendants if deep is true, under the parent. An empty
+// parent copies the node at the root level. The copy of the node is written.
+func (a *Api) Copy(ctx context.Context, uuid, parent string, deep bool, w io.Writer) error {
 	reference, err := core.GetReferenceFromString(uuid)
 
 	if err != nil {
 		return core.NotFoundError
 	}
 
-	node := a.Manager.Find(reference)
+	parentReference := core.GetEmptyReference()
 
-	if node == nil {
-		return core.NotFoundError
+	if parent != "" {
+		if parentReference, err = core.GetReferenceFromString(parent); err != nil {
+			return core.NotFoundError
+		}
+	}
+
+	node, err := a.Manager.CopyContext(ctx, reference, parentReference, deep)
+
+	if err != nil {
+		return err
 	}
 
 	a.Serializer.Serialize(w, node)
@@ -176,47 +417,493 @@ func (a *Api) FindOne(uuid string, w io.Writer) error {
 	return nil
 }
 
-func (a *Api) FindOneBy(query sq.SelectBuilder, w io.Writer) error {
+// Reorder the children of the node, the body is the list of the children's uuids
+// in the new order. The children are written in the new order.
+func (a *Api) Reorder(ctx context.Context, uuid string, r io.Reader, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
 
-	node := a.Manager.FindOneBy(query)
+	if err != nil {
+		return core.NotFoundError
+	}
 
-	if node == nil {
+	values := make([]string, 0)
+
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return core.ValidationError
+	}
+
+	uuids := make([]core.Reference, 0, len(values))
+
+	for _, value := range values {
+		child, err := core.GetReferenceFromString(value)
+
+		if err != nil {
+			return core.ValidationError
+		}
+
+		uuids = append(uuids, child)
+	}
+
+	nodes, err := a.Manager.ReorderContext(ctx, reference, uuids)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeList(w, nodes)
+}
+
+// Write the relations of the node, optionally filtered by kind
+func (a *Api) FindRelations(ctx context.Context, uuid string, kind string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	relations, err := a.Manager.FindRelationsContext(ctx, reference, core.GetEmptyReference(), kind)
+
+	if err != nil {
+		return err
+	}
+
+	pager := &ApiPager{
+		Elements: make([]interface{}, 0, len(relations)),
+		Page:     1,
+		PerPage:  uint64(len(relations)),
+	}
+
+	for _, relation := range relations {
+		pager.Elements = append(pager.Elements, relation)
+	}
+
+	return core.Serialize(w, pager)
+}
+
+// Link the node to another one, the body is the relation without the from field
+func (a *Api) Link(ctx context.Context, uuid string, r io.Reader, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	relation := core.NewRelation(reference, core.GetEmptyReference(), "")
+
+	if err := json.NewDecoder(r).Decode(relation); err != nil {
+		return core.ValidationError
+	}
+
+	relation.From = reference
+
+	if relation, err = a.Manager.LinkContext(ctx, relation); err != nil {
+		return err
+	}
+
+	return core.Serialize(w, relation)
+}
+
+// Remove the relations from the node to another one, all the kinds are removed
+// if the kind is empty
+func (a *Api) Unlink(ctx context.Context, uuid string, to string, kind string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	toReference, err := core.GetReferenceFromString(to)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	deleted, err := a.Manager.UnlinkContext(ctx, reference, toReference, kind)
+
+	if err != nil {
+		return err
+	}
+
+	a.Serializer.Serialize(w, &ApiOperation{
+		Status:  OPERATION_OK,
+		Message: fmt.Sprintf("Relation removed: %d", deleted),
+	})
+
+	return nil
+}
+
+// Restore the revision of the node as a new revision, the core.ValidationErrors
+// is returned if the restored node is not valid.
+func (a *Api) Restore(ctx context.Context, uuid string, revision string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	rev, err := strconv.Atoi(revision)
+
+	if err != nil {
 		return core.NotFoundError
 	}
 
+	node, err := a.Manager.RestoreContext(ctx, reference, rev)
+
+	if err != nil {
+		return err
+	}
+
 	a.Serializer.Serialize(w, node)
 
 	return nil
 }
 
-func (a *Api) RemoveOne(uuid string, w io.Writer) error {
+// Write the RFC 6902 JSON Patch transforming the from revision of the node into
+// the to revision, the revisions are loaded from the audit table.
+func (a *Api) Diff(ctx context.Context, uuid string, from string, to string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	nodes := make([]*core.Node, 0, 2)
+
+	for _, revision := range []string{from, to} {
+		rev, err := strconv.Atoi(revision)
+
+		if err != nil {
+			return core.NotFoundError
+		}
+
+		options := core.NewSelectOptions()
+		options.TableSuffix = "nodes_audit"
+
+		node, err := a.Manager.FindOneByContext(ctx, a.SelectBuilder(options).
+			Where("uuid = ?", reference.String()).
+			Where("revision = ?", rev))
+
+		if err != nil {
+			return err
+		}
+
+		if node == nil {
+			return core.NotFoundError
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	patch, err := core.DiffNodes(nodes[0], nodes[1])
+
+	if err != nil {
+		return err
+	}
+
+	return core.Serialize(w, patch)
+}
+
+// The tree of a node, the node is serialized with the Serializer
+type ApiTree struct {
+	Node     *json.RawMessage `json:"node"`
+	Children []*ApiTree       `json:"children"`
+}
+
+// Write the direct children of the node ordered by weight
+func (a *Api) FindChildren(ctx context.Context, uuid string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	nodes, err := a.Manager.FindChildrenContext(ctx, reference)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeList(w, nodes)
+}
+
+// Write the descendants of the node up to the depth, 0 means all the descendants
+func (a *Api) FindDescendants(ctx context.Context, uuid string, depth int, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	nodes, err := a.Manager.FindDescendantsContext(ctx, reference, depth)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeList(w, nodes)
+}
+
+// Write the ancestors of the node starting from the root
+func (a *Api) FindAncestors(ctx context.Context, uuid string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	nodes, err := a.Manager.FindAncestorsContext(ctx, reference)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeList(w, nodes)
+}
+
+// Write the nested tree of the node up to the depth, the tree is loaded with
+// one query whatever the depth is
+func (a *Api) FindTree(ctx context.Context, uuid string, depth int, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	node, err := a.Manager.FindContext(ctx, reference)
+
+	if err != nil {
+		return err
+	}
+
+	if node == nil || node.Deleted {
+		return core.NotFoundError
+	}
+
+	descendants, err := a.Manager.FindDescendantsContext(ctx, reference, depth)
+
+	if err != nil {
+		return err
+	}
+
+	return core.Serialize(w, a.serializeTree(core.BuildNodeTree(node, descendants)))
+}
+
+func (a *Api) serializeTree(tree *core.NodeTree) *ApiTree {
+	b := bytes.NewBuffer([]byte{})
+	a.Serializer.Serialize(b, tree.Node)
+
+	message := json.RawMessage(b.Bytes())
+
+	t := &ApiTree{
+		Node:     &message,
+		Children: make([]*ApiTree, 0, len(tree.Children)),
+	}
+
+	for _, child := range tree.Children {
+		t.Children = append(t.Children, a.serializeTree(child))
+	}
+
+	return t
+}
+
+// write all the nodes as one page
+func (a *Api) writeList(w io.Writer, nodes *list.List) error {
+	pager := &ApiPager{
+		Elements: make([]interface{}, 0, nodes.Len()),
+		Page:     1,
+		PerPage:  uint64(nodes.Len()),
+	}
+
+	for e := nodes.Front(); e != nil; e = e.Next() {
+		b := bytes.NewBuffer([]byte{})
+		a.Serializer.Serialize(b, e.Value.(*core.Node))
+
+		message := json.RawMessage(b.Bytes())
+		pager.Elements = append(pager.Elements, &message)
+	}
+
+	return core.Serialize(w, pager)
+}
+
+// Write the node, the referenced nodes are embedded if expand is not nil
+func (a *Api) FindOne(ctx context.Context, uuid string, expand *Expand, w io.Writer) error {
+	node, err := a.findOne(ctx, uuid)
+
+	if err != nil {
+		return err
+	}
+
+	return a.writeNode(ctx, node, expand, w)
+}
+
+func (a *Api) findOne(ctx context.Context, uuid string) (*core.Node, error) {
 	reference, err := core.GetReferenceFromString(uuid)
 
+	if err != nil {
+		return nil, core.NotFoundError
+	}
+
+	node, err := a.Manager.FindContext(ctx, reference)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, core.NotFoundError
+	}
+
+	return node, nil
+}
+
+// write the node with the expanded references
+func (a *Api) writeNode(ctx context.Context, node *core.Node, expand *Expand, w io.Writer) error {
+	if expand == nil {
+		a.Serializer.Serialize(w, node)
+
+		return nil
+	}
+
+	loaded, err := a.loadExpanded(ctx, []*core.Node{node}, expand)
+
 	if err != nil {
 		return err
 	}
 
-	node := a.Manager.Find(reference)
+	data, err := a.serializeExpanded(node, expand, loaded, 0)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func (a *Api) FindOneBy(ctx context.Context, query sq.SelectBuilder, w io.Writer) error {
+
+	node, err := a.Manager.FindOneByContext(ctx, query)
+
+	if err != nil {
+		return err
+	}
 
 	if node == nil {
 		return core.NotFoundError
 	}
 
+	a.Serializer.Serialize(w, node)
+
+	return nil
+}
+
+func (a *Api) RemoveOne(ctx context.Context, uuid string, w io.Writer) error {
+	node, err := a.remove(ctx, a.Manager, uuid)
+
+	if err != nil {
+		return err
+	}
+
+	a.Serializer.Serialize(w, node)
+
+	return nil
+}
+
+// Remove the node with the manager, the IntegrityError is returned if the node
+// is still referenced.
+func (a *Api) remove(ctx context.Context, m core.NodeManager, uuid string) (*core.Node, error) {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := m.FindContext(ctx, reference)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node == nil {
+		return nil, core.NotFoundError
+	}
+
 	if node.Deleted {
-		return core.AlreadyDeletedError
+		return nil, core.AlreadyDeletedError
+	}
+
+	if err := checkIfMatch(ctx, node); err != nil {
+		return nil, err
+	}
+
+	if node, err = m.RemoveOneContext(ctx, node); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// Undelete the soft deleted node, the node is written as is if it is not deleted
+func (a *Api) Undelete(ctx context.Context, uuid string, w io.Writer) error {
+	reference, err := core.GetReferenceFromString(uuid)
+
+	if err != nil {
+		return core.NotFoundError
+	}
+
+	node, err := a.Manager.FindContext(ctx, reference)
+
+	if err != nil {
+		return err
+	}
+
+	if node == nil {
+		return core.NotFoundError
 	}
 
-	node, _ = a.Manager.RemoveOne(node)
+	if node.Deleted {
+		if node, err = a.Manager.UndeleteContext(ctx, node); err != nil {
+			return err
+		}
+	}
 
 	a.Serializer.Serialize(w, node)
 
 	return nil
 }
 
-func (a *Api) Remove(b sq.SelectBuilder, w io.Writer) error {
-	a.Manager.Remove(b)
+// Parse a date used as a limit, ie 2016-01-31 or 2016-01-31T10:00:00Z
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", value)
+}
+
+// Delete from the datastore the nodes soft deleted before the date
+func (a *Api) Purge(ctx context.Context, before time.Time, options *core.PurgeOptions, w io.Writer) error {
+	query := a.SelectBuilder(core.NewSelectOptions()).Where("updated_at < ?", before)
 
-	a.Find(w, b, 0, 0)
+	purged, err := a.Manager.PurgeContext(ctx, query, options)
+
+	if err != nil {
+		return err
+	}
+
+	a.Serializer.Serialize(w, &ApiOperation{
+		Status:  OPERATION_OK,
+		Message: fmt.Sprintf("Node purged: %d", purged),
+	})
 
 	return nil
 }
+
+func (a *Api) Remove(ctx context.Context, b sq.SelectBuilder, w io.Writer) error {
+	if err := a.Manager.RemoveContext(ctx, b); err != nil {
+		return err
+	}
+
+	return a.Find(ctx, w, b, 0, 0, nil)
+}
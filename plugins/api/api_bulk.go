@@ -0,0 +1,355 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"io"
+	"net/http"
+	"unicode"
+)
+
+const (
+	BULK_ATOMIC      = "atomic"
+	BULK_BEST_EFFORT = "best_effort"
+)
+
+var (
+	InvalidBulkError = errors.New("Unable to parse the bulk operations")
+
+	// returned by the batch function to roll back the transaction
+	bulkRollbackError = errors.New("The bulk operation failed")
+)
+
+// BulkOperation is one operation of a bulk request:
+//   - create: save the node, the node must not exist
+//   - update: save the node, the node must exist and the revision must match
+//   - delete: soft delete the node with the uuid
+//   - move: move the node with the uuid under the node with the parent_uuid
+type BulkOperation struct {
+	Op         string          `json:"op"`
+	Uuid       string          `json:"uuid,omitempty"`
+	ParentUuid string          `json:"parent_uuid,omitempty"`
+	Node       json.RawMessage `json:"node,omitempty"`
+}
+
+// BulkItem is the result of an operation, the status is an http status code and
+// the code is the error code of a failing operation
+type BulkItem struct {
+	Op       string      `json:"op"`
+	Uuid     string      `json:"uuid,omitempty"`
+	Revision int         `json:"revision,omitempty"`
+	Status   int         `json:"status"`
+	Code     string      `json:"code,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Errors   core.Errors `json:"errors,omitempty"`
+}
+
+func (i *BulkItem) failed() bool {
+	return i.Status >= http.StatusBadRequest
+}
+
+type ApiBulkResult struct {
+	Status string      `json:"status"`
+	Items  []*BulkItem `json:"items"`
+}
+
+// The atomic mode runs all the operations inside one transaction, nothing is
+// saved if an operation fails. The best effort mode runs the operations by
+// batches of BatchSize inside a transaction, a failing batch is replayed one
+// operation at a time so only the failing operations are not saved.
+type BulkOptions struct {
+	Mode      string
+	BatchSize int
+}
+
+// Read the operations from a JSON array or from one JSON object per line (NDJSON)
+func ParseBulkOperations(r io.Reader) ([]*BulkOperation, error) {
+	reader := bufio.NewReader(r)
+	operations := make([]*BulkOperation, 0)
+
+	// detect the format from the first character
+	for {
+		b, err := reader.Peek(1)
+
+		if err == io.EOF {
+			return operations, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+
+		reader.ReadByte()
+	}
+
+	decoder := json.NewDecoder(reader)
+
+	b, _ := reader.Peek(1)
+
+	if b[0] == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return nil, InvalidBulkError
+		}
+
+		for decoder.More() {
+			operation := &BulkOperation{}
+
+			if err := decoder.Decode(operation); err != nil {
+				return nil, InvalidBulkError
+			}
+
+			operations = append(operations, operation)
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return nil, InvalidBulkError
+		}
+
+		return operations, nil
+	}
+
+	for {
+		operation := &BulkOperation{}
+
+		if err := decoder.Decode(operation); err == io.EOF {
+			return operations, nil
+		} else if err != nil {
+			return nil, InvalidBulkError
+		}
+
+		operations = append(operations, operation)
+	}
+}
+
+// Run the operations with the Save, RemoveOne and Move semantics and return the
+// status of each operation. In the atomic mode, the result is KO if an operation
+// fails, the other operations are then rolled back or not processed.
+func (a *Api) Bulk(ctx context.Context, r io.Reader, options *BulkOptions) (*ApiBulkResult, error) {
+	operations, err := ParseBulkOperations(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*BulkItem, len(operations))
+
+	if options.Mode == BULK_ATOMIC {
+		if err := a.bulkBatch(ctx, operations, items); err != nil {
+			if err != bulkRollbackError {
+				return nil, err
+			}
+
+			code := helper.GetErrorCode(http.StatusFailedDependency)
+
+			for i, item := range items {
+				if item == nil {
+					items[i] = &BulkItem{Op: operations[i].Op, Uuid: operations[i].Uuid, Status: http.StatusFailedDependency, Code: code, Error: "The operation is not processed"}
+				} else if !item.failed() {
+					items[i] = &BulkItem{Op: item.Op, Uuid: item.Uuid, Status: http.StatusFailedDependency, Code: code, Error: "The operation is rolled back"}
+				}
+			}
+
+			return &ApiBulkResult{Status: OPERATION_KO, Items: items}, nil
+		}
+
+		return &ApiBulkResult{Status: OPERATION_OK, Items: items}, nil
+	}
+
+	status := OPERATION_OK
+
+	for start := 0; start < len(operations); start += options.BatchSize {
+		end := start + options.BatchSize
+
+		if end > len(operations) {
+			end = len(operations)
+		}
+
+		if err := a.bulkBatch(ctx, operations[start:end], items[start:end]); err == nil {
+			continue
+		}
+
+		status = OPERATION_KO
+
+		// replay the batch without the failing operations
+		for i := start; i < end; i++ {
+			items[i] = nil
+
+			if err := a.bulkBatch(ctx, operations[i:i+1], items[i:i+1]); err != nil && err != bulkRollbackError {
+				items[i] = &BulkItem{Op: operations[i].Op, Uuid: operations[i].Uuid, Status: GetHttpCode(err), Code: GetErrorCode(err), Error: err.Error()}
+			}
+		}
+	}
+
+	return &ApiBulkResult{Status: status, Items: items}, nil
+}
+
+// Run the operations inside a transaction, the transaction is rolled back on the
+// first failing operation.
+func (a *Api) bulkBatch(ctx context.Context, operations []*BulkOperation, items []*BulkItem) error {
+	return a.Manager.TransactionContext(ctx, func(tx core.NodeManager) error {
+		for i, operation := range operations {
+			if items[i] = a.bulkOperation(ctx, tx, operation); items[i].failed() {
+				return bulkRollbackError
+			}
+		}
+
+		return nil
+	})
+}
+
+func (a *Api) bulkOperation(ctx context.Context, m core.NodeManager, operation *BulkOperation) (item *BulkItem) {
+	item = &BulkItem{Op: operation.Op, Uuid: operation.Uuid}
+
+	defer func() {
+		if item.failed() && item.Code == "" {
+			item.Code = helper.GetErrorCode(item.Status)
+		}
+	}()
+
+	var err error
+
+	switch operation.Op {
+	case "create", "update":
+		err = a.bulkSave(ctx, m, operation, item)
+	case "delete":
+		var node *core.Node
+
+		if node, err = a.remove(ctx, m, operation.Uuid); err == nil {
+			item.Revision = node.Revision
+			item.Status = http.StatusOK
+		}
+	case "move":
+		err = a.bulkMove(ctx, m, operation, item)
+	default:
+		item.Status = http.StatusBadRequest
+		item.Error = fmt.Sprintf("Invalid operation: %s", operation.Op)
+
+		return item
+	}
+
+	if err != nil {
+		item.Status = GetHttpCode(err)
+		item.Code = GetErrorCode(err)
+		item.Error = err.Error()
+		item.Errors = getFieldErrors(err)
+	}
+
+	return item
+}
+
+func (a *Api) bulkSave(ctx context.Context, m core.NodeManager, operation *BulkOperation, item *BulkItem) error {
+	if len(operation.Node) == 0 {
+		item.Status = http.StatusBadRequest
+		item.Error = "The node is required"
+
+		return nil
+	}
+
+	node := core.NewNode()
+
+	if err := a.Serializer.Deserialize(bytes.NewReader(operation.Node), node); err != nil {
+		return &InvalidBodyError{Err: err}
+	}
+
+	saved, err := m.FindContext(ctx, node.Uuid)
+
+	if err != nil {
+		return err
+	}
+
+	if operation.Op == "create" && saved != nil {
+		item.Uuid = node.Uuid.CleanString()
+		item.Status = http.StatusConflict
+		item.Error = "The node already exists"
+
+		return nil
+	}
+
+	if operation.Op == "update" && saved == nil {
+		return core.NotFoundError
+	}
+
+	if err := a.store(ctx, m, node, saved); err != nil {
+		return err
+	}
+
+	item.Uuid = node.Uuid.CleanString()
+	item.Revision = node.Revision
+	item.Status = http.StatusOK
+
+	if operation.Op == "create" {
+		item.Status = http.StatusCreated
+	}
+
+	return nil
+}
+
+func (a *Api) bulkMove(ctx context.Context, m core.NodeManager, operation *BulkOperation, item *BulkItem) error {
+	reference, err := core.GetReferenceFromString(operation.Uuid)
+
+	if err != nil {
+		return err
+	}
+
+	parent, err := core.GetReferenceFromString(operation.ParentUuid)
+
+	if err != nil {
+		return err
+	}
+
+	if node, err := m.FindContext(ctx, reference); err != nil {
+		return err
+	} else if node == nil {
+		return core.NotFoundError
+	}
+
+	target, err := m.FindContext(ctx, parent)
+
+	if err != nil {
+		return err
+	}
+
+	if target == nil || target.Deleted {
+		return core.NotFoundError
+	}
+
+	// the node cannot be moved inside its own subtree
+	if target.Uuid.CleanString() == reference.CleanString() {
+		return core.ValidationError
+	}
+
+	for _, uuid := range target.Parents {
+		if uuid.CleanString() == reference.CleanString() {
+			return core.ValidationError
+		}
+	}
+
+	affected, err := m.MoveContext(ctx, reference, parent)
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return core.NotFoundError
+	}
+
+	item.Status = http.StatusOK
+
+	return nil
+}
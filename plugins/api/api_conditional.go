@@ -0,0 +1,113 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rande/gonode/core"
+	"net/http"
+	"strings"
+)
+
+var PreconditionFailedError = errors.New("The node does not match the If-Match condition")
+
+type ifMatchKey struct{}
+
+// Return the entity tag of the node, ie "<uuid>-v3-1a2b3c4d". The tag changes
+// with each revision and with the writes not creating a revision (soft delete,
+// reorder, migration, ...) as the fingerprint of the node is part of it.
+func GetETag(node *core.Node) string {
+	h := sha1.New()
+
+	json.NewEncoder(h).Encode(node)
+
+	return fmt.Sprintf(`"%s-%x"`, node.UniqueId(), h.Sum(nil)[:4])
+}
+
+// Return true if the node matches one of the entity tags of the header, ie
+// `"uuid-v2", "uuid-v3"` or `*`. The weak tags only match with the weak
+// comparison used by If-None-Match.
+func MatchETag(header string, node *core.Node, weak bool) bool {
+	etag := GetETag(node)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+
+			tag = tag[2:]
+		}
+
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Set the ETag and the Last-Modified headers of the node, a 304 is sent and
+// true is returned if the If-None-Match header matches the node.
+func WriteNotModified(res http.ResponseWriter, req *http.Request, node *core.Node) bool {
+	res.Header().Set("ETag", GetETag(node))
+	res.Header().Set("Last-Modified", node.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	if header := req.Header.Get("If-None-Match"); header != "" && MatchETag(header, node, true) {
+		res.WriteHeader(http.StatusNotModified)
+
+		return true
+	}
+
+	return false
+}
+
+// Return a context holding the If-Match header, the Api methods altering a node
+// return the PreconditionFailedError if the stored node does not match. The
+// header replaces the revision check of the body.
+func NewIfMatchContext(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, header)
+}
+
+func IfMatchFromContext(ctx context.Context) (string, bool) {
+	header, ok := ctx.Value(ifMatchKey{}).(string)
+
+	return header, ok
+}
+
+// return the request's context with the If-Match header if any
+func getIfMatchContext(req *http.Request) context.Context {
+	if header := req.Header.Get("If-Match"); header != "" {
+		return NewIfMatchContext(req.Context(), header)
+	}
+
+	return req.Context()
+}
+
+// the node is nil if it does not exist, so the condition fails
+func checkIfMatch(ctx context.Context, node *core.Node) error {
+	header, ok := IfMatchFromContext(ctx)
+
+	if !ok {
+		return nil
+	}
+
+	if node == nil || !MatchETag(header, node, false) {
+		return PreconditionFailedError
+	}
+
+	return nil
+}
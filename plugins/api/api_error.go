@@ -0,0 +1,151 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"log"
+	"net/http"
+)
+
+// InvalidBodyError is returned when the node sent by the client cannot be decoded
+type InvalidBodyError struct {
+	Err error
+}
+
+func (e *InvalidBodyError) Error() string {
+	return fmt.Sprintf("Unable to decode the node: %s", e.Err)
+}
+
+// Return the http status code matching the error returned by the Api or by the
+// NodeManager, unknown errors are internal errors.
+func GetHttpCode(err error) int {
+	switch e := err.(type) {
+	case *core.ConstraintError:
+		return http.StatusConflict
+	case *core.ConnectionError:
+		return http.StatusServiceUnavailable
+	case *core.DecodeError:
+		return http.StatusInternalServerError
+	case *core.IntegrityError, *core.ValidationErrors:
+		return http.StatusPreconditionFailed
+	case *core.PatchError, *InvalidBodyError:
+		return http.StatusBadRequest
+	case *core.PatchTestError:
+		return http.StatusPreconditionFailed
+	default:
+		if core.IsRevisionError(e) {
+			return http.StatusConflict
+		}
+	}
+
+	switch err {
+	case core.NotFoundError:
+		return http.StatusNotFound
+	case core.AlreadyDeletedError:
+		return http.StatusGone
+	case core.ValidationError, core.InvalidFieldError:
+		return http.StatusPreconditionFailed
+	case core.InvalidReferenceFormatError, InvalidBulkError, TypeMismatchError:
+		return http.StatusBadRequest
+	case PreconditionFailedError:
+		return http.StatusPreconditionFailed
+	case UnsupportedPatchError:
+		return http.StatusUnsupportedMediaType
+	case context.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Return the machine readable code of the error returned by the Api or by the
+// NodeManager, the code is sent with the error response.
+func GetErrorCode(err error) string {
+	switch e := err.(type) {
+	case *core.ConstraintError:
+		return "constraint_violation"
+	case *core.ConnectionError:
+		return "datastore_unavailable"
+	case *core.DecodeError:
+		return "decode_error"
+	case *core.IntegrityError:
+		return "integrity_violation"
+	case *core.ValidationErrors:
+		return "validation_failed"
+	case *core.PatchError:
+		return "invalid_patch"
+	case *InvalidBodyError:
+		return "invalid_body"
+	case *core.PatchTestError:
+		return "patch_test_failed"
+	default:
+		if core.IsRevisionError(e) {
+			return "revision_conflict"
+		}
+	}
+
+	switch err {
+	case core.NotFoundError:
+		return "not_found"
+	case core.AlreadyDeletedError:
+		return "already_deleted"
+	case core.ValidationError:
+		return "validation_failed"
+	case core.InvalidFieldError:
+		return "invalid_field"
+	case core.InvalidReferenceFormatError:
+		return "invalid_reference"
+	case InvalidBulkError:
+		return "invalid_bulk"
+	case TypeMismatchError:
+		return "type_mismatch"
+	case PreconditionFailedError:
+		return "precondition_failed"
+	case UnsupportedPatchError:
+		return "unsupported_media_type"
+	case context.DeadlineExceeded:
+		return "timeout"
+	}
+
+	return helper.ERROR_INTERNAL
+}
+
+// Return the field errors of the validation and integrity errors
+func getFieldErrors(err error) core.Errors {
+	switch e := err.(type) {
+	case *core.ValidationErrors:
+		return e.Errors
+	case *core.IntegrityError:
+		return e.Errors
+	}
+
+	return nil
+}
+
+// send the error response matching the error with the field errors if any
+func sendError(res http.ResponseWriter, err error) {
+	code := GetHttpCode(err)
+	message := err.Error()
+
+	// the internal errors might expose details about the storage, the real
+	// error is only logged with the request id.
+	if code >= http.StatusInternalServerError {
+		log.Printf("[%s] %s", res.Header().Get(helper.RequestIdHeader), err)
+
+		message = http.StatusText(code)
+	}
+
+	helper.SendErrorResponse(res, &helper.ErrorResponse{
+		Code:     GetErrorCode(err),
+		HttpCode: code,
+		Message:  message,
+		Errors:   getFieldErrors(err),
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_GetHttpCode(t *testing.T) {
+	err := errors.New("boom")
+
+	assert.Equal(t, http.StatusNotFound, GetHttpCode(core.NotFoundError))
+	assert.Equal(t, http.StatusGone, GetHttpCode(core.AlreadyDeletedError))
+	assert.Equal(t, http.StatusPreconditionFailed, GetHttpCode(core.ValidationError))
+	assert.Equal(t, http.StatusPreconditionFailed, GetHttpCode(&core.ValidationErrors{Errors: core.NewErrors()}))
+	assert.Equal(t, http.StatusBadRequest, GetHttpCode(core.InvalidReferenceFormatError))
+	assert.Equal(t, http.StatusBadRequest, GetHttpCode(InvalidBulkError))
+	assert.Equal(t, http.StatusBadRequest, GetHttpCode(TypeMismatchError))
+	assert.Equal(t, http.StatusBadRequest, GetHttpCode(&InvalidBodyError{Err: err}))
+	assert.Equal(t, http.StatusConflict, GetHttpCode(core.RevisionError))
+	assert.Equal(t, http.StatusConflict, GetHttpCode(core.NewRevisionError("Invalid revision")))
+	assert.Equal(t, http.StatusConflict, GetHttpCode(&core.ConstraintError{Constraint: "nodes_slug", Err: err}))
+	assert.Equal(t, http.StatusServiceUnavailable, GetHttpCode(&core.ConnectionError{Err: err}))
+	assert.Equal(t, http.StatusInternalServerError, GetHttpCode(&core.DecodeError{Uuid: core.GetRootReference(), Err: err}))
+	assert.Equal(t, http.StatusBadRequest, GetHttpCode(&core.PatchError{Message: "invalid"}))
+	assert.Equal(t, http.StatusPreconditionFailed, GetHttpCode(&core.PatchTestError{Path: "/revision"}))
+	assert.Equal(t, http.StatusPreconditionFailed, GetHttpCode(PreconditionFailedError))
+	assert.Equal(t, http.StatusUnsupportedMediaType, GetHttpCode(UnsupportedPatchError))
+	assert.Equal(t, http.StatusGatewayTimeout, GetHttpCode(context.DeadlineExceeded))
+	assert.Equal(t, http.StatusInternalServerError, GetHttpCode(err))
+}
+
+func Test_GetErrorCode(t *testing.T) {
+	assert.Equal(t, "not_found", GetErrorCode(core.NotFoundError))
+	assert.Equal(t, "already_deleted", GetErrorCode(core.AlreadyDeletedError))
+	assert.Equal(t, "validation_failed", GetErrorCode(&core.ValidationErrors{Errors: core.NewErrors()}))
+	assert.Equal(t, "integrity_violation", GetErrorCode(&core.IntegrityError{Errors: core.NewErrors()}))
+	assert.Equal(t, "revision_conflict", GetErrorCode(core.RevisionError))
+	assert.Equal(t, "invalid_patch", GetErrorCode(&core.PatchError{Message: "invalid"}))
+	assert.Equal(t, "precondition_failed", GetErrorCode(PreconditionFailedError))
+	assert.Equal(t, "internal_error", GetErrorCode(errors.New("boom")))
+}
+
+func Test_SendError(t *testing.T) {
+	errors := core.NewErrors()
+	errors.AddError("data.email", "invalid email")
+
+	w := httptest.NewRecorder()
+
+	sendError(w, &core.ValidationErrors{Errors: errors})
+
+	e := &helper.ErrorResponse{}
+	json.Unmarshal(w.Body.Bytes(), e)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, "validation_failed", e.Code)
+	assert.Equal(t, http.StatusPreconditionFailed, e.HttpCode)
+	assert.Equal(t, []string{"invalid email"}, e.Errors["data.email"])
+}
+
+func Test_SendError_Internal(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	sendError(w, &core.ConnectionError{Err: errors.New("dial tcp 10.0.0.1:5432: connection refused")})
+
+	e := &helper.ErrorResponse{}
+	json.Unmarshal(w.Body.Bytes(), e)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), e.Message)
+
+	w = httptest.NewRecorder()
+
+	sendError(w, errors.New(`pq: relation "nodes" does not exist`))
+
+	e = &helper.ErrorResponse{}
+	json.Unmarshal(w.Body.Bytes(), e)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), e.Message)
+}
@@ -0,0 +1,200 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	sq "github.com/lann/squirrel"
+	"github.com/rande/gonode/core"
+	"strconv"
+	"strings"
+)
+
+const MaxExpandDepth = 3
+
+var (
+	InvalidExpandError = errors.New("Invalid expand parameters")
+
+	// the reference fields which can be expanded
+	expandFields = map[string]func(node *core.Node) core.Reference{
+		"created_by":  func(node *core.Node) core.Reference { return node.CreatedBy },
+		"updated_by":  func(node *core.Node) core.Reference { return node.UpdatedBy },
+		"parent_uuid": func(node *core.Node) core.Reference { return node.ParentUuid },
+		"source":      func(node *core.Node) core.Reference { return node.Source },
+		"set_uuid":    func(node *core.Node) core.Reference { return node.SetUuid },
+	}
+)
+
+// Expand embeds the nodes referenced by the fields in the "_expanded" key of the
+// serialized node. The referenced nodes are expanded too up to the depth, the
+// nodes of one depth level are loaded with one query.
+type Expand struct {
+	Fields []string
+	Depth  int
+}
+
+// Parse the expand parameters, ie expand=created_by,parent_uuid&expand_depth=2.
+// A nil Expand is returned if there is no field to expand.
+func ParseExpand(fields string, depth string) (*Expand, error) {
+	if fields == "" {
+		return nil, nil
+	}
+
+	expand := &Expand{
+		Fields: make([]string, 0),
+		Depth:  1,
+	}
+
+	for _, field := range strings.Split(fields, ",") {
+		if _, ok := expandFields[field]; !ok {
+			return nil, InvalidExpandError
+		}
+
+		expand.Fields = append(expand.Fields, field)
+	}
+
+	if depth != "" {
+		d, err := strconv.Atoi(depth)
+
+		if err != nil || d < 1 || d > MaxExpandDepth {
+			return nil, InvalidExpandError
+		}
+
+		expand.Depth = d
+	}
+
+	return expand, nil
+}
+
+// return the uuid referenced by the field, an empty string is returned if the
+// reference is empty
+func (e *Expand) reference(node *core.Node, field string) string {
+	reference := expandFields[field](node)
+
+	if reference.UUID == nil {
+		return ""
+	}
+
+	empty := core.GetEmptyReference()
+
+	if uuid := reference.CleanString(); uuid != empty.CleanString() {
+		return uuid
+	}
+
+	return ""
+}
+
+// Load the nodes referenced by the nodes, level by level. The nodes are indexed
+// by uuid, the deleted nodes are not expanded.
+func (a *Api) loadExpanded(ctx context.Context, nodes []*core.Node, expand *Expand) (map[string]*core.Node, error) {
+	loaded := make(map[string]*core.Node)
+
+	for _, node := range nodes {
+		loaded[node.Uuid.CleanString()] = node
+	}
+
+	current := nodes
+
+	for level := 0; level < expand.Depth && len(current) > 0; level++ {
+		uuids := make([]string, 0)
+		queued := make(map[string]bool)
+
+		for _, node := range current {
+			for _, field := range expand.Fields {
+				uuid := expand.reference(node, field)
+
+				if _, ok := loaded[uuid]; uuid == "" || ok || queued[uuid] {
+					continue
+				}
+
+				queued[uuid] = true
+				uuids = append(uuids, uuid)
+			}
+		}
+
+		if len(uuids) == 0 {
+			break
+		}
+
+		query := a.Manager.SelectBuilder(core.NewSelectOptions()).
+			Where(sq.Eq{"uuid": uuids}).
+			Where("deleted = ?", false)
+
+		list, err := a.Manager.FindByContext(ctx, query, 0, uint64(len(uuids)))
+
+		if err != nil {
+			return nil, err
+		}
+
+		current = make([]*core.Node, 0, list.Len())
+
+		for e := list.Front(); e != nil; e = e.Next() {
+			node := e.Value.(*core.Node)
+
+			loaded[node.Uuid.CleanString()] = node
+			current = append(current, node)
+		}
+	}
+
+	return loaded, nil
+}
+
+// Serialize the node with the Serializer, the expanded nodes are added as the
+// last key of the json object. The level of the node starts at 0.
+func (a *Api) serializeExpanded(node *core.Node, expand *Expand, loaded map[string]*core.Node, level int) ([]byte, error) {
+	b := bytes.NewBuffer([]byte{})
+
+	if err := a.Serializer.Serialize(b, node); err != nil {
+		return nil, err
+	}
+
+	raw := bytes.TrimRight(b.Bytes(), " \n")
+
+	if expand == nil || level >= expand.Depth || len(raw) < 2 || raw[len(raw)-1] != '}' {
+		return raw, nil
+	}
+
+	expanded := make(map[string]*json.RawMessage)
+
+	for _, field := range expand.Fields {
+		related, ok := loaded[expand.reference(node, field)]
+
+		if !ok {
+			continue
+		}
+
+		data, err := a.serializeExpanded(related, expand, loaded, level+1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		message := json.RawMessage(data)
+		expanded[field] = &message
+	}
+
+	data, err := json.Marshal(expanded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(raw)+len(data)+16))
+	out.Write(raw[:len(raw)-1])
+
+	if len(bytes.TrimSpace(raw[1:len(raw)-1])) > 0 {
+		out.WriteString(",")
+	}
+
+	out.WriteString(`"_expanded":`)
+	out.Write(data)
+	out.WriteString("}")
+
+	return out.Bytes(), nil
+}
@@ -8,7 +8,7 @@ package api
 import (
 	"bufio"
 	"container/list"
-	"fmt"
+	"encoding/json"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/schema"
 	"github.com/gorilla/websocket"
@@ -24,7 +24,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -41,37 +43,99 @@ func readLoop(c *websocket.Conn) {
 	}
 }
 
+// read the optional expand parameters, an error is sent if the values are invalid
+func getExpand(res http.ResponseWriter, req *http.Request) (*Expand, bool) {
+	expand, err := ParseExpand(req.URL.Query().Get("expand"), req.URL.Query().Get("expand_depth"))
+
+	if err != nil {
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `expand` condition")
+
+		return nil, false
+	}
+
+	return expand, true
+}
+
+// read the optional depth parameter, an error is sent if the value is invalid
+func getDepth(res http.ResponseWriter, req *http.Request) (int, bool) {
+	value := req.URL.Query().Get("depth")
+
+	if value == "" {
+		return 0, true
+	}
+
+	depth, err := strconv.Atoi(value)
+
+	if err != nil || depth < 0 {
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `depth` condition")
+
+		return 0, false
+	}
+
+	return depth, true
+}
+
+// The read queries of the requests altering the nodes, and of the requests with
+// the X-Read-Your-Writes header, are sent to the master database so the client
+// reads its own changes even if the replicas lag behind.
+func ReadYourWritesMiddleware(c *web.C, h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		readYourWrites, _ := strconv.ParseBool(r.Header.Get("X-Read-Your-Writes"))
+
+		if readYourWrites || (r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS") {
+			r = r.WithContext(core.WithReadYourWrites(r.Context()))
+		}
+
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
 func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 	l.Prepare(func(app *goapp.App) error {
+		// the websocket clients are indexed by tenant
 		app.Set("gonode.websocket.clients", func(app *goapp.App) interface{} {
-			return list.New()
+			clients := make(map[string]*list.List)
+
+			for _, tenant := range app.Get("gonode.tenants").(*core.Tenants).All() {
+				clients[tenant.Name] = list.New()
+			}
+
+			return clients
 		})
 
 		sub := app.Get("gonode.postgres.subscriber").(*core.Subscriber)
-		sub.ListenMessage(conf.Databases["master"].Prefix+"_manager_action", func(notification *pq.Notification) (int, error) {
-			logger := app.Get("logger").(*log.Logger)
-			logger.Printf("WebSocket: Sending message \n")
-			webSocketList := app.Get("gonode.websocket.clients").(*list.List)
 
-			for e := webSocketList.Front(); e != nil; e = e.Next() {
-				if err := e.Value.(*websocket.Conn).WriteMessage(websocket.TextMessage, []byte(notification.Extra)); err != nil {
-					logger.Printf("Error writing to websocket")
+		for _, tenant := range app.Get("gonode.tenants").(*core.Tenants).All() {
+			name := tenant.Name
+
+			sub.ListenMessage(tenant.ActionChannel(), func(notification *pq.Notification) (int, error) {
+				logger := app.Get("logger").(*log.Logger)
+				logger.Printf("WebSocket: Sending message \n")
+				webSocketList := app.Get("gonode.websocket.clients").(map[string]*list.List)[name]
+
+				for e := webSocketList.Front(); e != nil; e = e.Next() {
+					if err := e.Value.(*websocket.Conn).WriteMessage(websocket.TextMessage, []byte(notification.Extra)); err != nil {
+						logger.Printf("Error writing to websocket")
+					}
 				}
-			}
 
-			logger.Printf("WebSocket: End Sending message \n")
+				logger.Printf("WebSocket: End Sending message \n")
 
-			return core.PubSubListenContinue, nil
-		})
+				return core.PubSubListenContinue, nil
+			})
+		}
 
 		graceful.PreHook(func() {
 			logger := app.Get("logger").(*log.Logger)
-			webSocketList := app.Get("gonode.websocket.clients").(*list.List)
 
 			logger.Printf("Closing websocket connections \n")
-			for e := webSocketList.Front(); e != nil; e = e.Next() {
-				e.Value.(*websocket.Conn).Close()
+			for _, webSocketList := range app.Get("gonode.websocket.clients").(map[string]*list.List) {
+				for e := webSocketList.Front(); e != nil; e = e.Next() {
+					e.Value.(*websocket.Conn).Close()
+				}
 			}
 		})
 
@@ -97,18 +161,31 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 	l.Prepare(func(app *goapp.App) error {
 		mux := app.Get("goji.mux").(*web.Mux)
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
-		apiHandler := app.Get("gonode.api").(*Api)
-		handler_collection := app.Get("gonode.handler_collection").(core.Handlers)
+		tenants := app.Get("gonode.tenants").(*core.Tenants)
 		searchBuilder := app.Get("gonode.search.pgsql").(*search.SearchPGSQL)
 		searchParser := app.Get("gonode.search.parser.http").(*search.HttpSearchParser)
 		prefix := ""
 
+		mux.Use(ReadYourWritesMiddleware)
+
+		// the api of each tenant uses the tenant manager
+		apis := make(map[string]*Api)
+
+		for _, tenant := range tenants.All() {
+			a := *app.Get("gonode.api").(*Api)
+			a.Manager = tenant.Manager
+
+			apis[tenant.Name] = &a
+		}
+
 		mux.Get(prefix+"/hello", func(c web.C, res http.ResponseWriter, req *http.Request) {
 			res.Write([]byte("Hello!"))
 		})
 
 		mux.Post(prefix+"/login", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			tenant := tenants.FromContext(req.Context())
+			manager := tenant.Manager
+
 			res.Header().Set("Content-Type", "application/json")
 
 			req.ParseForm()
@@ -119,13 +196,21 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 			}{}
 
 			decoder := schema.NewDecoder()
-			err := decoder.Decode(loginForm, req.Form)
+			if err := decoder.Decode(loginForm, req.Form); err != nil {
+				helper.SendError(res, http.StatusBadRequest, helper.ERROR_INVALID_PARAMETER, "Invalid login form")
 
-			core.PanicOnError(err)
+				return
+			}
 
 			query := manager.SelectBuilder(core.NewSelectOptions()).Where("type = 'core.user' AND data->>'username' = ?", loginForm.Username)
 
-			node := manager.FindOneBy(query)
+			node, err := manager.FindOneByContext(req.Context(), query)
+
+			if err != nil {
+				sendError(res, err)
+
+				return
+			}
 
 			password := []byte("$2a$10$KDobsZdRDVnuMqvimYH82.Tnu3suk5xP7QzhQjlCo7Wy7d67xtYay")
 
@@ -134,31 +219,30 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 				password = []byte(data.Password)
 			}
 
-			fmt.Printf("%s => %s", password, loginForm.Password)
-
 			if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(loginForm.Password)); err == nil { // equal
 				token := jwt.New(jwt.SigningMethodHS256)
 				token.Header["kid"] = "the sha1"
 
 				// Set some claims
 				token.Claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
+				token.Claims[conf.Tenancy.Claim] = tenant.Name
 				// Sign and get the complete encoded token as a string
-				tokenString, err := token.SignedString([]byte(conf.Guard.Key))
+				tokenString, err := token.SignedString(tenant.GuardKey)
 
 				if err != nil {
-					helper.SendWithHttpCode(res, http.StatusInternalServerError, "Unable to sign the token")
+					helper.SendError(res, http.StatusInternalServerError, helper.ERROR_INTERNAL, "Unable to sign the token")
+
 					return
 				}
 
-				core.PanicOnError(err)
 				res.Write([]byte(tokenString))
 			} else {
-				helper.SendWithHttpCode(res, http.StatusForbidden, "Unable to authenticate request: "+err.Error())
+				helper.SendError(res, http.StatusForbidden, helper.ERROR_AUTHENTICATION, "Unable to authenticate request: "+err.Error())
 			}
 		})
 
 		mux.Get(prefix+"/nodes/stream", func(res http.ResponseWriter, req *http.Request) {
-			webSocketList := app.Get("gonode.websocket.clients").(*list.List)
+			webSocketList := app.Get("gonode.websocket.clients").(map[string]*list.List)[tenants.FromContext(req.Context()).Name]
 
 			upgrader.CheckOrigin = func(r *http.Request) bool {
 				return true
@@ -188,22 +272,49 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 			}
 		})
 
+		mux.Get(prefix+"/nodes/cache", func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "application/json")
+
+			cached, ok := tenants.FromContext(req.Context()).Manager.(*core.CachedNodeManager)
+
+			if !ok {
+				helper.SendError(res, http.StatusNotFound, helper.GetErrorCode(http.StatusNotFound), "The cache is not enabled")
+
+				return
+			}
+
+			data, _ := json.Marshal(cached.Stats())
+
+			res.Write(data)
+		})
+
 		mux.Get(prefix+"/nodes/:uuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			tenant := tenants.FromContext(req.Context())
+			apiHandler := apis[tenant.Name]
+			manager := tenant.Manager
+			handler_collection := tenant.Handlers
+
 			values := req.URL.Query()
 
 			if _, raw := values["raw"]; raw { // ask for binary content
 				reference, err := core.GetReferenceFromString(c.URLParams["uuid"])
 
 				if err != nil {
-					helper.SendWithHttpCode(res, http.StatusInternalServerError, "Unable to parse the reference")
+					sendError(res, err)
 
 					return
 				}
 
-				node := manager.Find(reference)
+				node, err := manager.FindContext(req.Context(), reference)
+
+				if err != nil {
+					sendError(res, err)
+
+					return
+				}
 
 				if node == nil {
-					helper.SendWithHttpCode(res, http.StatusNotFound, "Element not found")
+					sendError(res, core.NotFoundError)
 
 					return
 				}
@@ -212,6 +323,10 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 
 				res.Header().Set("Content-Type", data.ContentType)
 
+				if WriteNotModified(res, req, node) {
+					return
+				}
+
 				//			if download {
 				//				res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", data.Filename));
 				//			}
@@ -220,33 +335,165 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 			} else {
 				// send the json value
 				res.Header().Set("Content-Type", "application/json")
-				err := apiHandler.FindOne(c.URLParams["uuid"], res)
 
-				if err == core.NotFoundError {
-					helper.SendWithHttpCode(res, http.StatusNotFound, err.Error())
+				expand, ok := getExpand(res, req)
+
+				if !ok {
+					return
 				}
 
+				node, err := apiHandler.findOne(req.Context(), c.URLParams["uuid"])
+
 				if err != nil {
-					helper.SendWithHttpCode(res, http.StatusInternalServerError, err.Error())
+					sendError(res, err)
+
+					return
+				}
+
+				// the expanded nodes are not part of the entity tag
+				if expand == nil && WriteNotModified(res, req, node) {
+					return
+				}
+
+				if err := apiHandler.writeNode(req.Context(), node, expand, res); err != nil {
+					sendError(res, err)
 				}
 			}
 		})
 
+		mux.Get(prefix+"/nodes/:uuid/children", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			if err := apiHandler.FindChildren(req.Context(), c.URLParams["uuid"], res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Put(prefix+"/nodes/:uuid/children/order", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			if err := apiHandler.Reorder(req.Context(), c.URLParams["uuid"], req.Body, res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Get(prefix+"/nodes/:uuid/descendants", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			depth, ok := getDepth(res, req)
+
+			if !ok {
+				return
+			}
+
+			if err := apiHandler.FindDescendants(req.Context(), c.URLParams["uuid"], depth, res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Get(prefix+"/nodes/:uuid/ancestors", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			if err := apiHandler.FindAncestors(req.Context(), c.URLParams["uuid"], res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Get(prefix+"/nodes/:uuid/tree", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			depth, ok := getDepth(res, req)
+
+			if !ok {
+				return
+			}
+
+			if err := apiHandler.FindTree(req.Context(), c.URLParams["uuid"], depth, res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Get(prefix+"/nodes/:uuid/relations", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			if err := apiHandler.FindRelations(req.Context(), c.URLParams["uuid"], req.URL.Query().Get("kind"), res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Post(prefix+"/nodes/:uuid/relations", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			w := bufio.NewWriter(res)
+
+			if err := apiHandler.Link(req.Context(), c.URLParams["uuid"], req.Body, w); err != nil {
+				sendError(res, err)
+
+				return
+			}
+
+			res.WriteHeader(http.StatusCreated)
+
+			w.Flush()
+		})
+
+		mux.Delete(prefix+"/nodes/:uuid/relations/:to", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			if err := apiHandler.Unlink(req.Context(), c.URLParams["uuid"], c.URLParams["to"], req.URL.Query().Get("kind"), res); err != nil {
+				sendError(res, err)
+			}
+		})
+
 		mux.Get(prefix+"/nodes/:uuid/revisions", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
 			res.Header().Set("Content-Type", "application/json")
 
 			searchForm := searchParser.HandleSearch(res, req)
 
+			if searchForm == nil {
+				return
+			}
+
 			options := core.NewSelectOptions()
 			options.TableSuffix = "nodes_audit"
 
 			query := apiHandler.SelectBuilder(options).
 				Where("uuid = ?", c.URLParams["uuid"])
 
-			apiHandler.Find(res, searchBuilder.BuildQuery(searchForm, query), searchForm.Page, searchForm.PerPage)
+			err := apiHandler.Find(req.Context(), res, searchBuilder.BuildQuery(searchForm, query), searchForm.Page, searchForm.PerPage, &FindOptions{
+				Total:     searchForm.Total,
+				Facets:    searchForm.Facets,
+				FacetSize: searchParser.MaxResult,
+				OrderBy:   searchForm.OrderBy,
+				Cursor:    searchForm.Cursor,
+			})
+
+			if err != nil {
+				sendError(res, err)
+			}
 		})
 
 		mux.Get(prefix+"/nodes/:uuid/revisions/:rev", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
 			res.Header().Set("Content-Type", "application/json")
 
 			options := core.NewSelectOptions()
@@ -256,38 +503,121 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 				Where("uuid = ?", c.URLParams["uuid"]).
 				Where("revision = ?", c.URLParams["rev"])
 
-			err := apiHandler.FindOneBy(query, res)
+			err := apiHandler.FindOneBy(req.Context(), query, res)
 
-			if err == core.NotFoundError {
-				helper.SendWithHttpCode(res, http.StatusNotFound, err.Error())
+			if err != nil {
+				sendError(res, err)
 			}
+		})
+
+		mux.Get(prefix+"/nodes/:uuid/revisions/:from/diff/:to", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			w := bufio.NewWriter(res)
+
+			err := apiHandler.Diff(req.Context(), c.URLParams["uuid"], c.URLParams["from"], c.URLParams["to"], w)
 
 			if err != nil {
-				helper.SendWithHttpCode(res, http.StatusInternalServerError, err.Error())
+				sendError(res, err)
+
+				return
 			}
+
+			w.Flush()
 		})
 
-		mux.Post(prefix+"/nodes", func(res http.ResponseWriter, req *http.Request) {
+		mux.Put(prefix+"/nodes/:uuid/revisions/:rev/restore", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
 			res.Header().Set("Content-Type", "application/json")
 
 			w := bufio.NewWriter(res)
 
-			err := apiHandler.Save(req.Body, w)
+			if err := apiHandler.Restore(req.Context(), c.URLParams["uuid"], c.URLParams["rev"], w); err != nil {
+				sendError(res, err)
 
-			if err == core.RevisionError {
-				res.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			w.Flush()
+		})
+
+		mux.Post(prefix+"/nodes/_bulk", func(res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			values := req.URL.Query()
+			options := &BulkOptions{
+				Mode:      BULK_ATOMIC,
+				BatchSize: 100,
+			}
+
+			if mode := values.Get("mode"); mode != "" {
+				if mode != BULK_ATOMIC && mode != BULK_BEST_EFFORT {
+					helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `mode` condition")
+
+					return
+				}
+
+				options.Mode = mode
+			}
+
+			if values.Get("batch_size") != "" {
+				size, err := strconv.Atoi(values.Get("batch_size"))
+
+				if err != nil || size < 1 {
+					helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `batch_size` condition")
+
+					return
+				}
+
+				options.BatchSize = size
+			}
+
+			result, err := apiHandler.Bulk(req.Context(), req.Body, options)
+
+			if err != nil {
+				sendError(res, err)
+
+				return
 			}
 
-			if err == core.ValidationError {
+			// nothing is saved if an atomic operation fails
+			if options.Mode == BULK_ATOMIC && result.Status == OPERATION_KO {
 				res.WriteHeader(http.StatusPreconditionFailed)
 			}
 
+			apiHandler.Serializer.Serialize(res, result)
+		})
+
+		mux.Post(prefix+"/nodes", func(res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			w := bufio.NewWriter(res)
+
+			if err := apiHandler.Save(req.Context(), req.Body, w); err != nil {
+				sendError(res, err)
+
+				return
+			}
+
 			res.WriteHeader(http.StatusCreated)
 
 			w.Flush()
 		})
 
 		mux.Put(prefix+"/nodes/:uuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			tenant := tenants.FromContext(req.Context())
+			apiHandler := apis[tenant.Name]
+			manager := tenant.Manager
+			handler_collection := tenant.Handlers
+			ctx := getIfMatchContext(req)
+
 			res.Header().Set("Content-Type", "application/json")
 
 			values := req.URL.Query()
@@ -296,80 +626,195 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 				reference, err := core.GetReferenceFromString(c.URLParams["uuid"])
 
 				if err != nil {
-					helper.SendWithHttpCode(res, http.StatusInternalServerError, "Unable to parse the reference")
+					sendError(res, err)
 
 					return
 				}
 
-				node := manager.Find(reference)
+				node, err := manager.FindContext(ctx, reference)
+
+				if err != nil {
+					sendError(res, err)
+
+					return
+				}
 
 				if node == nil {
-					helper.SendWithHttpCode(res, http.StatusNotFound, "Element not found")
+					sendError(res, core.NotFoundError)
+
+					return
+				}
+
+				if err := checkIfMatch(ctx, node); err != nil {
+					sendError(res, err)
+
 					return
 				}
 
 				_, err = handler_collection.Get(node).StoreStream(node, req.Body)
 
+				if err == nil {
+					_, err = manager.SaveContext(ctx, node, false)
+				}
+
 				if err != nil {
-					helper.SendWithHttpCode(res, http.StatusInternalServerError, err.Error())
+					sendError(res, err)
 				} else {
-					manager.Save(node, false)
-
 					helper.SendWithHttpCode(res, http.StatusOK, "binary stored")
 				}
 
 			} else {
 				w := bufio.NewWriter(res)
 
-				err := apiHandler.Save(req.Body, w)
+				if err := apiHandler.Save(ctx, req.Body, w); err != nil {
+					sendError(res, err)
 
-				if err == core.RevisionError {
-					res.WriteHeader(http.StatusConflict)
-				}
-
-				if err == core.ValidationError {
-					res.WriteHeader(http.StatusPreconditionFailed)
+					return
 				}
 
 				w.Flush()
 			}
 		})
 
+		mux.Patch(prefix+"/nodes/:uuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+			w := bufio.NewWriter(res)
+
+			if err := apiHandler.Patch(getIfMatchContext(req), c.URLParams["uuid"], contentType, req.Body, w); err != nil {
+				sendError(res, err)
+
+				return
+			}
+
+			w.Flush()
+		})
+
 		mux.Put(prefix+"/nodes/move/:uuid/:parentUuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
 			res.Header().Set("Content-Type", "application/json")
 
-			err := apiHandler.Move(c.URLParams["uuid"], c.URLParams["parentUuid"], res)
+			err := apiHandler.Move(req.Context(), c.URLParams["uuid"], c.URLParams["parentUuid"], res)
 
 			if err != nil {
-				helper.SendWithHttpCode(res, http.StatusInternalServerError, err.Error())
+				sendError(res, err)
 			}
 		})
 
-		mux.Delete(prefix+"/nodes/:uuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
-			err := apiHandler.RemoveOne(c.URLParams["uuid"], res)
+		mux.Post(prefix+"/nodes/:uuid/copy", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
 
-			if err == core.NotFoundError {
-				helper.SendWithHttpCode(res, http.StatusNotFound, err.Error())
-				return
+			res.Header().Set("Content-Type", "application/json")
+
+			values := req.URL.Query()
+			deep := false
+
+			if values.Get("deep") != "" {
+				var err error
+
+				if deep, err = strconv.ParseBool(values.Get("deep")); err != nil {
+					helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `deep` condition")
+
+					return
+				}
 			}
 
-			if err == core.AlreadyDeletedError {
-				helper.SendWithHttpCode(res, http.StatusGone, err.Error())
+			w := bufio.NewWriter(res)
+
+			if err := apiHandler.Copy(req.Context(), c.URLParams["uuid"], values.Get("parent"), deep, w); err != nil {
+				sendError(res, err)
+
 				return
 			}
 
+			res.WriteHeader(http.StatusCreated)
+
+			w.Flush()
+		})
+
+		mux.Put(prefix+"/nodes/:uuid/undelete", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			err := apiHandler.Undelete(req.Context(), c.URLParams["uuid"], res)
+
+			if err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Delete(prefix+"/nodes/purge", func(res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			res.Header().Set("Content-Type", "application/json")
+
+			values := req.URL.Query()
+			options := &core.PurgeOptions{}
+
+			before, err := ParseDate(values.Get("before"))
+
 			if err != nil {
-				helper.SendWithHttpCode(res, http.StatusInternalServerError, err.Error())
+				helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `before` condition")
+
+				return
+			}
+
+			if values.Get("keep_audit") != "" {
+				if options.KeepAudit, err = strconv.ParseBool(values.Get("keep_audit")); err != nil {
+					helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `keep_audit` condition")
+
+					return
+				}
+			}
+
+			if values.Get("keep_binaries") != "" {
+				if options.KeepBinaries, err = strconv.ParseBool(values.Get("keep_binaries")); err != nil {
+					helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `keep_binaries` condition")
+
+					return
+				}
+			}
+
+			if err := apiHandler.Purge(req.Context(), before, options, res); err != nil {
+				sendError(res, err)
+			}
+		})
+
+		mux.Delete(prefix+"/nodes/:uuid", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			apiHandler := apis[tenants.FromContext(req.Context()).Name]
+
+			w := bufio.NewWriter(res)
+
+			if err := apiHandler.RemoveOne(getIfMatchContext(req), c.URLParams["uuid"], w); err != nil {
+				sendError(res, err)
+
+				return
 			}
+
+			w.Flush()
 		})
 
 		mux.Put(prefix+"/notify/:name", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			manager := tenants.FromContext(req.Context()).Manager
+
 			body, _ := ioutil.ReadAll(req.Body)
 
-			manager.Notify(c.URLParams["name"], string(body[:]))
+			if err := manager.NotifyContext(req.Context(), c.URLParams["name"], string(body[:])); err != nil {
+				sendError(res, err)
+			}
 		})
 
 		mux.Get(prefix+"/nodes", func(c web.C, res http.ResponseWriter, req *http.Request) {
+			tenant := tenants.FromContext(req.Context())
+			apiHandler := apis[tenant.Name]
+			manager := tenant.Manager
+
 			res.Header().Set("Content-Type", "application/json")
 
 			searchForm := searchParser.HandleSearch(res, req)
@@ -378,9 +823,32 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 				return
 			}
 
+			expand, ok := getExpand(res, req)
+
+			if !ok {
+				return
+			}
+
+			if err := search.ResolveRelated(req.Context(), manager, searchForm); err != nil {
+				sendError(res, err)
+
+				return
+			}
+
 			query := searchBuilder.BuildQuery(searchForm, manager.SelectBuilder(core.NewSelectOptions()))
 
-			apiHandler.Find(res, query, searchForm.Page, searchForm.PerPage)
+			err := apiHandler.Find(req.Context(), res, query, searchForm.Page, searchForm.PerPage, &FindOptions{
+				Total:     searchForm.Total,
+				Facets:    searchForm.Facets,
+				FacetSize: searchParser.MaxResult,
+				OrderBy:   searchForm.OrderBy,
+				Cursor:    searchForm.Cursor,
+				Expand:    expand,
+			})
+
+			if err != nil {
+				sendError(res, err)
+			}
 		})
 
 		return nil
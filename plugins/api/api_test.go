@@ -8,13 +8,18 @@ package api
 import (
 	"bytes"
 	"container/list"
+	"context"
 	"encoding/json"
 	"github.com/gorilla/schema"
 	sq "github.com/lann/squirrel"
 	"github.com/rande/gonode/core"
 	"github.com/rande/gonode/plugins/search"
 	"github.com/stretchr/testify/assert"
+	"github.com/zenazn/goji/web"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,7 +57,7 @@ func Test_ApiPager_Serialization(t *testing.T) {
 
 	assert.Equal(t, sb, api.SelectBuilder(options))
 
-	api.Find(b, api.SelectBuilder(options), uint64(1), uint64(10))
+	api.Find(context.Background(), b, api.SelectBuilder(options), uint64(1), uint64(10), nil)
 
 	var out bytes.Buffer
 
@@ -104,3 +109,125 @@ func Test_OrderBy_Form(t *testing.T) {
 
 	assert.Equal(t, form.OrderBy, []string{"updated_at,ASC", "name,DESC"})
 }
+
+func Test_ParseExpand(t *testing.T) {
+	expand, err := ParseExpand("", "")
+
+	assert.Nil(t, err)
+	assert.Nil(t, expand)
+
+	expand, err = ParseExpand("created_by,parent_uuid", "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"created_by", "parent_uuid"}, expand.Fields)
+	assert.Equal(t, 1, expand.Depth)
+
+	expand, err = ParseExpand("source", "3")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, expand.Depth)
+
+	_, err = ParseExpand("foo", "")
+	assert.Equal(t, InvalidExpandError, err)
+
+	_, err = ParseExpand("source", "4")
+	assert.Equal(t, InvalidExpandError, err)
+
+	_, err = ParseExpand("source", "a")
+	assert.Equal(t, InvalidExpandError, err)
+}
+
+func Test_ParseBulkOperations(t *testing.T) {
+	operations, err := ParseBulkOperations(strings.NewReader(`
+		[{"op": "create", "node": {"type": "core.user"}}, {"op": "delete", "uuid": "d703a3ab-8374-4c30-a8a4-2c22aa67763b"}]
+	`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(operations))
+	assert.Equal(t, "create", operations[0].Op)
+	assert.Equal(t, `{"type": "core.user"}`, string(operations[0].Node))
+	assert.Equal(t, "d703a3ab-8374-4c30-a8a4-2c22aa67763b", operations[1].Uuid)
+
+	operations, err = ParseBulkOperations(strings.NewReader("{\"op\": \"delete\"}\n{\"op\": \"move\", \"parent_uuid\": \"p\"}\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(operations))
+	assert.Equal(t, "p", operations[1].ParentUuid)
+
+	operations, err = ParseBulkOperations(strings.NewReader(" "))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(operations))
+
+	for _, body := range []string{`[{"op": "create"}`, `{"op": "create"} [`, `"create"`} {
+		_, err = ParseBulkOperations(strings.NewReader(body))
+
+		assert.Equal(t, InvalidBulkError, err, body)
+	}
+}
+
+func Test_MatchETag(t *testing.T) {
+	node := core.NewNode()
+	node.Uuid, _ = core.GetReferenceFromString("d703a3ab-8374-4c30-a8a4-2c22aa67763b")
+	node.Revision = 3
+
+	etag := GetETag(node)
+
+	assert.Regexp(t, `^"d703a3ab-8374-4c30-a8a4-2c22aa67763b-v3-[0-9a-f]{8}"$`, etag)
+
+	assert.True(t, MatchETag(etag, node, false))
+	assert.True(t, MatchETag(`"other", `+etag, node, false))
+	assert.True(t, MatchETag(`*`, node, false))
+	assert.False(t, MatchETag(`"d703a3ab-8374-4c30-a8a4-2c22aa67763b-v3"`, node, false))
+
+	// the weak tags only match with the weak comparison
+	assert.False(t, MatchETag(`W/`+etag, node, false))
+	assert.True(t, MatchETag(`W/`+etag, node, true))
+
+	// the writes without a new revision change the tag
+	node.Weight = 2
+	assert.False(t, MatchETag(etag, node, false))
+
+	node.Weight = 0
+	node.Deleted = true
+	assert.False(t, MatchETag(etag, node, false))
+}
+
+func Test_WriteNotModified(t *testing.T) {
+	node := core.NewNode()
+	node.Uuid, _ = core.GetReferenceFromString("d703a3ab-8374-4c30-a8a4-2c22aa67763b")
+	node.UpdatedAt = time.Date(2016, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	r, _ := http.NewRequest("GET", "/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b", nil)
+	res := httptest.NewRecorder()
+
+	assert.False(t, WriteNotModified(res, r, node))
+	assert.Equal(t, GetETag(node), res.Header().Get("ETag"))
+	assert.Equal(t, "Sun, 31 Jan 2016 10:00:00 GMT", res.Header().Get("Last-Modified"))
+
+	r.Header.Set("If-None-Match", res.Header().Get("ETag"))
+	res = httptest.NewRecorder()
+
+	assert.True(t, WriteNotModified(res, r, node))
+	assert.Equal(t, http.StatusNotModified, res.Code)
+}
+
+func Test_ReadYourWritesMiddleware(t *testing.T) {
+	var readYourWrites bool
+
+	h := ReadYourWritesMiddleware(&web.C{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readYourWrites = core.ReadYourWrites(r.Context())
+	}))
+
+	r, _ := http.NewRequest("GET", "/nodes", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.False(t, readYourWrites)
+
+	r.Header.Set("X-Read-Your-Writes", "true")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, readYourWrites)
+
+	r, _ = http.NewRequest("POST", "/nodes", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, readYourWrites)
+}
@@ -6,6 +6,7 @@
 package guard
 
 import (
+	"context"
 	"errors"
 	"net/http"
 )
@@ -66,14 +67,30 @@ func (t *DefaultGuardToken) GetRoles() []string {
 	return t.Roles
 }
 
+type contextKey int
+
+const guardTokenKey contextKey = 0
+
+// Return a new context storing the authenticated token
+func NewContext(ctx context.Context, token GuardToken) context.Context {
+	return context.WithValue(ctx, guardTokenKey, token)
+}
+
+// Return the authenticated token stored in the context, if any
+func FromContext(ctx context.Context) (GuardToken, bool) {
+	token, ok := ctx.Value(guardTokenKey).(GuardToken)
+
+	return token, ok
+}
+
 type GuardAuthenticator interface {
 	// This method is call on each request.
 	// If the method return nil as interface{} value, it means the authenticator
 	// cannot handle the request
 	getCredentials(req *http.Request) (interface{}, error)
 
-	// Return the user from the credentials
-	getUser(credentials interface{}) (GuardUser, error)
+	// Return the user from the credentials, the context is the request's context
+	getUser(ctx context.Context, credentials interface{}) (GuardUser, error)
 
 	// Check if the provided credentials are valid for the current user
 	checkCredentials(credentials interface{}, user GuardUser) error
@@ -10,6 +10,7 @@ import (
 	"github.com/rande/gonode/core"
 	"github.com/rande/gonode/core/config"
 	"github.com/zenazn/goji/web"
+	"net/http"
 	"regexp"
 )
 
@@ -18,24 +19,34 @@ func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
 	l.Prepare(func(app *goapp.App) error {
 		mux := app.Get("goji.mux").(*web.Mux)
 		conf := app.Get("gonode.configuration").(*config.ServerConfig)
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
-
-		auths := []GuardAuthenticator{
-			&JwtTokenGuardAuthenticator{
-				Path:        regexp.MustCompile(conf.Guard.Jwt.Token.Path),
-				Key:         []byte(conf.Guard.Key),
-				Validity:    conf.Guard.Jwt.Validity,
-				NodeManager: manager,
-			},
-			&JwtLoginGuardAuthenticator{
-				LoginPath:   conf.Guard.Jwt.Login.Path,
-				Key:         []byte(conf.Guard.Key),
-				Validity:    conf.Guard.Jwt.Validity,
-				NodeManager: manager,
-			},
+		tenants := app.Get("gonode.tenants").(*core.Tenants)
+
+		// each tenant has its own authenticators, the tenant is resolved by the tenant middleware
+		middlewares := make(map[string]func(c *web.C, h http.Handler) http.Handler)
+
+		for _, tenant := range tenants.All() {
+			auths := []GuardAuthenticator{
+				&JwtTokenGuardAuthenticator{
+					Path:        regexp.MustCompile(conf.Guard.Jwt.Token.Path),
+					Key:         tenant.GuardKey,
+					Validity:    conf.Guard.Jwt.Validity,
+					NodeManager: tenant.Manager,
+				},
+				&JwtLoginGuardAuthenticator{
+					LoginPath:   conf.Guard.Jwt.Login.Path,
+					Key:         tenant.GuardKey,
+					Validity:    conf.Guard.Jwt.Validity,
+					NodeManager: tenant.Manager,
+					Claims: map[string]interface{}{
+						conf.Tenancy.Claim: tenant.Name,
+					},
+				},
+			}
+
+			middlewares[tenant.Name] = GetGuardMiddleware(auths)
 		}
 
-		mux.Use(GetGuardMiddleware(auths))
+		mux.Use(GetTenantGuardMiddleware(tenants, middlewares))
 
 		return nil
 	})
@@ -6,10 +6,12 @@
 package guard
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/schema"
 	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
 	"github.com/rande/gonode/plugins/user"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
@@ -22,6 +24,8 @@ type JwtLoginGuardAuthenticator struct {
 	NodeManager core.NodeManager
 	Validity    int64
 	Key         []byte
+	// the claims added to the generated tokens, ie the tenant
+	Claims map[string]interface{}
 }
 
 func (a *JwtLoginGuardAuthenticator) getCredentials(req *http.Request) (interface{}, error) {
@@ -44,14 +48,20 @@ func (a *JwtLoginGuardAuthenticator) getCredentials(req *http.Request) (interfac
 	return &struct{ Username, Password string }{loginForm.Username, loginForm.Password}, nil
 }
 
-func (a *JwtLoginGuardAuthenticator) getUser(credentials interface{}) (GuardUser, error) {
+func (a *JwtLoginGuardAuthenticator) getUser(ctx context.Context, credentials interface{}) (GuardUser, error) {
 	c := credentials.(*struct{ Username, Password string })
 
 	query := a.NodeManager.
 		SelectBuilder(core.NewSelectOptions()).
 		Where("type = 'core.user' AND data->>'username' = ?", c.Username)
 
-	if node := a.NodeManager.FindOneBy(query); node != nil {
+	node, err := a.NodeManager.FindOneByContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node != nil {
 		return node.Data.(*user.User), nil
 	}
 
@@ -76,17 +86,7 @@ func (a *JwtLoginGuardAuthenticator) createAuthenticatedToken(user GuardUser) (G
 }
 
 func (a *JwtLoginGuardAuthenticator) onAuthenticationFailure(req *http.Request, res http.ResponseWriter, err error) bool {
-	// nothing to do
-	res.Header().Set("Content-Type", "application/json")
-
-	res.WriteHeader(http.StatusForbidden)
-
-	data, _ := json.Marshal(map[string]string{
-		"status":  "KO",
-		"message": "Unable to authenticate request",
-	})
-
-	res.Write(data)
+	helper.SendError(res, http.StatusForbidden, helper.ERROR_AUTHENTICATION, "Unable to authenticate request")
 
 	return true
 }
@@ -104,6 +104,10 @@ func (a *JwtLoginGuardAuthenticator) onAuthenticationSuccess(req *http.Request,
 	jwtToken.Claims["rls"] = token.GetRoles()
 	jwtToken.Claims["usr"] = token.GetUsername()
 
+	for name, value := range a.Claims {
+		jwtToken.Claims[name] = value
+	}
+
 	// Sign and get the complete encoded token as a string
 	tokenString, _ := jwtToken.SignedString([]byte(a.Key))
 
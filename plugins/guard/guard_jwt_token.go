@@ -6,10 +6,11 @@
 package guard
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
 	"github.com/rande/gonode/plugins/user"
 	"net/http"
 	"regexp"
@@ -42,14 +43,20 @@ func (a *JwtTokenGuardAuthenticator) getCredentials(req *http.Request) (interfac
 	}
 }
 
-func (a *JwtTokenGuardAuthenticator) getUser(credentials interface{}) (GuardUser, error) {
+func (a *JwtTokenGuardAuthenticator) getUser(ctx context.Context, credentials interface{}) (GuardUser, error) {
 	jwtToken := credentials.(*jwt.Token)
 
 	query := a.NodeManager.
 		SelectBuilder(core.NewSelectOptions()).
 		Where("type = 'core.user' AND data->>'username' = ?", jwtToken.Claims["usr"].(string))
 
-	if node := a.NodeManager.FindOneBy(query); node != nil {
+	node, err := a.NodeManager.FindOneByContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node != nil {
 		return node.Data.(*user.User), nil
 	}
 
@@ -70,17 +77,7 @@ func (a *JwtTokenGuardAuthenticator) createAuthenticatedToken(user GuardUser) (G
 }
 
 func (a *JwtTokenGuardAuthenticator) onAuthenticationFailure(req *http.Request, res http.ResponseWriter, err error) bool {
-	// nothing to do
-	res.Header().Set("Content-Type", "application/json")
-
-	res.WriteHeader(http.StatusForbidden)
-
-	data, _ := json.Marshal(map[string]string{
-		"status":  "KO",
-		"message": "Unable to validate token",
-	})
-
-	res.Write(data)
+	helper.SendError(res, http.StatusForbidden, helper.ERROR_AUTHENTICATION, "Unable to validate token")
 
 	return true
 }
@@ -6,6 +6,8 @@
 package guard
 
 import (
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
 	"github.com/zenazn/goji/web"
 	"net/http"
 )
@@ -25,6 +27,11 @@ func GetGuardMiddleware(auths []GuardAuthenticator) func(c *web.C, h http.Handle
 				}
 			}
 
+			// the token is available from the request's context to the next handlers
+			if token, ok := c.Env["guard_token"].(GuardToken); ok {
+				r = r.WithContext(NewContext(r.Context(), token))
+			}
+
 			h.ServeHTTP(w, r)
 		}
 
@@ -32,6 +39,28 @@ func GetGuardMiddleware(auths []GuardAuthenticator) func(c *web.C, h http.Handle
 	}
 }
 
+// Dispatch the request to the guard middleware of the request's tenant
+func GetTenantGuardMiddleware(tenants *core.Tenants, middlewares map[string]func(c *web.C, h http.Handler) http.Handler) func(c *web.C, h http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		handlers := make(map[string]http.Handler)
+
+		for name, middleware := range middlewares {
+			handlers[name] = middleware(c, h)
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			// a tenant without authenticators is not allowed
+			if handler, ok := handlers[tenants.FromContext(r.Context()).Name]; ok {
+				handler.ServeHTTP(w, r)
+			} else {
+				helper.SendError(w, http.StatusForbidden, helper.ERROR_AUTHENTICATION, "No authenticator is configured for the tenant")
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
 // false means, no authentification has been done
 func performAuthentication(c *web.C, a GuardAuthenticator, w http.ResponseWriter, r *http.Request) (bool, bool) {
 	var o bool
@@ -51,7 +80,7 @@ func performAuthentication(c *web.C, a GuardAuthenticator, w http.ResponseWriter
 	}
 
 	// ok get the current user for the current credentials
-	user, err := a.getUser(credentials)
+	user, err := a.getUser(r.Context(), credentials)
 
 	if err != nil || user == nil {
 		o = a.onAuthenticationFailure(r, w, err)
@@ -6,6 +6,8 @@
 package guard
 
 import (
+	"context"
+	"github.com/rande/gonode/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/zenazn/goji/web"
 	"net/http"
@@ -112,3 +114,83 @@ func Test_Perform_Authentification_With_Valid_User(t *testing.T) {
 	assert.True(t, output)
 	assert.Equal(t, token, cw.Env["guard_token"])
 }
+
+func Test_Guard_Middleware_Token_In_Request_Context(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/foobar", nil)
+	w := httptest.NewRecorder()
+
+	c := map[string]string{
+		"login": "thomas",
+	}
+
+	u := &DefaultGuardUser{
+		Username: "thomas",
+	}
+
+	token := &DefaultGuardToken{
+		Username: "thomas",
+	}
+
+	a := &MockedAuthenticator{}
+	a.On("getCredentials", r).Return(c, nil)
+	a.On("getUser", c).Return(u, nil)
+	a.On("checkCredentials", c, u).Return(nil)
+	a.On("createAuthenticatedToken", u).Return(token, nil)
+	a.On("onAuthenticationSuccess", r, w, token).Return(false)
+
+	cw := &web.C{Env: make(map[interface{}]interface{})}
+
+	var found GuardToken
+
+	h := GetGuardMiddleware([]GuardAuthenticator{a})(cw, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found, _ = FromContext(r.Context())
+	}))
+
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, token, found)
+}
+
+func Test_Guard_FromContext_Without_Token(t *testing.T) {
+	token, ok := FromContext(context.Background())
+
+	assert.False(t, ok)
+	assert.Nil(t, token)
+}
+
+func Test_Tenant_Guard_Middleware(t *testing.T) {
+	tenants := core.NewTenants(&core.Tenant{Name: core.DefaultTenant, Prefix: "test"})
+	tenants.Add(&core.Tenant{Name: "acme", Prefix: "acme"})
+
+	called := ""
+
+	getMiddleware := func(name string) func(c *web.C, h http.Handler) http.Handler {
+		return func(c *web.C, h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = name
+				h.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := GetTenantGuardMiddleware(tenants, map[string]func(c *web.C, h http.Handler) http.Handler{
+		core.DefaultTenant: getMiddleware(core.DefaultTenant),
+	})(&web.C{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r, _ := http.NewRequest("GET", "/foobar", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, core.DefaultTenant, called)
+	assert.Equal(t, 200, w.Code)
+
+	// the tenant does not have a middleware
+	called = ""
+	w = httptest.NewRecorder()
+
+	h.ServeHTTP(w, r.WithContext(core.NewTenantContext(context.Background(), tenants.Get("acme"))))
+
+	assert.Equal(t, "", called)
+	assert.Equal(t, 403, w.Code)
+}
@@ -6,6 +6,7 @@
 package guard
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 	"net/http"
 )
@@ -23,7 +24,7 @@ func (m *MockedAuthenticator) getCredentials(req *http.Request) (interface{}, er
 
 	return args.Get(0).(interface{}), args.Error(1)
 }
-func (m *MockedAuthenticator) getUser(credentials interface{}) (GuardUser, error) {
+func (m *MockedAuthenticator) getUser(ctx context.Context, credentials interface{}) (GuardUser, error) {
 	args := m.Mock.Called(credentials)
 
 	if args.Get(0) == nil {
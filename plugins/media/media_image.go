@@ -6,6 +6,8 @@
 package media
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/lib/pq"
 	"github.com/rande/gonode/core"
@@ -122,12 +124,46 @@ func (h *ImageHandler) StoreStream(node *core.Node, r io.Reader) (written int64,
 	return
 }
 
+// Remove the binaries stored for all the node's revisions
+func (h *ImageHandler) Purge(node *core.Node) error {
+	revision := *node
+
+	for revision.Revision = 1; revision.Revision <= node.Revision; revision.Revision++ {
+		if err := h.Vault.Remove(revision.UniqueId()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Duplicate the binary of the source's current revision for the copy
+func (h *ImageHandler) Copy(source *core.Node, node *core.Node) error {
+	if !h.Vault.Has(source.UniqueId()) {
+		return nil
+	}
+
+	b := bytes.NewBuffer([]byte{})
+
+	if _, err := h.Vault.Get(source.UniqueId(), b); err != nil {
+		return err
+	}
+
+	_, err := h.Vault.Put(node.UniqueId(), core.GetVaultMetadata(node), b)
+
+	return err
+}
+
 type ImageDownloadListener struct {
 	Vault      *vault.Vault
 	HttpClient core.HttpClient
 }
 
 func (l *ImageDownloadListener) Handle(notification *pq.Notification, m core.NodeManager) (int, error) {
+	return l.HandleContext(context.Background(), notification, m)
+}
+
+func (l *ImageDownloadListener) HandleContext(ctx context.Context, notification *pq.Notification, m core.NodeManager) (int, error) {
 	reference, err := core.GetReferenceFromString(notification.Extra)
 
 	if err != nil { // unable to parse the reference
@@ -135,7 +171,11 @@ func (l *ImageDownloadListener) Handle(notification *pq.Notification, m core.Nod
 	}
 
 	fmt.Printf("Download binary from uuid: %s\n", notification.Extra)
-	node := m.Find(reference)
+	node, err := m.FindContext(ctx, reference)
+
+	if err != nil {
+		return core.PubSubListenContinue, err
+	}
 
 	if node == nil {
 		fmt.Printf("Uuid does not exist: %s\n", notification.Extra)
@@ -156,7 +196,7 @@ func (l *ImageDownloadListener) Handle(notification *pq.Notification, m core.Nod
 	if err != nil {
 		meta.SourceStatus = core.ProcessStatusError
 		meta.SourceError = "Unable to retrieve the remote file"
-		m.Save(node, false)
+		m.SaveContext(ctx, node, false)
 
 		return core.PubSubListenContinue, err
 	}
@@ -174,7 +214,7 @@ func (l *ImageDownloadListener) Handle(notification *pq.Notification, m core.Nod
 	meta.ContentType = "application/octet-stream"
 	meta.SourceStatus = core.ProcessStatusDone
 
-	m.Save(node, false)
+	m.SaveContext(ctx, node, false)
 
 	return core.PubSubListenContinue, nil
 }
@@ -6,8 +6,11 @@
 package media
 
 import (
+	"bytes"
 	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/vault"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +129,71 @@ func Test_ImageHandler_PostInsert(t *testing.T) {
 
 	a.Equal(node.Meta.(*ImageMeta).SourceStatus, core.ProcessStatusUpdate)
 }
+
+func Test_ImageHandler_Purge(t *testing.T) {
+	a := assert.New(t)
+
+	handler := &ImageHandler{
+		Vault: &vault.Vault{
+			Algo:    "no_op",
+			BaseKey: []byte(""),
+			Driver: &vault.DriverFs{
+				Root: "/tmp/goapp/test/media",
+			},
+		},
+	}
+
+	node := core.NewNode()
+	node.Data, node.Meta = handler.GetStruct()
+
+	handler.StoreStream(node, strings.NewReader("revision 1"))
+
+	node.Revision = 2
+	handler.StoreStream(node, strings.NewReader("revision 2"))
+
+	a.True(handler.Vault.Has(node.UniqueId()))
+
+	a.Nil(handler.Purge(node))
+
+	a.False(handler.Vault.Has(node.UniqueId()))
+
+	node.Revision = 1
+	a.False(handler.Vault.Has(node.UniqueId()))
+}
+
+func Test_ImageHandler_Copy(t *testing.T) {
+	a := assert.New(t)
+
+	handler := &ImageHandler{
+		Vault: &vault.Vault{
+			Algo:    "no_op",
+			BaseKey: []byte(""),
+			Driver: &vault.DriverFs{
+				Root: "/tmp/goapp/test/media",
+			},
+		},
+	}
+
+	source := core.NewNode()
+	source.Data, source.Meta = handler.GetStruct()
+
+	node := core.NewNode()
+	node.Uuid = core.GetRootReference()
+	node.Data, node.Meta = handler.GetStruct()
+
+	// nothing to copy
+	a.Nil(handler.Copy(source, node))
+	a.False(handler.Vault.Has(node.UniqueId()))
+
+	handler.StoreStream(source, strings.NewReader("binary"))
+
+	a.Nil(handler.Copy(source, node))
+
+	b := bytes.NewBuffer([]byte{})
+	handler.Vault.Get(node.UniqueId(), b)
+
+	a.Equal("binary", b.String())
+
+	a.Nil(handler.Purge(source))
+	a.Nil(handler.Purge(node))
+}
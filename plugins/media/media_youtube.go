@@ -6,6 +6,7 @@
 package media
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/lib/pq"
@@ -97,13 +98,21 @@ type YoutubeListener struct {
 }
 
 func (l *YoutubeListener) Handle(notification *pq.Notification, m core.NodeManager) (int, error) {
+	return l.HandleContext(context.Background(), notification, m)
+}
+
+func (l *YoutubeListener) HandleContext(ctx context.Context, notification *pq.Notification, m core.NodeManager) (int, error) {
 	reference, err := core.GetReferenceFromString(notification.Extra)
 
 	if err != nil { // unable to parse the reference
 		return core.PubSubListenContinue, nil
 	}
 
-	node := m.Find(reference)
+	node, err := m.FindContext(ctx, reference)
+
+	if err != nil {
+		return core.PubSubListenContinue, err
+	}
 
 	if node == nil {
 		return core.PubSubListenContinue, nil
@@ -117,7 +126,7 @@ func (l *YoutubeListener) Handle(notification *pq.Notification, m core.NodeManag
 	if err != nil {
 		node.Data.(*Youtube).Status = core.ProcessStatusError
 		node.Data.(*Youtube).Error = "Error while retrieving json response"
-		m.Save(node, true)
+		m.SaveContext(ctx, node, true)
 
 		return core.PubSubListenContinue, err
 	}
@@ -130,24 +139,33 @@ func (l *YoutubeListener) Handle(notification *pq.Notification, m core.NodeManag
 	if err != nil {
 		node.Data.(*Youtube).Status = core.ProcessStatusError
 		node.Data.(*Youtube).Error = "Error while decoding json"
-		m.Save(node, true)
+		m.SaveContext(ctx, node, true)
 
 		return core.PubSubListenContinue, err
 	}
 
 	node.Data.(*Youtube).Status = core.ProcessStatusDone
 
-	m.Save(node, true)
+	// the video and its thumbnail are stored together
+	err = m.TransactionContext(ctx, func(tx core.NodeManager) error {
+		if _, err := tx.SaveContext(ctx, node, true); err != nil {
+			return err
+		}
+
+		if node.Meta.(*YoutubeMeta).ThumbnailUrl == "" {
+			return nil
+		}
 
-	if node.Meta.(*YoutubeMeta).ThumbnailUrl != "" {
-		image := m.NewNode("media.image")
+		image := tx.NewNode("media.image")
 		image.Data.(*Image).SourceUrl = node.Meta.(*YoutubeMeta).ThumbnailUrl
 		image.Source = node.Uuid
 		image.CreatedBy = node.CreatedBy
 		image.UpdatedBy = node.UpdatedBy
 
-		m.Save(image, false)
-	}
+		_, err := tx.SaveContext(ctx, image, false)
+
+		return err
+	})
 
-	return core.PubSubListenContinue, nil
+	return core.PubSubListenContinue, err
 }
@@ -0,0 +1,269 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	sq "github.com/lann/squirrel"
+	"github.com/rande/gonode/core"
+	"strings"
+	"time"
+)
+
+// Cursor is the position of a node inside a result set ordered by the order_by
+// fields, the node's id is used to break the ties. A previous cursor points to
+// the nodes located before the node.
+type Cursor struct {
+	OrderBy  []string      `json:"o"`
+	Values   []interface{} `json:"v"`
+	Id       int           `json:"i"`
+	Previous bool          `json:"p,omitempty"`
+}
+
+// Create a cursor located on the node
+func NewCursor(orderBy []*Param, node *core.Node, previous bool) (*Cursor, error) {
+	c := &Cursor{
+		OrderBy:  getCursorOrderBy(orderBy),
+		Values:   make([]interface{}, 0, len(orderBy)),
+		Id:       node.Id,
+		Previous: previous,
+	}
+
+	for _, order := range orderBy {
+		v, err := GetCursorValue(node, order.SubField)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.Values = append(c.Values, v)
+	}
+
+	return c, nil
+}
+
+// Decode an opaque token generated by the String function
+func ParseCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cursor{}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	if len(c.Values) != len(c.OrderBy) {
+		return nil, fmt.Errorf("invalid cursor values")
+	}
+
+	return c, nil
+}
+
+// Return the opaque token
+func (c *Cursor) String() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Check if the cursor has been generated with the same order
+func (c *Cursor) Match(orderBy []*Param) bool {
+	current := getCursorOrderBy(orderBy)
+
+	if len(current) != len(c.OrderBy) {
+		return false
+	}
+
+	for i := range current {
+		if current[i] != c.OrderBy[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Return the query's condition selecting the nodes located after the cursor, or
+// before if the cursor is a previous cursor:
+//
+//	(a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)
+//
+// The missing json values (ie, data.name) are NULL, they are sorted after the
+// other values with the ASC order and before with the DESC order, so the
+// conditions include the IS NULL branches matching this order.
+func GetCursorCondition(orderBy []*Param, c *Cursor) sq.Sqlizer {
+	ors := make([]string, 0, len(orderBy)+1)
+	args := make([]interface{}, 0)
+
+	for i := 0; i <= len(orderBy); i++ {
+		ands := make([]string, 0, i+1)
+		values := make([]interface{}, 0, i+1)
+
+		for j := 0; j < i; j++ {
+			field := GetJsonQuery(orderBy[j].SubField, "->")
+
+			if c.Values[j] == nil {
+				ands = append(ands, field+" IS NULL")
+			} else {
+				ands = append(ands, field+" = ?")
+				values = append(values, c.Values[j])
+			}
+		}
+
+		if i < len(orderBy) {
+			field := GetJsonQuery(orderBy[i].SubField, "->")
+			asc := GetOrderDirection(orderBy[i].Operation, c.Previous) != "DESC"
+
+			switch {
+			case c.Values[i] == nil && asc:
+				continue // no value after NULL
+			case c.Values[i] == nil:
+				ands = append(ands, field+" IS NOT NULL")
+			case asc:
+				ands = append(ands, fmt.Sprintf("(%s > ? OR %s IS NULL)", field, field))
+				values = append(values, c.Values[i])
+			default:
+				ands = append(ands, field+" < ?")
+				values = append(values, c.Values[i])
+			}
+		} else {
+			ands = append(ands, fmt.Sprintf("id %s ?", getCursorOperator("ASC", c.Previous)))
+			values = append(values, c.Id)
+		}
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+		args = append(args, values...)
+	}
+
+	return sq.Expr("("+strings.Join(ors, " OR ")+")", args...)
+}
+
+// Return the value used by the query for the field, the json values (ie,
+// data.name) are encoded as the query compares jsonb values, nil is returned
+// for a missing json value.
+func GetCursorValue(node *core.Node, field string) (interface{}, error) {
+	switch field {
+	case "id":
+		return node.Id, nil
+	case "uuid":
+		return node.Uuid.CleanString(), nil
+	case "type":
+		return node.Type, nil
+	case "name":
+		return node.Name, nil
+	case "slug":
+		return node.Slug, nil
+	case "status":
+		return node.Status, nil
+	case "weight":
+		return node.Weight, nil
+	case "revision":
+		return node.Revision, nil
+	case "version":
+		return node.Version, nil
+	case "enabled":
+		return node.Enabled, nil
+	case "deleted":
+		return node.Deleted, nil
+	case "created_at":
+		return node.CreatedAt.Format(time.RFC3339Nano), nil
+	case "updated_at":
+		return node.UpdatedAt.Format(time.RFC3339Nano), nil
+	case "created_by":
+		return node.CreatedBy.CleanString(), nil
+	case "updated_by":
+		return node.UpdatedBy.CleanString(), nil
+	case "parent_uuid":
+		return node.ParentUuid.CleanString(), nil
+	case "set_uuid":
+		return node.SetUuid.CleanString(), nil
+	case "source":
+		return node.Source.CleanString(), nil
+	}
+
+	fields := strings.Split(field, ".")
+
+	var value interface{}
+
+	switch fields[0] {
+	case "data":
+		value = node.Data
+	case "meta":
+		value = node.Meta
+	default:
+		return nil, fmt.Errorf("unable to paginate on the field %q", field)
+	}
+
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// decode in a new value, json.Unmarshal reuses the pointer stored in value
+	value = nil
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+
+	// a missing value is NULL for the query, not the json null value
+	for _, f := range fields[1:] {
+		m, ok := value.(map[string]interface{})
+
+		if !ok {
+			return nil, nil
+		}
+
+		if value, ok = m[f]; !ok {
+			return nil, nil
+		}
+	}
+
+	raw, err = json.Marshal(value)
+
+	return string(raw), err
+}
+
+// Return the direction used to sort the results, the direction is reversed to
+// read the nodes located before a previous cursor.
+func GetOrderDirection(direction string, previous bool) string {
+	direction = strings.ToUpper(direction)
+
+	if !previous {
+		return direction
+	}
+
+	if direction == "DESC" {
+		return "ASC"
+	}
+
+	return "DESC"
+}
+
+func getCursorOperator(direction string, previous bool) string {
+	if GetOrderDirection(direction, previous) == "DESC" {
+		return "<"
+	}
+
+	return ">"
+}
+
+func getCursorOrderBy(orderBy []*Param) []string {
+	fields := make([]string, 0, len(orderBy))
+
+	for _, order := range orderBy {
+		fields = append(fields, order.SubField+","+strings.ToUpper(order.Operation))
+	}
+
+	return fields
+}
@@ -50,14 +50,21 @@ func GetJsonSearchQuery(query sq.SelectBuilder, params []*Param, field string) s
 type SearchPGSQL struct {
 }
 
+// The id is used as the last order to get a stable order, so the results can be
+// paginated with a cursor. The order is reversed for a previous cursor, however
+// the cursor's condition is not added as the query is also used to count the
+// nodes, see GetCursorCondition.
 func (s *SearchPGSQL) BuildQuery(searchForm *SearchForm, query sq.SelectBuilder) sq.SelectBuilder {
+	previous := searchForm.Cursor != nil && searchForm.Cursor.Previous
 
 	for _, order := range searchForm.OrderBy {
 		core.PanicIf(len(order.SubField) == 0, "OrderBy field name is empty")
 
-		query = query.OrderBy(GetJsonQuery(order.SubField, "->") + " " + order.Operation)
+		query = query.OrderBy(GetJsonQuery(order.SubField, "->") + " " + GetOrderDirection(order.Operation, previous))
 	}
 
+	query = query.OrderBy("id " + GetOrderDirection("ASC", previous))
+
 	if searchForm.Uuid != nil {
 		query = query.Where(sq.Eq{"uuid": searchForm.Uuid.Value})
 	}
@@ -121,5 +128,14 @@ func (s *SearchPGSQL) BuildQuery(searchForm *SearchForm, query sq.SelectBuilder)
 		query = query.Where(sq.Eq{"source": searchForm.Source.Value})
 	}
 
+	// the related uuids are loaded by ResolveRelated
+	if searchForm.Related != nil {
+		if uuids := searchForm.Related.Value.([]string); len(uuids) > 0 {
+			query = query.Where(sq.Eq{"uuid": uuids})
+		} else {
+			query = query.Where("1 = 0")
+		}
+	}
+
 	return query
 }
@@ -30,26 +30,32 @@ func NewParam(v interface{}, options ...string) *Param {
 }
 
 type SearchForm struct {
-	Page       uint64   `json:"page"`
-	PerPage    uint64   `json:"per_page"`
-	OrderBy    []*Param `json:"order_by"`
-	Uuid       *Param   `json:"uuid"`
-	Type       *Param   `json:"type"`
-	Name       *Param   `json:"name"`
-	Slug       *Param   `json:"slug"`
-	Data       []*Param `json:"data"`
-	Meta       []*Param `json:"meta"`
-	Status     *Param   `json:"status"`
-	Weight     *Param   `json:"weight"`
-	Revision   *Param   `json:"revision"`
-	Enabled    *Param   `json:"enabled"`
-	Deleted    *Param   `json:"deleted"`
-	Current    *Param   `json:"current"`
-	UpdatedBy  *Param   `json:"updated_by"`
-	CreatedBy  *Param   `json:"created_by"`
-	ParentUuid *Param   `json:"parent_uuid"`
-	SetUuid    *Param   `json:"set_uuid"`
-	Source     *Param   `json:"source"`
+	Page        uint64   `json:"page"`
+	PerPage     uint64   `json:"per_page"`
+	OrderBy     []*Param `json:"order_by"`
+	Uuid        *Param   `json:"uuid"`
+	Type        *Param   `json:"type"`
+	Name        *Param   `json:"name"`
+	Slug        *Param   `json:"slug"`
+	Data        []*Param `json:"data"`
+	Meta        []*Param `json:"meta"`
+	Status      *Param   `json:"status"`
+	Weight      *Param   `json:"weight"`
+	Revision    *Param   `json:"revision"`
+	Enabled     *Param   `json:"enabled"`
+	Deleted     *Param   `json:"deleted"`
+	Current     *Param   `json:"current"`
+	UpdatedBy   *Param   `json:"updated_by"`
+	CreatedBy   *Param   `json:"created_by"`
+	ParentUuid  *Param   `json:"parent_uuid"`
+	SetUuid     *Param   `json:"set_uuid"`
+	Source      *Param   `json:"source"`
+	RelatedTo   *Param   `json:"related_to"`
+	RelatedKind *Param   `json:"related_kind"`
+	Related     *Param   `json:"related"`
+	Total       bool     `json:"total"`
+	Facets      []string `json:"facets"`
+	Cursor      *Cursor  `json:"cursor"`
 }
 
 func NewSearchForm() *SearchForm {
@@ -58,5 +64,6 @@ func NewSearchForm() *SearchForm {
 		Data:    make([]*Param, 0),
 		Meta:    make([]*Param, 0),
 		Deleted: NewParam(false, "="),
+		Facets:  make([]string, 0),
 	}
 }
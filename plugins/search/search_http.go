@@ -7,6 +7,7 @@ package search
 
 import (
 	"github.com/gorilla/schema"
+	"github.com/rande/gonode/core"
 	"github.com/rande/gonode/helper"
 	"net/http"
 	"regexp"
@@ -16,29 +17,35 @@ var (
 	rexOrderBy = regexp.MustCompile(`(^[a-z,_.A-Z]*),(DESC|ASC|desc|asc)$`)
 	rexMeta    = regexp.MustCompile(`meta\.([a-zA-Z]*)`)
 	rexData    = regexp.MustCompile(`data\.([a-zA-Z]*)`)
+	rexFacet   = regexp.MustCompile(`^(type|name|slug|status|enabled|deleted|revision|version|weight|created_by|updated_by|parent_uuid|set_uuid|source|(data|meta)(\.[a-zA-Z0-9_]+)+)$`)
 )
 
 type HttpSearchForm struct {
-	Page       int64               `schema:"page"`
-	PerPage    int64               `schema:"per_page"`
-	OrderBy    []string            `schema:"order_by"`
-	Uuid       []string            `schema:"uuid"`
-	Type       []string            `schema:"type"`
-	Name       string              `schema:"name"`
-	Slug       string              `schema:"slug"`
-	Data       map[string][]string `schema:"data"`
-	Meta       map[string][]string `schema:"meta"`
-	Status     []string            `schema:"status"`
-	Weight     []string            `schema:"weight"`
-	Revision   string              `schema:"revision"`
-	Enabled    string              `schema:"enabled"`
-	Deleted    string              `schema:"deleted"`
-	Current    string              `schema:"current"`
-	UpdatedBy  []string            `schema:"updated_by"`
-	CreatedBy  []string            `schema:"created_by"`
-	ParentUuid []string            `schema:"parent_uuid"`
-	SetUuid    []string            `schema:"set_uuid"`
-	Source     []string            `schema:"source"`
+	Page        int64               `schema:"page"`
+	PerPage     int64               `schema:"per_page"`
+	OrderBy     []string            `schema:"order_by"`
+	Uuid        []string            `schema:"uuid"`
+	Type        []string            `schema:"type"`
+	Name        string              `schema:"name"`
+	Slug        string              `schema:"slug"`
+	Data        map[string][]string `schema:"data"`
+	Meta        map[string][]string `schema:"meta"`
+	Status      []string            `schema:"status"`
+	Weight      []string            `schema:"weight"`
+	Revision    string              `schema:"revision"`
+	Enabled     string              `schema:"enabled"`
+	Deleted     string              `schema:"deleted"`
+	Current     string              `schema:"current"`
+	UpdatedBy   []string            `schema:"updated_by"`
+	CreatedBy   []string            `schema:"created_by"`
+	ParentUuid  []string            `schema:"parent_uuid"`
+	SetUuid     []string            `schema:"set_uuid"`
+	Source      []string            `schema:"source"`
+	RelatedTo   []string            `schema:"related_to"`
+	RelatedKind string              `schema:"related_kind"`
+	Total       string              `schema:"total"`
+	Facets      []string            `schema:"facets"`
+	Cursor      string              `schema:"cursor"`
 }
 
 func GetHttpSearchForm() *HttpSearchForm {
@@ -63,7 +70,7 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 
 	// check page range
 	if httpSearchForm.Page < 0 || httpSearchForm.PerPage < 0 || uint64(httpSearchForm.PerPage) > h.MaxResult {
-		helper.SendWithHttpCode(res, http.StatusPreconditionFailed, "Invalid `pagination` range")
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `pagination` range")
 
 		return nil
 	}
@@ -83,7 +90,7 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 		r := rexOrderBy.FindAllStringSubmatch(order, -1)
 
 		if r == nil {
-			helper.SendWithHttpCode(res, http.StatusPreconditionFailed, "Invalid `order_by` condition")
+			helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `order_by` condition")
 
 			return nil
 		}
@@ -91,6 +98,19 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 		searchForm.OrderBy = append(searchForm.OrderBy, NewParam(nil, r[0][2], r[0][1]))
 	}
 
+	// the cursor replaces the page
+	if len(httpSearchForm.Cursor) > 0 {
+		cursor, err := ParseCursor(httpSearchForm.Cursor)
+
+		if err != nil || !cursor.Match(searchForm.OrderBy) {
+			helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `cursor` condition")
+
+			return nil
+		}
+
+		searchForm.Cursor = cursor
+	}
+
 	if len(httpSearchForm.Uuid) > 0 {
 		searchForm.Uuid = NewParam(httpSearchForm.Uuid, "=")
 	}
@@ -142,7 +162,7 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 	} else if httpSearchForm.Enabled == "false" || httpSearchForm.Enabled == "f" || httpSearchForm.Enabled == "0" {
 		searchForm.Enabled = NewParam(false, "=")
 	} else if len(httpSearchForm.Enabled) > 0 {
-		helper.SendWithHttpCode(res, http.StatusPreconditionFailed, "Invalid `enabled` condition")
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `enabled` condition")
 
 		return nil
 	}
@@ -153,7 +173,7 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 	} else if httpSearchForm.Deleted == "false" || httpSearchForm.Deleted == "f" || httpSearchForm.Deleted == "0" {
 		searchForm.Deleted = NewParam(false, "=")
 	} else if len(httpSearchForm.Deleted) > 0 {
-		helper.SendWithHttpCode(res, http.StatusPreconditionFailed, "Invalid `deleted `condition")
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `deleted `condition")
 
 		return nil
 	}
@@ -163,7 +183,7 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 	} else if httpSearchForm.Current == "false" || httpSearchForm.Current == "f" || httpSearchForm.Current == "0" {
 		searchForm.Current = NewParam(false, "=")
 	} else if len(httpSearchForm.Current) > 0 {
-		helper.SendWithHttpCode(res, http.StatusPreconditionFailed, "Invalid `current` condition")
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `current` condition")
 
 		return nil
 	}
@@ -188,5 +208,41 @@ func (h *HttpSearchParser) HandleSearch(res http.ResponseWriter, req *http.Reque
 		searchForm.Source = NewParam(httpSearchForm.Source, "=")
 	}
 
+	for _, value := range httpSearchForm.RelatedTo {
+		if _, err := core.GetReferenceFromString(value); err != nil {
+			helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `related_to` condition")
+
+			return nil
+		}
+	}
+
+	if len(httpSearchForm.RelatedTo) > 0 {
+		searchForm.RelatedTo = NewParam(httpSearchForm.RelatedTo, "=")
+	}
+
+	if len(httpSearchForm.RelatedKind) > 0 {
+		searchForm.RelatedKind = NewParam(httpSearchForm.RelatedKind, "=")
+	}
+
+	if httpSearchForm.Total == "true" || httpSearchForm.Total == "t" || httpSearchForm.Total == "1" {
+		searchForm.Total = true
+	} else if httpSearchForm.Total == "false" || httpSearchForm.Total == "f" || httpSearchForm.Total == "0" {
+		searchForm.Total = false
+	} else if len(httpSearchForm.Total) > 0 {
+		helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `total` condition")
+
+		return nil
+	}
+
+	for _, facet := range httpSearchForm.Facets {
+		if !rexFacet.MatchString(facet) {
+			helper.SendError(res, http.StatusPreconditionFailed, helper.ERROR_INVALID_PARAMETER, "Invalid `facets` condition")
+
+			return nil
+		}
+
+		searchForm.Facets = append(searchForm.Facets, facet)
+	}
+
 	return searchForm
 }
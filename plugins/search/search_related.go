@@ -0,0 +1,50 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"context"
+	"github.com/rande/gonode/core"
+)
+
+// Load the uuids of the nodes related to the RelatedTo nodes, optionally with the
+// RelatedKind kind. The relations are not stored in the nodes table, so the uuids
+// must be resolved with the NodeManager before building the query.
+func ResolveRelated(ctx context.Context, m core.NodeManager, searchForm *SearchForm) error {
+	if searchForm.RelatedTo == nil {
+		return nil
+	}
+
+	kind := ""
+
+	if searchForm.RelatedKind != nil {
+		kind = searchForm.RelatedKind.Value.(string)
+	}
+
+	uuids := make([]string, 0)
+
+	for _, value := range searchForm.RelatedTo.Value.([]string) {
+		reference, err := core.GetReferenceFromString(value)
+
+		if err != nil {
+			return core.InvalidReferenceFormatError
+		}
+
+		relations, err := m.FindRelationsContext(ctx, reference, core.GetEmptyReference(), kind)
+
+		if err != nil {
+			return err
+		}
+
+		for _, relation := range relations {
+			uuids = append(uuids, relation.To.CleanString())
+		}
+	}
+
+	searchForm.Related = NewParam(uuids, "=")
+
+	return nil
+}
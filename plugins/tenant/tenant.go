@@ -0,0 +1,125 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rande/gonode/core"
+	"github.com/zenazn/goji/web"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	UnknownTenant = errors.New("Unknown tenant")
+)
+
+// TenantResolver returns the name of the tenant of the request or an empty string
+// if the tenant cannot be resolved. The returned request is used by the next
+// resolvers and handlers.
+type TenantResolver func(req *http.Request) (string, *http.Request)
+
+// resolve the tenant from the host of the request, the port is ignored
+func HostResolver(hosts map[string]string) TenantResolver {
+	return func(req *http.Request) (string, *http.Request) {
+		host := req.Host
+
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		return hosts[strings.ToLower(host)], req
+	}
+}
+
+// resolve the tenant from the first segment of the path, the segment is removed
+// from the path so the routes are shared by the tenants, ie /acme/nodes => /nodes
+func PathResolver(paths map[string]string) TenantResolver {
+	return func(req *http.Request) (string, *http.Request) {
+		for path, name := range paths {
+			if req.URL.Path != path && !strings.HasPrefix(req.URL.Path, path+"/") {
+				continue
+			}
+
+			r := new(http.Request)
+			*r = *req
+
+			u := *req.URL
+			u.Path = "/" + strings.TrimLeft(strings.TrimPrefix(req.URL.Path, path), "/")
+			u.RawPath = ""
+
+			r.URL = &u
+			r.RequestURI = u.RequestURI()
+
+			return name, r
+		}
+
+		return "", req
+	}
+}
+
+// resolve the tenant from a claim of the bearer token, the token must be signed
+// with the guard key of the tenant
+func ClaimResolver(claim string, tenants *core.Tenants) TenantResolver {
+	return func(req *http.Request) (string, *http.Request) {
+		header := req.Header.Get("Authorization")
+
+		if len(header) < 7 || strings.ToUpper(header[0:7]) != "BEARER " {
+			return "", req
+		}
+
+		token, err := jwt.Parse(header[7:], func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+			}
+
+			name, _ := token.Claims[claim].(string)
+
+			if tenant := tenants.Get(name); tenant != nil {
+				return tenant.GuardKey, nil
+			}
+
+			return nil, UnknownTenant
+		})
+
+		if err != nil || !token.Valid {
+			return "", req
+		}
+
+		return token.Claims[claim].(string), req
+	}
+}
+
+// The middleware stores the tenant in the request's context, the first resolver
+// returning a tenant wins. The default tenant is used if no tenant is resolved.
+func GetTenantMiddleware(tenants *core.Tenants, resolvers []TenantResolver) func(c *web.C, h http.Handler) http.Handler {
+	return func(c *web.C, h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			tenant := tenants.Default
+
+			for _, resolver := range resolvers {
+				var name string
+
+				if name, r = resolver(r); name == "" {
+					continue
+				}
+
+				if t := tenants.Get(name); t != nil {
+					tenant = t
+
+					break
+				}
+			}
+
+			h.ServeHTTP(w, r.WithContext(core.NewTenantContext(r.Context(), tenant)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package tenant
+
+import (
+	"fmt"
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/core/config"
+	"github.com/zenazn/goji/web"
+	"strings"
+)
+
+// return the resolvers defined in the configuration
+func GetResolvers(conf *config.ServerTenancy, tenants *core.Tenants) ([]TenantResolver, error) {
+	resolvers := make([]TenantResolver, 0)
+
+	for _, name := range conf.Resolvers {
+		switch name {
+		case "host":
+			hosts := make(map[string]string)
+
+			for tenant, c := range conf.Tenants {
+				for _, host := range c.Hosts {
+					hosts[strings.ToLower(host)] = tenant
+				}
+			}
+
+			resolvers = append(resolvers, HostResolver(hosts))
+		case "path":
+			paths := make(map[string]string)
+
+			for tenant, c := range conf.Tenants {
+				if c.Path != "" {
+					paths["/"+strings.Trim(c.Path, "/")] = tenant
+				}
+			}
+
+			resolvers = append(resolvers, PathResolver(paths))
+		case "claim":
+			resolvers = append(resolvers, ClaimResolver(conf.Claim, tenants))
+		default:
+			return nil, fmt.Errorf("Invalid tenant resolver: %s", name)
+		}
+	}
+
+	return resolvers, nil
+}
+
+func ConfigureServer(l *goapp.Lifecycle, conf *config.ServerConfig) {
+	l.Prepare(func(app *goapp.App) error {
+		mux := app.Get("goji.mux").(*web.Mux)
+		tenants := app.Get("gonode.tenants").(*core.Tenants)
+
+		resolvers, err := GetResolvers(conf.Tenancy, tenants)
+
+		if err != nil {
+			return err
+		}
+
+		mux.Use(GetTenantMiddleware(tenants, resolvers))
+
+		return nil
+	})
+}
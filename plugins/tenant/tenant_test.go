@@ -0,0 +1,138 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package tenant
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/zenazn/goji/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getTenants() *core.Tenants {
+	tenants := core.NewTenants(&core.Tenant{Name: core.DefaultTenant, Prefix: "test", GuardKey: []byte("ZeSecretKey0oo")})
+	tenants.Add(&core.Tenant{Name: "acme", Prefix: "acme", GuardKey: []byte("AcmeSecretKey")})
+
+	return tenants
+}
+
+func getToken(claims map[string]interface{}, key string) string {
+	token := jwt.New(jwt.SigningMethodHS256)
+
+	for name, value := range claims {
+		token.Claims[name] = value
+	}
+
+	s, _ := token.SignedString([]byte(key))
+
+	return s
+}
+
+// return the tenant and the path seen by the handler
+func serve(tenants *core.Tenants, resolvers []TenantResolver, r *http.Request) (string, string) {
+	var name, path string
+
+	h := GetTenantMiddleware(tenants, resolvers)(&web.C{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name = tenants.FromContext(r.Context()).Name
+		path = r.URL.Path
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	return name, path
+}
+
+func Test_HostResolver(t *testing.T) {
+	tenants := getTenants()
+	resolvers := []TenantResolver{HostResolver(map[string]string{"acme.example.org": "acme"})}
+
+	r, _ := http.NewRequest("GET", "http://ACME.example.org:2508/nodes", nil)
+	name, path := serve(tenants, resolvers, r)
+
+	assert.Equal(t, "acme", name)
+	assert.Equal(t, "/nodes", path)
+
+	r, _ = http.NewRequest("GET", "http://example.org/nodes", nil)
+	name, _ = serve(tenants, resolvers, r)
+
+	assert.Equal(t, core.DefaultTenant, name)
+}
+
+func Test_PathResolver(t *testing.T) {
+	tenants := getTenants()
+	resolvers := []TenantResolver{PathResolver(map[string]string{"/acme": "acme"})}
+
+	r, _ := http.NewRequest("GET", "http://example.org/acme/nodes?type=core.user", nil)
+	name, path := serve(tenants, resolvers, r)
+
+	assert.Equal(t, "acme", name)
+	assert.Equal(t, "/nodes", path)
+
+	r, _ = http.NewRequest("GET", "http://example.org/acmenodes", nil)
+	name, path = serve(tenants, resolvers, r)
+
+	assert.Equal(t, core.DefaultTenant, name)
+	assert.Equal(t, "/acmenodes", path)
+}
+
+func Test_ClaimResolver(t *testing.T) {
+	tenants := getTenants()
+	resolvers := []TenantResolver{ClaimResolver("tenant", tenants)}
+
+	r, _ := http.NewRequest("GET", "http://example.org/nodes", nil)
+	r.Header.Set("Authorization", "Bearer "+getToken(map[string]interface{}{"tenant": "acme"}, "AcmeSecretKey"))
+	name, _ := serve(tenants, resolvers, r)
+
+	assert.Equal(t, "acme", name)
+
+	// the token is not signed with the tenant key
+	r.Header.Set("Authorization", "Bearer "+getToken(map[string]interface{}{"tenant": "acme"}, "ZeSecretKey0oo"))
+	name, _ = serve(tenants, resolvers, r)
+
+	assert.Equal(t, core.DefaultTenant, name)
+
+	// unknown tenant
+	r.Header.Set("Authorization", "Bearer "+getToken(map[string]interface{}{"tenant": "foo"}, "AcmeSecretKey"))
+	name, _ = serve(tenants, resolvers, r)
+
+	assert.Equal(t, core.DefaultTenant, name)
+}
+
+func Test_GetResolvers(t *testing.T) {
+	conf := config.NewServerConfig()
+	conf.Tenancy.Resolvers = []string{"path", "host"}
+	conf.Tenancy.Tenants["acme"] = &config.ServerTenant{
+		Hosts:  []string{"acme.example.org"},
+		Path:   "acme/",
+		Prefix: "acme",
+	}
+
+	resolvers, err := GetResolvers(conf.Tenancy, getTenants())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(resolvers))
+
+	r, _ := http.NewRequest("GET", "http://acme.example.org/nodes", nil)
+	name, _ := serve(getTenants(), resolvers, r)
+
+	assert.Equal(t, "acme", name)
+
+	r, _ = http.NewRequest("GET", "http://example.org/acme/nodes", nil)
+	name, path := serve(getTenants(), resolvers, r)
+
+	assert.Equal(t, "acme", name)
+	assert.Equal(t, "/nodes", path)
+
+	conf.Tenancy.Resolvers = []string{"cookie"}
+
+	_, err = GetResolvers(conf.Tenancy, getTenants())
+
+	assert.NotNil(t, err)
+}
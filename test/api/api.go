@@ -44,7 +44,7 @@ func GetNode(app *goapp.App, res *test.Response) *core.Node {
 }
 
 func InitSearchFixture(app *goapp.App) []*core.Node {
-	manager := app.Get("gonode.manager").(*core.PgNodeManager)
+	manager := app.Get("gonode.manager").(core.NodeManager)
 	collection := app.Get("gonode.handler_collection").(core.Handlers)
 	nodes := make([]*core.Node, 0)
 
@@ -0,0 +1,180 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/api"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getBulkResult(res *test.Response) *api.ApiBulkResult {
+	result := &api.ApiBulkResult{}
+
+	json.Unmarshal(res.GetBody(), result)
+
+	return result
+}
+
+func getBulkStatuses(result *api.ApiBulkResult) []int {
+	statuses := make([]int, 0)
+
+	for _, item := range result.Items {
+		statuses = append(statuses, item.Status)
+	}
+
+	return statuses
+}
+
+func newBulkUser(name string, setUuid string) string {
+	if setUuid != "" {
+		setUuid = fmt.Sprintf(`"set_uuid": "%s", `, setUuid)
+	}
+
+	return fmt.Sprintf(`{"op": "create", "node": {"type": "core.user", "name": "%s", "slug": "%s", %s"data": {"username": "%s", "email": "%s@example.org"}}}`, name, name, setUuid, name, name)
+}
+
+func Test_Bulk_Atomic(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// NDJSON
+		body := strings.Join([]string{
+			newBulkUser("user-c", ""),
+			fmt.Sprintf(`{"op": "update", "node": {"uuid": "%s", "type": "core.user", "name": "User A2", "slug": "user-a", "revision": %d, "data": {"username": "user-a", "email": "user-a@example.org"}}}`, nodes[0].Uuid.CleanString(), nodes[0].Revision),
+			fmt.Sprintf(`{"op": "delete", "uuid": "%s"}`, nodes[2].Uuid.CleanString()),
+			fmt.Sprintf(`{"op": "move", "uuid": "%s", "parent_uuid": "%s"}`, nodes[1].Uuid.CleanString(), nodes[0].Uuid.CleanString()),
+		}, "\n")
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes/_bulk", strings.NewReader(body), auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		result := getBulkResult(res)
+
+		assert.Equal(t, "OK", result.Status)
+		assert.Equal(t, []int{201, 200, 200, 200}, getBulkStatuses(result))
+
+		created, _ := core.GetReferenceFromString(result.Items[0].Uuid)
+
+		assert.NotNil(t, manager.Find(created))
+		assert.Equal(t, "User A2", manager.Find(nodes[0].Uuid).Name)
+		assert.True(t, manager.Find(nodes[2].Uuid).Deleted)
+		assert.Equal(t, nodes[0].Uuid, manager.Find(nodes[1].Uuid).ParentUuid)
+
+		// JSON array, the update uses an old revision
+		body = fmt.Sprintf(`[%s, %s, %s]`,
+			newBulkUser("user-d", ""),
+			fmt.Sprintf(`{"op": "update", "node": {"uuid": "%s", "type": "core.user", "name": "User A3", "slug": "user-a", "revision": %d, "data": {"username": "user-a", "email": "user-a@example.org"}}}`, nodes[0].Uuid.CleanString(), nodes[0].Revision),
+			newBulkUser("user-e", ""),
+		)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk", strings.NewReader(body), auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		result = getBulkResult(res)
+
+		assert.Equal(t, "KO", result.Status)
+		assert.Equal(t, []int{424, 409, 424}, getBulkStatuses(result))
+
+		// nothing is saved
+		created, _ = core.GetReferenceFromString(result.Items[0].Uuid)
+
+		assert.Nil(t, manager.Find(created))
+		assert.Equal(t, "User A2", manager.Find(nodes[0].Uuid).Name)
+
+		// the moves altering no node fail: a missing or deleted parent, or a parent inside the node's subtree
+		body = strings.Join([]string{
+			fmt.Sprintf(`{"op": "move", "uuid": "%s", "parent_uuid": "%s"}`, nodes[0].Uuid.CleanString(), "d703a3ab-8374-4c30-a8a4-2c22aa67763b"),
+			fmt.Sprintf(`{"op": "move", "uuid": "%s", "parent_uuid": "%s"}`, nodes[0].Uuid.CleanString(), nodes[2].Uuid.CleanString()),
+			fmt.Sprintf(`{"op": "move", "uuid": "%s", "parent_uuid": "%s"}`, nodes[0].Uuid.CleanString(), nodes[1].Uuid.CleanString()),
+			fmt.Sprintf(`{"op": "move", "uuid": "%s", "parent_uuid": "%s"}`, nodes[0].Uuid.CleanString(), nodes[0].Uuid.CleanString()),
+		}, "\n")
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk?mode=best_effort", strings.NewReader(body), auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		result = getBulkResult(res)
+
+		assert.Equal(t, "KO", result.Status)
+		assert.Equal(t, []int{404, 404, 412, 412}, getBulkStatuses(result))
+		assert.Equal(t, "validation_failed", result.Items[2].Code)
+		assert.Equal(t, nodes[0].ParentUuid.CleanString(), manager.Find(nodes[0].Uuid).ParentUuid.CleanString())
+
+		// in atomic mode, the request fails
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk", strings.NewReader(body), auth)
+		assert.Equal(t, 412, res.StatusCode)
+		assert.Equal(t, "KO", getBulkResult(res).Status)
+	})
+}
+
+func Test_Bulk_Best_Effort(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		body := strings.Join([]string{
+			newBulkUser("user-a", ""),
+			newBulkUser("user-b", "d703a3ab-8374-4c30-a8a4-2c22aa67763b"), // the set does not exist
+			newBulkUser("user-c", ""),
+			`{"op": "rename", "uuid": "d703a3ab-8374-4c30-a8a4-2c22aa67763b"}`,
+			fmt.Sprintf(`{"op": "delete", "uuid": "%s"}`, "d703a3ab-8374-4c30-a8a4-2c22aa67763b"),
+		}, "\n")
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes/_bulk?mode=best_effort&batch_size=2", strings.NewReader(body), auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		result := getBulkResult(res)
+
+		assert.Equal(t, "KO", result.Status)
+		assert.Equal(t, []int{201, 412, 201, 400, 404}, getBulkStatuses(result))
+		assert.True(t, result.Items[1].Errors.HasError("set_uuid"))
+
+		for _, i := range []int{0, 2} {
+			reference, _ := core.GetReferenceFromString(result.Items[i].Uuid)
+
+			assert.NotNil(t, manager.Find(reference))
+		}
+
+		// the invalid nodes are rejected by the operation, not by the request
+		body = strings.Join([]string{
+			fmt.Sprintf(`{"op": "update", "node": {"uuid": "%s", "type": "media.image", "revision": 1}}`, result.Items[0].Uuid),
+			`{"op": "create", "node": {"type": "core.user", "data": "invalid"}}`,
+		}, "\n")
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk?mode=best_effort", strings.NewReader(body), auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		result = getBulkResult(res)
+
+		assert.Equal(t, []int{400, 400}, getBulkStatuses(result))
+		assert.Equal(t, "type_mismatch", result.Items[0].Code)
+		assert.Equal(t, "invalid_body", result.Items[1].Code)
+	})
+}
+
+func Test_Bulk_Invalid_Request(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes/_bulk", strings.NewReader(`[{"op": "create"`), auth)
+		assert.Equal(t, 400, res.StatusCode)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk?mode=partial", strings.NewReader(`[]`), auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/_bulk?mode=best_effort&batch_size=0", strings.NewReader(`[]`), auth)
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Conditional_Requests(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		headers := func(values ...string) map[string]string {
+			h := map[string]string{"Authorization": auth["Authorization"]}
+
+			for i := 0; i < len(values); i += 2 {
+				h[values[i]] = values[i+1]
+			}
+
+			return h
+		}
+
+		file, _ := os.Open("../fixtures/new_user.json")
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes", file, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		node := GetNode(app, res)
+		url := ts.URL + "/nodes/" + node.Uuid.CleanString()
+
+		res, _ = test.RunRequest("GET", url, nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get("ETag"))
+		assert.NotEmpty(t, res.Header.Get("Last-Modified"))
+
+		etag := res.Header.Get("ETag")
+		modified := res.Header.Get("Last-Modified")
+
+		res, _ = test.RunRequest("GET", url, nil, headers("If-None-Match", etag))
+		assert.Equal(t, 304, res.StatusCode)
+		assert.Empty(t, res.GetBody())
+
+		// the expanded nodes are not part of the entity tag
+		res, _ = test.RunRequest("GET", url+"?expand=created_by", nil, headers("If-None-Match", etag))
+		assert.Equal(t, 200, res.StatusCode)
+
+		// the If-Match header replaces the revision of the body
+		body := `{"uuid": "%s", "type": "core.user", "name": "User 12 updated", "slug": "the-user-12", "revision": 42, "data": {"username": "user12", "email": "user-12@exemple.org"}}`
+		body = fmt.Sprintf(body, node.Uuid.CleanString())
+
+		res, _ = test.RunRequest("PUT", url, strings.NewReader(body), headers("If-Match", `"other-v1"`))
+		assert.Equal(t, 412, res.StatusCode)
+
+		time.Sleep(time.Second) // the Last-Modified header has a second resolution
+
+		res, _ = test.RunRequest("PUT", url, strings.NewReader(body), headers("If-Match", etag))
+		assert.Equal(t, 200, res.StatusCode)
+
+		updated := GetNode(app, res)
+
+		assert.Equal(t, "User 12 updated", updated.Name)
+		assert.Equal(t, node.Revision+1, updated.Revision)
+
+		// the previous revision does not match anymore
+		res, _ = test.RunRequest("GET", url, nil, headers("If-None-Match", etag))
+		assert.Equal(t, 200, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+		assert.NotEqual(t, modified, res.Header.Get("Last-Modified"))
+
+		current := res.Header.Get("ETag")
+
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`{"name": "patched"}`), headers("If-Match", etag, "Content-Type", "application/merge-patch+json"))
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("DELETE", url, nil, headers("If-Match", etag))
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("DELETE", url, nil, headers("If-Match", current))
+		assert.Equal(t, 200, res.StatusCode)
+
+		assert.True(t, app.Get("gonode.manager").(core.NodeManager).Find(node.Uuid).Deleted)
+
+		// the soft delete does not create a revision but changes the tag
+		res, _ = test.RunRequest("GET", url, nil, headers("If-None-Match", current))
+		assert.NotEqual(t, 304, res.StatusCode)
+	})
+}
+
+func Test_Conditional_Raw_Requests(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		file, _ := os.Open("../fixtures/new_image.json")
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes", file, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		node := GetNode(app, res)
+		url := ts.URL + "/nodes/" + node.Uuid.CleanString() + "?raw"
+
+		res, _ = test.RunRequest("GET", url, nil, auth)
+		etag := res.Header.Get("ETag")
+
+		res, _ = test.RunRequest("PUT", url, strings.NewReader("binary"), map[string]string{"Authorization": auth["Authorization"], "If-Match": `"other-v1"`})
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("PUT", url, strings.NewReader("binary"), map[string]string{"Authorization": auth["Authorization"], "If-Match": etag})
+		assert.Equal(t, 200, res.StatusCode)
+
+		// the stored file alters the meta of the node without a new revision
+		res, _ = test.RunRequest("GET", url, nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+
+		etag = res.Header.Get("ETag")
+
+		res, _ = test.RunRequest("GET", url, nil, map[string]string{"Authorization": auth["Authorization"], "If-None-Match": etag})
+		assert.Equal(t, 304, res.StatusCode)
+	})
+}
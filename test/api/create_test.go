@@ -78,3 +78,15 @@ func Test_Create_Media_With_Binary_Upload(t *testing.T) {
 		assert.Equal(t, "application/octet-stream", meta.ContentType)
 	})
 }
+
+func Test_Create_Invalid_Body(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		for _, body := range []string{"", "{invalid", `{"type": "core.user", "data": "invalid"}`} {
+			res, _ := test.RunRequest("POST", ts.URL+"/nodes", strings.NewReader(body), auth)
+
+			assert.Equal(t, 400, res.StatusCode, body)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/api"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func getPagerNames(p *api.ApiPager) []string {
+	names := []string{}
+
+	for _, e := range p.Elements {
+		names = append(names, e.(*core.Node).Name)
+	}
+
+	return names
+}
+
+func Test_Search_Cursor(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// WITH
+		InitSearchFixture(app)
+
+		auth := test.GetAuthHeader(t, ts)
+		url := ts.URL + "/nodes?per_page=2&order_by=weight,ASC"
+
+		// WHEN
+		res, _ := test.RunRequest("GET", url, nil, auth)
+		p := GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, []string{"User ZZ", "User A"}, getPagerNames(p))
+		assert.Equal(t, "", p.PreviousCursor)
+		assert.NotEqual(t, "", p.NextCursor)
+
+		// WHEN
+		res, _ = test.RunRequest("GET", url+"&cursor="+p.NextCursor, nil, auth)
+		p = GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, uint64(0), p.Page)
+		assert.Equal(t, []string{"User B", "User AA"}, getPagerNames(p))
+		assert.Equal(t, "", p.NextCursor)
+		assert.NotEqual(t, "", p.PreviousCursor)
+
+		// WHEN
+		res, _ = test.RunRequest("GET", url+"&cursor="+p.PreviousCursor, nil, auth)
+		p = GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, []string{"User ZZ", "User A"}, getPagerNames(p))
+		assert.Equal(t, "", p.PreviousCursor)
+		assert.NotEqual(t, "", p.NextCursor)
+	})
+}
+
+func Test_Search_Cursor_With_Inserted_Node(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// WITH
+		InitSearchFixture(app)
+
+		auth := test.GetAuthHeader(t, ts)
+		url := ts.URL + "/nodes?per_page=2&order_by=name,DESC"
+
+		res, _ := test.RunRequest("GET", url, nil, auth)
+		p := GetPager(app, res)
+
+		assert.Equal(t, []string{"User ZZ", "User B"}, getPagerNames(p))
+
+		// a node inserted in the first page does not shift the next one
+		node := app.Get("gonode.handler_collection").(core.Handlers).NewNode("core.user")
+		node.Name = "User C"
+		app.Get("gonode.manager").(core.NodeManager).Save(node, false)
+
+		// WHEN
+		res, _ = test.RunRequest("GET", url+"&cursor="+p.NextCursor, nil, auth)
+		p = GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, []string{"User AA", "User A"}, getPagerNames(p))
+	})
+}
+
+func Test_Search_Invalid_Cursor(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		InitSearchFixture(app)
+
+		auth := test.GetAuthHeader(t, ts)
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes?per_page=2&order_by=weight,ASC", nil, auth)
+		p := GetPager(app, res)
+
+		// the cursor does not match the order
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?per_page=2&order_by=name,ASC&cursor="+p.NextCursor, nil, auth)
+
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?cursor=foobar", nil, auth)
+
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
+
+func Test_Search_Revisions_Cursor(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// WITH
+		manager := app.Get("gonode.manager").(core.NodeManager)
+		node := InitSearchFixture(app)[0]
+
+		for i := 0; i < 3; i++ {
+			manager.Save(node, true)
+		}
+
+		auth := test.GetAuthHeader(t, ts)
+		url := ts.URL + "/nodes/" + node.Uuid.CleanString() + "/revisions?per_page=3&order_by=revision,DESC"
+
+		// WHEN
+		res, _ := test.RunRequest("GET", url, nil, auth)
+		p := GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, 3, len(p.Elements))
+		assert.Equal(t, 4, p.Elements[0].(*core.Node).Revision)
+
+		res, _ = test.RunRequest("GET", url+"&cursor="+p.NextCursor, nil, auth)
+		p = GetPager(app, res)
+
+		assert.Equal(t, 1, len(p.Elements))
+		assert.Equal(t, 1, p.Elements[0].(*core.Node).Revision)
+		assert.Equal(t, "", p.NextCursor)
+	})
+}
+
+func Test_Search_Cursor_Sparse_Field(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// WITH 3 users and 2 images without username
+		InitSearchFixture(app)
+
+		manager := app.Get("gonode.manager").(core.NodeManager)
+		collection := app.Get("gonode.handler_collection").(core.Handlers)
+
+		for _, name := range []string{"Image A", "Image B"} {
+			node := collection.NewNode("media.image")
+			node.Name = name
+			manager.Save(node, false)
+		}
+
+		auth := test.GetAuthHeader(t, ts)
+
+		for _, order := range []string{"ASC", "DESC"} {
+			url := ts.URL + "/nodes?per_page=2&order_by=data.username," + order
+
+			res, _ := test.RunRequest("GET", url, nil, auth)
+			p := GetPager(app, res)
+
+			names := getPagerNames(p)
+			pages := []*api.ApiPager{p}
+
+			// WHEN
+			for i := 0; p.NextCursor != "" && i < 5; i++ {
+				res, _ = test.RunRequest("GET", url+"&cursor="+p.NextCursor, nil, auth)
+				assert.Equal(t, 200, res.StatusCode, order)
+
+				p = GetPager(app, res)
+				names = append(names, getPagerNames(p)...)
+				pages = append(pages, p)
+			}
+
+			// THEN the nodes without username are not skipped
+			res, _ = test.RunRequest("GET", ts.URL+"/nodes?per_page=100&order_by=data.username,"+order, nil, auth)
+
+			assert.Equal(t, getPagerNames(GetPager(app, res)), names, order)
+			assert.Contains(t, names, "Image A", order)
+			assert.Contains(t, names, "Image B", order)
+
+			// the previous cursors go back through the same pages
+			for i := len(pages) - 1; i > 0; i-- {
+				res, _ = test.RunRequest("GET", url+"&cursor="+pages[i].PreviousCursor, nil, auth)
+
+				assert.Equal(t, getPagerNames(pages[i-1]), getPagerNames(GetPager(app, res)), order)
+			}
+		}
+	})
+}
@@ -6,13 +6,18 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	. "github.com/rande/goapp"
 	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/user"
 	"github.com/rande/gonode/test"
 	"github.com/stretchr/testify/assert"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 )
 
 func Test_Delete_Non_Existant_Node(t *testing.T) {
@@ -76,3 +81,102 @@ func Test_Delete_Find_Filter(t *testing.T) {
 		assert.Equal(t, 3, len(p.Elements))
 	})
 }
+
+func Test_Undelete_Node(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+
+		res, _ := test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		res, _ = test.RunRequest("PUT", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/undelete", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		node := GetNode(app, res)
+		assert.False(t, node.Deleted)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes", nil, auth)
+		assert.Equal(t, 4, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("PUT", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b/undelete", nil, auth)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
+func Test_Purge_Nodes(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		res, _ := test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// the node has been deleted after the date
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/purge?before=2015-01-01", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "{\"status\":\"OK\",\"message\":\"Node purged: 0\"}\n", string(res.GetBody()))
+
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/purge?before="+url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))+"&keep_audit=1", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "{\"status\":\"OK\",\"message\":\"Node purged: 1\"}\n", string(res.GetBody()))
+
+		assert.Nil(t, manager.Find(nodes[0].Uuid))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/revisions/1", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/purge?before=yesterday", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
+
+func Test_Purge_Recently_Deleted_Node(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// the node was edited a year ago
+		node := app.Get("gonode.handler_collection").(core.Handlers).NewNode("core.user")
+		node.Name = "User A"
+		node.Data.(*user.User).Username = "user-a"
+		node.UpdatedAt = time.Now().AddDate(-1, 0, 0)
+		manager.Save(node, false)
+
+		res, _ := test.RunRequest("DELETE", ts.URL+"/nodes/"+node.Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// the deletion time is used, not the last edition
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/purge?before="+url.QueryEscape(time.Now().AddDate(0, 0, -1).Format(time.RFC3339)), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "{\"status\":\"OK\",\"message\":\"Node purged: 0\"}\n", string(res.GetBody()))
+
+		assert.NotNil(t, manager.Find(node.Uuid))
+	})
+}
+
+func Test_Save_Deleted_Node(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// the type cannot be altered
+		node := manager.Find(nodes[1].Uuid)
+		node.Type = "media.image"
+		data, _ := json.Marshal(node)
+
+		res, _ := test.RunRequest("PUT", ts.URL+"/nodes/"+node.Uuid.CleanString(), bytes.NewReader(data), auth)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// a deleted node must be undeleted first
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		data, _ = json.Marshal(manager.Find(nodes[0].Uuid))
+
+		res, _ = test.RunRequest("PUT", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), bytes.NewReader(data), auth)
+		assert.Equal(t, 410, res.StatusCode)
+	})
+}
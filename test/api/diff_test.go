@@ -0,0 +1,78 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/user"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Diff_Revisions(t *testing.T) {
+
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// GIVEN
+		u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		data := u.Data.(*user.User)
+		data.Email = "test@example.org"
+		data.FirstName = "Thomas"
+		data.NewPassword = "ZePassword"
+		data.Username = "rande"
+		u.Name = "Title 1"
+
+		u.Meta.(*user.UserMeta).PasswordCost = 1 // save test time
+
+		u, err := manager.Save(u, true)
+		core.PanicOnError(err)
+
+		u.Name = "Title 2"
+		u.Data.(*user.User).FirstName = "Tom"
+		u, err = manager.Save(u, true)
+		core.PanicOnError(err)
+
+		auth := test.GetAuthHeader(t, ts)
+		baseUrl := fmt.Sprintf("%s/nodes/%s/revisions", ts.URL, u.Uuid.CleanString())
+
+		// WHEN
+		res, _ := test.RunRequest("GET", baseUrl+"/1/diff/2", nil, auth)
+
+		// THEN
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		patch := []map[string]interface{}{}
+		json.Unmarshal(res.GetBody(), &patch)
+
+		changes := map[string]interface{}{}
+		for _, op := range patch {
+			assert.Equal(t, "replace", op["op"])
+			changes[op["path"].(string)] = op["value"]
+		}
+
+		assert.Equal(t, "Title 2", changes["/name"])
+		assert.Equal(t, "Tom", changes["/data/firstname"])
+		assert.Equal(t, float64(2), changes["/revision"])
+
+		// same revision
+		res, _ = test.RunRequest("GET", baseUrl+"/2/diff/2", nil, auth)
+		assert.Equal(t, "[]\n", string(res.GetBody()))
+
+		// unknown revision
+		res, _ = test.RunRequest("GET", baseUrl+"/1/diff/100", nil, auth)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = test.RunRequest("GET", baseUrl+"/foo/diff/1", nil, auth)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/helper"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Error_Responses(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b", nil, auth)
+		assert.Equal(t, 404, res.StatusCode)
+
+		e := &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "KO", e.Status)
+		assert.Equal(t, "not_found", e.Code)
+		assert.Equal(t, 404, e.HttpCode)
+		assert.NotEqual(t, "", e.RequestId)
+		assert.Equal(t, res.Header.Get(helper.RequestIdHeader), e.RequestId)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?per_page=-1", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, helper.ERROR_INVALID_PARAMETER, e.Code)
+
+		// the requests without a valid token are rejected
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/protected", nil, map[string]string{"Authorization": "Bearer invalid"})
+		assert.Equal(t, 403, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, helper.ERROR_AUTHENTICATION, e.Code)
+		assert.NotEqual(t, "", e.RequestId)
+
+		// the unknown fields of the login form are rejected
+		res, _ = test.RunRequest("POST", ts.URL+"/login", url.Values{
+			"username": {"test-admin"},
+			"password": {"admin"},
+			"remember": {"1"},
+		})
+		assert.Equal(t, 400, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, helper.ERROR_INVALID_PARAMETER, e.Code)
+
+		// the raw download of an unknown node
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b?raw", nil, auth)
+		assert.Equal(t, 404, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "not_found", e.Code)
+
+		// the invalid references of the raw requests are client errors
+		for _, method := range []string{"GET", "PUT"} {
+			res, _ = test.RunRequest(method, ts.URL+"/nodes/invalid?raw", strings.NewReader("binary"), auth)
+			assert.Equal(t, 400, res.StatusCode, method)
+
+			e = &helper.ErrorResponse{}
+			json.Unmarshal(res.GetBody(), e)
+
+			assert.Equal(t, 400, e.HttpCode, method)
+			assert.NotEqual(t, "", e.Code, method)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Expand_Nodes(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// nodes[2] -> nodes[1] -> nodes[0]
+		manager.Move(nodes[1].Uuid, nodes[2].Uuid)
+		manager.Move(nodes[0].Uuid, nodes[1].Uuid)
+
+		type expanded struct {
+			Uuid     string                 `json:"uuid"`
+			Expanded map[string]interface{} `json:"_expanded"`
+		}
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"?expand=parent_uuid,created_by&expand_depth=2", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		type expandedNode struct {
+			Uuid     string `json:"uuid"`
+			Expanded struct {
+				ParentUuid expanded `json:"parent_uuid"`
+			} `json:"_expanded"`
+		}
+
+		node := expandedNode{}
+		json.Unmarshal(res.GetBody(), &node)
+
+		assert.Equal(t, nodes[0].Uuid.CleanString(), node.Uuid)
+		assert.Equal(t, nodes[1].Uuid.CleanString(), node.Expanded.ParentUuid.Uuid)
+		assert.Equal(t, nodes[2].Uuid.CleanString(), node.Expanded.ParentUuid.Expanded["parent_uuid"].(map[string]interface{})["uuid"])
+
+		// the default depth is 1
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"?expand=parent_uuid", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		node = expandedNode{}
+		json.Unmarshal(res.GetBody(), &node)
+
+		assert.Equal(t, nodes[1].Uuid.CleanString(), node.Expanded.ParentUuid.Uuid)
+		assert.Nil(t, node.Expanded.ParentUuid.Expanded)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?expand=parent_uuid&type=core.user", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		pager := struct {
+			Elements []expanded `json:"elements"`
+		}{}
+
+		json.Unmarshal(res.GetBody(), &pager)
+
+		count := 0
+		for _, element := range pager.Elements {
+			if _, ok := element.Expanded["parent_uuid"]; ok {
+				count++
+			}
+		}
+
+		assert.Equal(t, 2, count)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"?expand=foo", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?expand=parent_uuid&expand_depth=9", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Search_Total_And_Facets(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// WITH
+		InitSearchFixture(app)
+
+		auth := test.GetAuthHeader(t, ts)
+
+		// WHEN
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes?per_page=1&total=1&facets=type&facets=weight&facets=data.firstname&data.firstname=User", nil, auth)
+
+		p := GetPager(app, res)
+
+		// THEN
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 1, len(p.Elements))
+		assert.Equal(t, uint64(3), *p.Total)
+		assert.Equal(t, []*core.AggregateValue{{Value: "core.user", Count: 3}}, p.Facets["type"])
+		assert.Equal(t, []*core.AggregateValue{{Value: "1", Count: 2}, {Value: "2", Count: 1}}, p.Facets["weight"])
+		assert.Equal(t, []*core.AggregateValue{{Value: "User", Count: 3}}, p.Facets["data.firstname"])
+	})
+}
+
+func Test_Search_Without_Total(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes", nil, auth)
+
+		p := GetPager(app, res)
+
+		assert.Nil(t, p.Total)
+		assert.Nil(t, p.Facets)
+	})
+}
+
+func Test_Search_Invalid_Facet(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		auth := test.GetAuthHeader(t, ts)
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes?facets=data.'name", nil, auth)
+
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
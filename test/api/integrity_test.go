@@ -0,0 +1,52 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the test configuration rejects a missing set_uuid and restricts the delete
+func Test_Integrity_Nodes(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+
+		body := `{"type": "core.user", "name": "User D", "slug": "user-d", "set_uuid": "%s", "data": {"username": "user-d", "email": "user-d@exemple.org"}}`
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes", strings.NewReader(strings.Replace(body, "%s", "d703a3ab-8374-4c30-a8a4-2c22aa67763b", 1)), auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		e := &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "validation_failed", e.Code)
+		assert.True(t, core.Errors(e.Errors).HasError("set_uuid"))
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes", strings.NewReader(strings.Replace(body, "%s", nodes[0].Uuid.CleanString(), 1)), auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "integrity_violation", e.Code)
+		assert.True(t, core.Errors(e.Errors).HasError("set_uuid"))
+
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[1].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
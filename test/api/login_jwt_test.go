@@ -29,7 +29,7 @@ func Test_Create_Username(t *testing.T) {
 
 		// WITH
 		// create a valid user into the database ...
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
 		u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
 		data := u.Data.(*user.User)
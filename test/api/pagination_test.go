@@ -33,7 +33,7 @@ func Test_Pagination(t *testing.T) {
 
 		// WITH
 		// create a valid user into the database ...
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
 		for i := 0; i < 11; i++ {
 			u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
@@ -20,7 +20,7 @@ func Test_Create_Parents_With_Manager(t *testing.T) {
 	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
 		// WITH
 		handlers := app.Get("gonode.handler_collection").(core.HandlerCollection)
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
 		node1 := handlers.NewNode("default")
 		manager.Save(node1, false)
@@ -70,7 +70,7 @@ func Test_Create_Parents_With_Api(t *testing.T) {
 		auth := test.GetAuthHeader(t, ts)
 
 		handlers := app.Get("gonode.handler_collection").(core.HandlerCollection)
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
 		node1 := handlers.NewNode("default")
 		manager.Save(node1, false)
@@ -0,0 +1,99 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"github.com/rande/gonode/plugins/user"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Patch_Node(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		headers := func(contentType string) map[string]string {
+			return map[string]string{"Authorization": auth["Authorization"], "Content-Type": contentType}
+		}
+
+		file, _ := os.Open("../fixtures/new_user.json")
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes", file, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		node := GetNode(app, res)
+		url := ts.URL + "/nodes/" + node.Uuid.CleanString()
+
+		// the merge patch only alters the given fields
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`{"name": "User 12 patched", "data": {"lastname": "Twelve"}}`), headers("application/merge-patch+json"))
+		assert.Equal(t, 200, res.StatusCode)
+
+		patched := GetNode(app, res)
+
+		assert.Equal(t, "User 12 patched", patched.Name)
+		assert.Equal(t, "User", patched.Data.(*user.User).FirstName)
+		assert.Equal(t, "Twelve", patched.Data.(*user.User).LastName)
+		assert.Equal(t, node.Revision+1, patched.Revision)
+
+		// the test operation is a precondition on the revision
+		body := `[{"op": "test", "path": "/revision", "value": %d}, {"op": "replace", "path": "/data/firstname", "value": "Member"}]`
+
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(fmt.Sprintf(body, node.Revision)), headers("application/json-patch+json"))
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(fmt.Sprintf(body, patched.Revision)), headers("application/json-patch+json"))
+		assert.Equal(t, 200, res.StatusCode)
+
+		patched = GetNode(app, res)
+
+		assert.Equal(t, "Member", patched.Data.(*user.User).FirstName)
+		assert.Equal(t, "Twelve", patched.Data.(*user.User).LastName)
+
+		// the patched node is validated
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`[{"op": "replace", "path": "/data/email", "value": "invalid"}]`), headers("application/json-patch+json"))
+		assert.Equal(t, 412, res.StatusCode)
+
+		e := &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "validation_failed", e.Code)
+		assert.True(t, core.Errors(e.Errors).HasError("data.email"))
+
+		// a stale revision in a merge patch is rejected
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(fmt.Sprintf(`{"revision": %d, "name": "stale"}`, node.Revision)), headers("application/merge-patch+json"))
+		assert.Equal(t, 409, res.StatusCode)
+
+		// invalid patches
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`[{"op": "replace", "path": "/missing/field", "value": 1}]`), headers("application/json-patch+json"))
+		assert.Equal(t, 400, res.StatusCode)
+
+		e = &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.Equal(t, "invalid_patch", e.Code)
+
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`{"type": "media.image"}`), headers("application/merge-patch+json"))
+		assert.Equal(t, 400, res.StatusCode)
+
+		res, _ = test.RunRequest("PATCH", url, strings.NewReader(`{"name": "json"}`), headers("application/json"))
+		assert.Equal(t, 415, res.StatusCode)
+
+		res, _ = test.RunRequest("PATCH", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b", strings.NewReader(`{}`), headers("application/merge-patch+json"))
+		assert.Equal(t, 404, res.StatusCode)
+
+		saved := app.Get("gonode.manager").(core.NodeManager).Find(node.Uuid)
+
+		assert.Equal(t, "User 12 patched", saved.Name)
+		assert.Equal(t, "user-12@exemple.org", saved.Data.(*user.User).Email)
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Relations(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		body := strings.NewReader(`{"to": "` + nodes[1].Uuid.CleanString() + `", "kind": "cover", "on_delete": "cascade"}`)
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/relations", body, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		body = strings.NewReader(`{"to": "` + nodes[2].Uuid.CleanString() + `", "kind": "related"}`)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/relations", body, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/relations", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/relations?kind=cover", nil, auth)
+		assert.Equal(t, 1, len(GetPager(app, res).Elements))
+
+		// search the related nodes
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?related_to="+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?related_to="+nodes[0].Uuid.CleanString()+"&related_kind=related", nil, auth)
+		assert.Equal(t, 1, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?related_to="+nodes[1].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 0, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?related_to=foo", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/relations/"+nodes[2].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "{\"status\":\"OK\",\"message\":\"Relation removed: 1\"}\n", string(res.GetBody()))
+
+		// the cover is removed with the node
+		res, _ = test.RunRequest("DELETE", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		assert.True(t, manager.Find(nodes[1].Uuid).Deleted)
+
+		// invalid relations
+		body = strings.NewReader(`{"to": "` + nodes[2].Uuid.CleanString() + `", "kind": ""}`)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/"+nodes[2].Uuid.CleanString()+"/relations", body, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		body = strings.NewReader(`{"to": "` + nodes[1].Uuid.CleanString() + `", "kind": "cover"}`)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/"+nodes[2].Uuid.CleanString()+"/relations", body, auth)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
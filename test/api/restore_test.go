@@ -0,0 +1,93 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/helper"
+	"github.com/rande/gonode/plugins/user"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Restore_Revision(t *testing.T) {
+
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		// GIVEN
+		u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		data := u.Data.(*user.User)
+		data.Email = "test@example.org"
+		data.Enabled = true
+		data.FirstName = "Thomas"
+		data.NewPassword = "ZePassword"
+		data.Username = "rande"
+		u.Name = "Title 1"
+
+		u.Meta.(*user.UserMeta).PasswordCost = 1 // save test time
+
+		u, err := manager.Save(u, true)
+		core.PanicOnError(err)
+
+		u.Name = "Title 2"
+		u.Data.(*user.User).FirstName = "Tom"
+		u, err = manager.Save(u, true)
+		core.PanicOnError(err)
+
+		auth := test.GetAuthHeader(t, ts)
+		baseUrl := fmt.Sprintf("%s/nodes/%s/revisions", ts.URL, u.Uuid.CleanString())
+
+		// WHEN
+		res, _ := test.RunRequest("PUT", baseUrl+"/1/restore", nil, auth)
+
+		// THEN
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		node := GetNode(app, res)
+		assert.Equal(t, 3, node.Revision)
+		assert.Equal(t, "Title 1", node.Name)
+		assert.Equal(t, "Thomas", node.Data.(*user.User).FirstName)
+
+		// the restored revision is kept in the history
+		res, _ = test.RunRequest("GET", baseUrl+"/2", nil, auth)
+		assert.Equal(t, "Title 2", GetNode(app, res).Name)
+
+		// unknown revision
+		res, _ = test.RunRequest("PUT", baseUrl+"/100/restore", nil, auth)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = test.RunRequest("PUT", baseUrl+"/foo/restore", nil, auth)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		// the field errors of the invalid revision are returned
+		u = manager.Find(u.Uuid)
+		u.Data.(*user.User).Email = "invalid"
+		u, err = manager.Save(u, true)
+		core.PanicOnError(err)
+
+		invalid := u.Revision
+
+		u.Data.(*user.User).Email = "test@example.org"
+		u, err = manager.Save(u, true)
+		core.PanicOnError(err)
+
+		res, _ = test.RunRequest("PUT", fmt.Sprintf("%s/%d/restore", baseUrl, invalid), nil, auth)
+		assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+		e := &helper.ErrorResponse{}
+		json.Unmarshal(res.GetBody(), e)
+
+		assert.True(t, core.Errors(e.Errors).HasError("data.email"))
+		assert.Equal(t, u.Revision, manager.Find(u.Uuid).Revision)
+	})
+}
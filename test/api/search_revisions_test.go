@@ -24,7 +24,7 @@ func Test_Search_Revision_Basic(t *testing.T) {
 	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
 
 		u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
 		data := u.Data.(*user.User)
 		data.Email = "test@example.org"
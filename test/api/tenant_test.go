@@ -0,0 +1,126 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/plugins/user"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// create the admin user of the tenant and return the token of the user
+func getTenantToken(t *testing.T, ts *httptest.Server, tenant *core.Tenant, path string) string {
+	u := tenant.Handlers.NewNode("core.user")
+	u.Name = "Acme admin"
+	data := u.Data.(*user.User)
+	data.Email = "acme-admin@example.org"
+	data.Enabled = true
+	data.NewPassword = "acme"
+	data.Username = "acme-admin"
+	data.Roles = []string{"ADMIN"}
+	u.Meta.(*user.UserMeta).PasswordCost = 1
+
+	_, err := tenant.Manager.Save(u, false)
+	assert.Nil(t, err)
+
+	res, _ := test.RunRequest("POST", ts.URL+path+"/login", url.Values{
+		"username": {"acme-admin"},
+		"password": {"acme"},
+	})
+
+	assert.Equal(t, 200, res.StatusCode)
+
+	v := &struct {
+		Token string `json:"token"`
+	}{}
+
+	json.Unmarshal(res.GetBody(), v)
+
+	return v.Token
+}
+
+func Test_Tenant_Login(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		acme := app.Get("gonode.tenants").(*core.Tenants).Get("acme")
+
+		token, err := jwt.Parse(getTenantToken(t, ts, acme, "/acme"), func(token *jwt.Token) (interface{}, error) {
+			return acme.GuardKey, nil
+		})
+
+		assert.Nil(t, err)
+		assert.True(t, token.Valid)
+		assert.Equal(t, "acme", token.Claims["tenant"])
+
+		// the user of the tenant does not exist in the default tenant
+		res, _ := test.RunRequest("POST", ts.URL+"/login", url.Values{
+			"username": {"acme-admin"},
+			"password": {"acme"},
+		})
+
+		assert.Equal(t, 403, res.StatusCode)
+	})
+}
+
+func Test_Tenant_Nodes_Isolation(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *goapp.App) {
+		tenants := app.Get("gonode.tenants").(*core.Tenants)
+		acme := tenants.Get("acme")
+		auth := map[string]string{
+			"Authorization": fmt.Sprintf("Bearer %s", getTenantToken(t, ts, acme, "/acme")),
+		}
+
+		// the tenant is resolved from the claim of the token
+		file, _ := os.Open("../fixtures/new_user.json")
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes", file, auth)
+
+		assert.Equal(t, 201, res.StatusCode)
+
+		node := GetNode(app, res)
+
+		assert.NotNil(t, acme.Manager.Find(node.Uuid))
+		assert.Nil(t, tenants.Default.Manager.Find(node.Uuid))
+
+		// a token signed with the default key is rejected by the tenant
+		forged := jwt.New(jwt.SigningMethodHS256)
+		forged.Claims["usr"] = "acme-admin"
+		forged.Claims["tenant"] = "acme"
+		signed, _ := forged.SignedString(tenants.Default.GuardKey)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+node.Uuid.CleanString(), nil, map[string]string{
+			"Host":          "acme.localhost",
+			"Authorization": fmt.Sprintf("Bearer %s", signed),
+		})
+
+		assert.Equal(t, 403, res.StatusCode)
+
+		// the tenant is resolved from the host
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?type=core.user", nil, map[string]string{"Host": "acme.localhost"})
+
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		// the tenant is resolved from the path
+		res, _ = test.RunRequest("GET", ts.URL+"/acme/nodes?type=core.user", nil)
+
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		// the default tenant only contains the test admin
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes?type=core.user", nil)
+
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 1, len(GetPager(app, res).Elements))
+	})
+}
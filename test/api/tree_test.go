@@ -0,0 +1,144 @@
+// Copyright © 2014-2015 Thomas Rabaix <thomas.rabaix@gmail.com>.
+//
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	. "github.com/rande/goapp"
+	"github.com/rande/gonode/core"
+	"github.com/rande/gonode/test"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Tree_Nodes(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// nodes[0] -> nodes[1] -> nodes[2]
+		manager.Move(nodes[1].Uuid, nodes[0].Uuid)
+		manager.Move(nodes[2].Uuid, nodes[1].Uuid)
+
+		res, _ := test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/children", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 1, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/descendants", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/descendants?depth=1", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 1, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[2].Uuid.CleanString()+"/ancestors", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/tree", nil, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		tree := struct {
+			Node     map[string]interface{} `json:"node"`
+			Children []struct {
+				Node     map[string]interface{} `json:"node"`
+				Children []interface{}          `json:"children"`
+			} `json:"children"`
+		}{}
+
+		json.Unmarshal(res.GetBody(), &tree)
+
+		assert.Equal(t, nodes[0].Uuid.CleanString(), tree.Node["uuid"])
+		assert.Equal(t, 1, len(tree.Children))
+		assert.Equal(t, nodes[1].Uuid.CleanString(), tree.Children[0].Node["uuid"])
+		assert.Equal(t, 1, len(tree.Children[0].Children))
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/tree?depth=-1", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b/children", nil, auth)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
+func Test_Copy_Nodes(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		// nodes[0] -> nodes[1]
+		manager.Move(nodes[1].Uuid, nodes[0].Uuid)
+
+		res, _ := test.RunRequest("POST", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/copy?deep=1&parent="+nodes[2].Uuid.CleanString(), nil, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		node := GetNode(app, res)
+
+		assert.Equal(t, nodes[0].Uuid.CleanString(), node.Source.CleanString())
+		assert.Equal(t, nodes[2].Uuid.CleanString(), node.ParentUuid.CleanString())
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+nodes[2].Uuid.CleanString()+"/descendants", nil, auth)
+		assert.Equal(t, 2, len(GetPager(app, res).Elements))
+
+		// shallow copy at the root level, the parent is empty
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/copy", nil, auth)
+		assert.Equal(t, 201, res.StatusCode)
+
+		node = GetNode(app, res)
+
+		assert.Equal(t, "11111111-1111-1111-1111-111111111111", node.ParentUuid.CleanString())
+
+		res, _ = test.RunRequest("GET", ts.URL+"/nodes/"+node.Uuid.CleanString()+"/children", nil, auth)
+		assert.Equal(t, 0, len(GetPager(app, res).Elements))
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/copy?deep=yes", nil, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("POST", ts.URL+"/nodes/d703a3ab-8374-4c30-a8a4-2c22aa67763b/copy", nil, auth)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
+func Test_Reorder_Children(t *testing.T) {
+	test.RunHttpTest(t, func(t *testing.T, ts *httptest.Server, app *App) {
+		auth := test.GetAuthHeader(t, ts)
+		nodes := InitSearchFixture(app)
+		manager := app.Get("gonode.manager").(core.NodeManager)
+
+		manager.Move(nodes[1].Uuid, nodes[0].Uuid)
+		manager.Move(nodes[2].Uuid, nodes[0].Uuid)
+
+		body := strings.NewReader(`["` + nodes[2].Uuid.CleanString() + `", "` + nodes[1].Uuid.CleanString() + `"]`)
+
+		res, _ := test.RunRequest("PUT", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/children/order", body, auth)
+		assert.Equal(t, 200, res.StatusCode)
+
+		p := GetPager(app, res)
+
+		assert.Equal(t, 2, len(p.Elements))
+
+		children, _ := manager.FindChildren(nodes[0].Uuid)
+
+		assert.Equal(t, nodes[2].Uuid, children.Front().Value.(*core.Node).Uuid)
+		assert.Equal(t, 1, children.Front().Value.(*core.Node).Weight)
+		assert.Equal(t, 2, children.Back().Value.(*core.Node).Weight)
+		assert.Equal(t, nodes[1].Revision, children.Back().Value.(*core.Node).Revision)
+
+		// not a child
+		body = strings.NewReader(`["` + nodes[0].Uuid.CleanString() + `"]`)
+
+		res, _ = test.RunRequest("PUT", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/children/order", body, auth)
+		assert.Equal(t, 412, res.StatusCode)
+
+		res, _ = test.RunRequest("PUT", ts.URL+"/nodes/"+nodes[0].Uuid.CleanString()+"/children/order", strings.NewReader("{"), auth)
+		assert.Equal(t, 412, res.StatusCode)
+	})
+}
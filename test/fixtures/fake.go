@@ -59,7 +59,7 @@ func GetFakeUserNode(pos int) *core.Node {
 	return node
 }
 
-func LoadFixtures(m *core.PgNodeManager, max int) error {
+func LoadFixtures(m core.NodeManager, max int) error {
 
 	var err error
 
@@ -7,17 +7,20 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/rande/goapp"
 	"github.com/rande/gonode/commands/server"
 	"github.com/rande/gonode/core"
 	"github.com/rande/gonode/core/config"
+	"github.com/rande/gonode/helper"
 	"github.com/rande/gonode/plugins/api"
 	"github.com/rande/gonode/plugins/guard"
 	"github.com/rande/gonode/plugins/search"
 	"github.com/rande/gonode/plugins/security"
 	"github.com/rande/gonode/plugins/setup"
+	"github.com/rande/gonode/plugins/tenant"
 	"github.com/rande/gonode/plugins/user"
 	"github.com/stretchr/testify/assert"
 	"github.com/zenazn/goji/web"
@@ -43,6 +46,10 @@ func GetLifecycle(file string) *goapp.Lifecycle {
 
 	config.LoadConfigurationFromFile(file, conf)
 
+	if os.Getenv("GONODE_TEST_MEMORY") != "" {
+		conf.Databases["master"].Type = "memory"
+	}
+
 	l.Config(func(app *goapp.App) error {
 		app.Set("gonode.configuration", func(app *goapp.App) interface{} {
 			return conf
@@ -60,8 +67,10 @@ func GetLifecycle(file string) *goapp.Lifecycle {
 		app.Set("goji.mux", func(app *goapp.App) interface{} {
 			mux := web.New()
 
+			mux.Use(middleware.RequestID)
+			mux.Use(helper.RequestIdMiddleware)
 			mux.Use(middleware.Logger)
-			mux.Use(middleware.Recoverer)
+			mux.Use(helper.Recoverer)
 
 			return mux
 		})
@@ -74,6 +83,7 @@ func GetLifecycle(file string) *goapp.Lifecycle {
 	search.ConfigureServer(l, conf)
 	api.ConfigureServer(l, conf)
 	setup.ConfigureServer(l, conf)
+	tenant.ConfigureServer(l, conf)
 	guard.ConfigureServer(l, conf)
 
 	return l
@@ -165,6 +175,11 @@ func RunRequest(method string, path string, options ...interface{}) (*Response,
 		for name, value := range headers {
 			req.Header.Set(name, value)
 		}
+
+		// the client ignores the Host header
+		if host, ok := headers["Host"]; ok {
+			req.Host = host
+		}
 	}
 
 	core.PanicOnError(err)
@@ -180,7 +195,6 @@ func RunHttpTest(t *testing.T, f func(t *testing.T, ts *httptest.Server, app *go
 
 	l.Run(func(app *goapp.App, state *goapp.GoroutineState) error {
 		var err error
-		var res *Response
 
 		mux := app.Get("goji.mux").(*web.Mux)
 
@@ -194,16 +208,24 @@ func RunHttpTest(t *testing.T, f func(t *testing.T, ts *httptest.Server, app *go
 			}
 		}()
 
-		res, err = RunRequest("PUT", ts.URL+"/setup/uninstall", nil)
-		core.PanicIf(res.StatusCode != http.StatusOK, fmt.Sprintf("Expected code 200, get %d\n%s", res.StatusCode, string(res.GetBody()[:])))
-		core.PanicOnError(err)
+		// create a fresh schema for each tenant
+		manager := app.Get("gonode.manager").(core.NodeManager)
 
-		res, err = RunRequest("PUT", ts.URL+"/setup/install", nil)
-		core.PanicIf(res.StatusCode != http.StatusOK, fmt.Sprintf("Expected code 200, get %d\n%s", res.StatusCode, string(res.GetBody()[:])))
-		core.PanicOnError(err)
+		if _, ok := manager.(*core.InMemoryNodeManager); ok {
+			for _, tenant := range app.Get("gonode.tenants").(*core.Tenants).All() {
+				tenant.Manager.(*core.InMemoryNodeManager).Reset()
+			}
+		} else {
+			for _, migrator := range app.Get("gonode.schema.migrators").([]*core.SchemaMigrator) {
+				_, err = migrator.Reset(context.Background())
+				core.PanicOnError(err)
+
+				_, err = migrator.Migrate(context.Background())
+				core.PanicOnError(err)
+			}
+		}
 
 		// create a valid user
-		manager := app.Get("gonode.manager").(*core.PgNodeManager)
 
 		u := app.Get("gonode.handler_collection").(core.HandlerCollection).NewNode("core.user")
 		u.Name = "User ZZ"